@@ -0,0 +1,169 @@
+package main
+
+import (
+	"WarehouseControl/internal/models"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// audit verify проверяет, что журнал item_history согласован с таблицей items:
+//   - история каждого товара начинается с create;
+//   - old_values каждой записи совпадают с new_values предыдущей;
+//   - new_values последней записи совпадают с текущим состоянием товара,
+//     в том числе товара в корзине;
+//   - история окончательно удаленного товара заканчивается purge;
+//   - у каждой записи заполнен changed_by;
+//   - общий остаток товара равен сумме остатков по местам хранения.
+//
+// Записи об остатках по местам (stock) в цепочку old_values/new_values не входят
+func (a *app) audit(args []string) error {
+	if len(args) == 0 || args[0] != "verify" {
+		return errors.New("audit: only 'verify' subcommand is supported")
+	}
+
+	page, err := a.items.GetAllItems(a.ctx, models.ItemQuery{})
+	if err != nil {
+		return err
+	}
+	trash, err := a.items.GetAllItems(a.ctx, models.ItemQuery{Deleted: true})
+	if err != nil {
+		return err
+	}
+	items := append(page.Items, trash.Items...)
+
+	history, err := a.history.GetAllHistory(a.ctx, models.HistoryFilter{})
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	byItem := make(map[int][]*models.ItemHistory)
+	for _, h := range history {
+		if h.Action == models.ActionStock {
+			if h.ChangedBy == "" {
+				problems = append(problems, fmt.Sprintf("item %d: stock record %d has empty changed_by", h.ItemID, h.ID))
+			}
+			continue
+		}
+		byItem[h.ItemID] = append(byItem[h.ItemID], h)
+	}
+
+	report := func(itemID int, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("item %d: ", itemID)+fmt.Sprintf(format, args...))
+	}
+
+	existing := make(map[int]bool, len(items))
+	for _, item := range items {
+		existing[item.ID] = true
+	}
+
+	for itemID, records := range byItem {
+		if existing[itemID] {
+			continue
+		}
+		sortByID(records)
+		checkChain(itemID, records, report)
+		if last := records[len(records)-1]; last.Action != models.ActionPurge {
+			report(itemID, "item is missing but history ends with %q instead of purge (record %d)", last.Action, last.ID)
+		}
+	}
+
+	for _, item := range items {
+		var stockTotal models.Quantity
+		for _, st := range item.Stock {
+			stockTotal += st.Quantity
+		}
+		if stockTotal != item.Quantity {
+			report(item.ID, "quantity %s differs from stock total %s", item.Quantity, stockTotal)
+		}
+
+		records := byItem[item.ID]
+		sortByID(records)
+
+		if len(records) == 0 {
+			report(item.ID, "no history records")
+			continue
+		}
+
+		checkChain(item.ID, records, report)
+
+		last := records[len(records)-1]
+		if !matchesItem(last.NewValues, item) {
+			report(item.ID, "current row differs from last history record %d", last.ID)
+		}
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+
+	fmt.Printf("checked %d items and %d history records, %d problems found\n", len(items), len(history), len(problems))
+	if len(problems) > 0 {
+		return errors.New("audit trail is inconsistent")
+	}
+	return nil
+}
+
+// sortByID упорядочивает историю: записи одной транзакции имеют одинаковый changed_at,
+// порядок задает id
+func sortByID(records []*models.ItemHistory) {
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+}
+
+// checkChain проверяет начало истории с create, changed_by и совпадение old_values
+// каждой записи с new_values предыдущей
+func checkChain(itemID int, records []*models.ItemHistory, report func(itemID int, format string, args ...interface{})) {
+	if records[0].Action != models.ActionCreate {
+		report(itemID, "history starts with %q instead of create (record %d)", records[0].Action, records[0].ID)
+	}
+
+	for i, rec := range records {
+		if rec.ChangedBy == "" {
+			report(itemID, "record %d has empty changed_by", rec.ID)
+		}
+		if i > 0 && !reflect.DeepEqual(rec.OldValues, records[i-1].NewValues) {
+			report(itemID, "record %d old_values do not match new_values of record %d", rec.ID, records[i-1].ID)
+		}
+	}
+}
+
+// matchesItem сравнивает отслеживаемые поля товара со снимком to_jsonb(NEW)
+func matchesItem(values models.JSONB, item *models.Item) bool {
+	if values == nil {
+		return false
+	}
+
+	sku, _ := values["sku"].(string)
+	name, _ := values["name"].(string)
+	quantity, _ := values["quantity"].(float64)
+	unit, _ := values["unit"].(string)
+	precision, _ := values["unit_precision"].(float64)
+	barcodes, _ := values["barcodes"].([]interface{})
+	tags, _ := values["tags"].([]interface{})
+
+	if sku != item.SKU || name != item.Name || models.QuantityFromFloat(quantity) != item.Quantity ||
+		unit != item.Unit || int(precision) != item.UnitPrecision {
+		return false
+	}
+
+	categoryID, hasCategory := values["category_id"].(float64)
+	if hasCategory != (item.CategoryID != nil) || hasCategory && int(categoryID) != *item.CategoryID {
+		return false
+	}
+
+	return equalStrings(barcodes, item.Barcodes) && equalStrings(tags, item.Tags)
+}
+
+func equalStrings(values []interface{}, strs []string) bool {
+	if len(values) != len(strs) {
+		return false
+	}
+	for i, v := range values {
+		if v != strs[i] {
+			return false
+		}
+	}
+	return true
+}
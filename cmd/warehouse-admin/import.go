@@ -0,0 +1,129 @@
+package main
+
+import (
+	"WarehouseControl/internal/lib/itemimport"
+	"WarehouseControl/internal/models"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// importItems загружает товары из CSV или XLSX тем же путем, что POST /items/import.
+// Изменения записываются в историю от имени существующего пользователя -user
+func (a *app) importItems(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "", "CSV or XLSX file")
+	format := fs.String("format", "", "csv or xlsx, by default from the file extension")
+	matchBy := fs.String("match", "sku", "match existing items by sku or name")
+	mapping := fs.String("map", "", "column mapping: sku=Артикул,name=Наименование")
+	username := fs.String("user", "", "user the changes are attributed to")
+	dryRun := fs.Bool("dry-run", false, "validate and show changes without saving")
+	fs.Parse(args)
+
+	if *file == "" || *username == "" {
+		return errors.New("import: -file and -user are required")
+	}
+
+	user, err := a.users.GetUserByUsername(a.ctx, *username)
+	if err != nil {
+		return fmt.Errorf("import: user %s: %w", *username, err)
+	}
+	if user.Disabled {
+		return fmt.Errorf("import: user %s is disabled", *username)
+	}
+
+	opts := itemimport.Options{
+		Format:  itemimport.Format(*format),
+		Mapping: itemimport.Mapping{},
+		MatchBy: models.ImportMatch(*matchBy),
+	}
+	if opts.Format == "" {
+		opts.Format = itemimport.Format(strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), "."))
+	}
+	if !opts.MatchBy.IsValid() {
+		return errors.New("import: -match must be sku or name")
+	}
+	if *mapping != "" {
+		for _, pair := range strings.Split(*mapping, ",") {
+			field, column, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("import: invalid mapping %q, expected field=column", pair)
+			}
+			opts.Mapping[strings.TrimSpace(field)] = strings.TrimSpace(column)
+		}
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+
+	rows, err := itemimport.Parse(data, opts)
+	if err != nil {
+		return err
+	}
+
+	report, err := a.items.ImportItems(a.ctx, rows, models.ImportOptions{MatchBy: opts.MatchBy, DryRun: *dryRun}, user.Username)
+	if err != nil {
+		return err
+	}
+
+	printImportReport(report)
+
+	switch {
+	case report.Failed > 0:
+		return errors.New("import has invalid rows, nothing was saved")
+	case report.DryRun:
+		fmt.Println("dry run, nothing was saved")
+	}
+	return nil
+}
+
+func printImportReport(report *models.ImportReport) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tACTION\tITEM\tSKU\tDETAILS")
+	for _, row := range report.Rows {
+		item := ""
+		if row.ItemID != 0 {
+			item = fmt.Sprint(row.ItemID)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", row.Line, row.Action, item, row.SKU, rowDetails(row))
+	}
+	tw.Flush()
+
+	fmt.Printf("created %d, updated %d, unchanged %d, failed %d\n", report.Created, report.Updated, report.Unchanged, report.Failed)
+}
+
+func rowDetails(row models.ImportRowResult) string {
+	if len(row.Errors) > 0 {
+		return strings.Join(row.Errors, "; ")
+	}
+
+	fields := make([]string, 0, len(row.Changes))
+	for field := range row.Changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	details := make([]string, 0, len(fields))
+	for _, field := range fields {
+		change := row.Changes[field]
+		if row.Action == models.ImportCreate {
+			details = append(details, fmt.Sprintf("%s=%s", field, jsonValue(change.New)))
+			continue
+		}
+		details = append(details, fmt.Sprintf("%s: %s -> %s", field, jsonValue(change.Old), jsonValue(change.New)))
+	}
+	return strings.Join(details, ", ")
+}
+
+func jsonValue(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,104 @@
+// warehouse-admin - утилита администрирования: пользователи, миграции,
+// демо-данные, импорт товаров и проверка журнала изменений.
+//
+//	warehouse-admin -config ./config/local.yml user create -username ivan -role manager
+//
+// Пароли читаются из stdin и не попадают в историю командной оболочки
+package main
+
+import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/lib/passwords"
+	"WarehouseControl/internal/storage/postgres"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `usage: warehouse-admin [-config path] <command> [args]
+
+commands:
+  user create -username NAME -role ROLE   create local user, password from stdin
+  user list                               list users
+  user set-role -username NAME -role ROLE change role and end user sessions
+  user reset-password -username NAME      set new password from stdin
+  user disable -username NAME             disable user and end sessions
+  user enable -username NAME              enable user
+  migrate [-path DIR]                     apply pending migrations
+  migrate version                         print schema version
+  seed                                    create demo users and items
+  audit verify                            check item_history consistency
+  import -file F -user NAME [-match sku|name] [-map sku=COL,...] [-format csv|xlsx] [-dry-run]
+                                          create and update items from CSV or XLSX
+`
+
+// app - общие зависимости подкоманд
+type app struct {
+	ctx       context.Context
+	storage   *postgres.Storage
+	passwords *passwords.Hasher
+	users     *postgres.UserStorage
+	tokens    *postgres.TokenStorage
+	items     *postgres.ItemStorage
+	history   *postgres.HistoryStorage
+}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+
+	cfg := config.MustLoad()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	hasher, err := passwords.New(cfg.Auth.Password)
+	if err != nil {
+		fatal(err)
+	}
+
+	storage, err := postgres.InitDB(&cfg.Database)
+	if err != nil {
+		fatal(err)
+	}
+	defer storage.Close()
+
+	a := &app{
+		ctx:       context.Background(),
+		storage:   storage,
+		passwords: hasher,
+		users:     postgres.NewUserStorage(storage.DB),
+		tokens:    postgres.NewTokenStorage(storage.DB),
+		items:     postgres.NewItemStorage(storage.DB),
+		history:   postgres.NewHistoryStorage(storage.DB),
+	}
+
+	switch args[0] {
+	case "user":
+		err = a.user(args[1:])
+	case "migrate":
+		err = a.migrate(args[1:])
+	case "seed":
+		err = a.seed(args[1:])
+	case "audit":
+		err = a.audit(args[1:])
+	case "import":
+		err = a.importItems(args[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		storage.Close()
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"WarehouseControl/internal/storage/postgres"
+	"flag"
+	"fmt"
+)
+
+func (a *app) migrate(args []string) error {
+	if len(args) > 0 && args[0] == "version" {
+		version, dirty, err := postgres.MigrationVersion(a.ctx, a.storage.DB)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version %d", version)
+		if dirty {
+			fmt.Print(" (dirty)")
+		}
+		fmt.Println()
+		return nil
+	}
+
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	path := fs.String("path", "./migrations", "migrations directory")
+	fs.Parse(args)
+
+	applied, err := postgres.Migrate(a.ctx, a.storage.DB, *path)
+	for _, v := range applied {
+		fmt.Printf("applied %06d\n", v)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("no pending migrations")
+	}
+	return nil
+}
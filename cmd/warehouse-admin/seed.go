@@ -0,0 +1,68 @@
+package main
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"errors"
+	"fmt"
+)
+
+// seedChangedBy попадает в item_history.changed_by демо-товаров
+const seedChangedBy = "seed"
+
+var demoUsers = []struct {
+	username string
+	role     models.UserRole
+}{
+	{"manager", models.RoleManager},
+	{"viewer", models.RoleViewer},
+}
+
+var demoItems = []models.Item{
+	{SKU: "PAL-1200-800", Name: "Паллета деревянная 1200x800", Quantity: models.NewQuantity(40), Barcodes: []string{"4607000000014"}},
+	{SKU: "FLM-500", Name: "Стрейч-пленка 500 мм", Quantity: models.NewQuantity(120), Barcodes: []string{"4607000000021"}},
+	{SKU: "BOX-600-400-400", Name: "Коробка картонная 600x400x400", Quantity: models.NewQuantity(350), Barcodes: []string{"4607000000038"}},
+	{SKU: "TAPE-48", Name: "Скотч упаковочный 48 мм", Quantity: models.NewQuantity(200), Barcodes: []string{"4607000000045"}},
+	{SKU: "SCN-01", Name: "Сканер штрихкодов", Quantity: models.NewQuantity(6), Barcodes: []string{"4607000000052"}},
+}
+
+// seed создает демо-пользователей с общим паролем из stdin и демо-товары.
+// Существующие пользователи не изменяются, товары создаются только в пустой базе
+func (a *app) seed(args []string) error {
+	hash, err := a.readPasswordHash("", nil)
+	if err != nil {
+		return err
+	}
+
+	for _, du := range demoUsers {
+		user := &models.User{Username: du.username, PasswordHash: hash, Role: du.role}
+		err = a.users.CreateUser(a.ctx, user)
+		switch {
+		case errors.Is(err, storage.ErrUserExists):
+			fmt.Printf("user %s already exists, skipped\n", du.username)
+		case err != nil:
+			return err
+		default:
+			fmt.Printf("user %s (%s) created\n", user.Username, user.Role)
+		}
+	}
+
+	page, err := a.items.GetAllItems(a.ctx, models.ItemQuery{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if page.Total > 0 {
+		fmt.Printf("%d items already exist, demo items skipped\n", page.Total)
+		return nil
+	}
+
+	for _, di := range demoItems {
+		item := di
+		if err = a.items.CreateItem(a.ctx, &item, seedChangedBy); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%d demo items created\n", len(demoItems))
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/term"
+)
+
+func (a *app) user(args []string) error {
+	if len(args) == 0 {
+		return errors.New("user: subcommand is required (create, list, set-role, reset-password, disable, enable)")
+	}
+
+	fs := flag.NewFlagSet("user "+args[0], flag.ExitOnError)
+	username := fs.String("username", "", "username")
+	role := fs.String("role", "", "role: admin, manager or viewer")
+	fs.Parse(args[1:])
+
+	switch args[0] {
+	case "create":
+		return a.userCreate(*username, models.UserRole(*role))
+	case "list":
+		return a.userList()
+	case "set-role":
+		return a.userSetRole(*username, models.UserRole(*role))
+	case "reset-password":
+		return a.userResetPassword(*username)
+	case "disable":
+		return a.userSetDisabled(*username, true)
+	case "enable":
+		return a.userSetDisabled(*username, false)
+	}
+
+	return fmt.Errorf("user: unknown subcommand %q", args[0])
+}
+
+func (a *app) userCreate(username string, role models.UserRole) error {
+	if len(username) < 3 || len(username) > 50 {
+		return errors.New("username must be 3 to 50 characters")
+	}
+	if !role.IsValid() {
+		return fmt.Errorf("invalid role %q", role)
+	}
+
+	hash, err := a.readPasswordHash(username, nil)
+	if err != nil {
+		return err
+	}
+
+	user := &models.User{Username: username, PasswordHash: hash, Role: role}
+	if err = a.users.CreateUser(a.ctx, user); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			return fmt.Errorf("user %q already exists", username)
+		}
+		return err
+	}
+
+	fmt.Printf("user %s created with id %d\n", user.Username, user.ID)
+	return nil
+}
+
+func (a *app) userList() error {
+	users, err := a.users.ListUsers(a.ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tUSERNAME\tROLE\tPROVIDER\tDISABLED\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%t\t%s\n",
+			u.ID, u.Username, u.Role, u.AuthProvider, u.Disabled, u.CreatedAt.Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+func (a *app) userSetRole(username string, role models.UserRole) error {
+	if !role.IsValid() {
+		return fmt.Errorf("invalid role %q", role)
+	}
+
+	user, err := a.findUser(username)
+	if err != nil {
+		return err
+	}
+
+	if err = a.users.UpdateUserRole(a.ctx, user.ID, role); err != nil {
+		return err
+	}
+
+	// Как и в API: токены со старой ролью больше не действительны
+	if _, err = a.tokens.RevokeUserSessions(a.ctx, user.ID); err != nil {
+		return err
+	}
+
+	fmt.Printf("user %s role changed: %s -> %s\n", user.Username, user.Role, role)
+	return nil
+}
+
+func (a *app) userResetPassword(username string) error {
+	user, err := a.findUser(username)
+	if err != nil {
+		return err
+	}
+	if user.AuthProvider != models.AuthProviderLocal {
+		return fmt.Errorf("user %s signs in via %s and has no local password", user.Username, user.AuthProvider)
+	}
+
+	history, err := a.users.GetPasswordHistory(a.ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	hash, err := a.readPasswordHash(user.Username, append([]string{user.PasswordHash}, history...))
+	if err != nil {
+		return err
+	}
+
+	if err = a.users.ChangePassword(a.ctx, user.ID, hash, a.passwords.HistorySize()); err != nil {
+		return err
+	}
+
+	if _, err = a.tokens.RevokeUserSessions(a.ctx, user.ID); err != nil {
+		return err
+	}
+
+	fmt.Printf("password of user %s changed, sessions revoked\n", user.Username)
+	return nil
+}
+
+func (a *app) userSetDisabled(username string, disabled bool) error {
+	user, err := a.findUser(username)
+	if err != nil {
+		return err
+	}
+
+	if err = a.users.SetUserDisabled(a.ctx, user.ID, disabled); err != nil {
+		return err
+	}
+
+	if !disabled {
+		fmt.Printf("user %s enabled\n", user.Username)
+		return nil
+	}
+
+	if _, err = a.tokens.RevokeUserSessions(a.ctx, user.ID); err != nil {
+		return err
+	}
+
+	fmt.Printf("user %s disabled, sessions revoked\n", user.Username)
+	return nil
+}
+
+func (a *app) findUser(username string) (*models.User, error) {
+	if username == "" {
+		return nil, errors.New("-username is required")
+	}
+
+	user, err := a.users.GetUserByUsername(a.ctx, username)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil, fmt.Errorf("user %q not found", username)
+	}
+	return user, err
+}
+
+// readPasswordHash читает пароль из stdin, проверяет его по политике паролей
+// и возвращает хеш. previous - прежние хеши, которые нельзя повторять. В терминале
+// ввод не отображается и запрашивается дважды, из канала читается первая строка
+func (a *app) readPasswordHash(username string, previous []string) (string, error) {
+	password, err := readPassword()
+	if err != nil {
+		return "", err
+	}
+
+	if err = a.passwords.Check(username, password); err != nil {
+		return "", err
+	}
+	if a.passwords.HistorySize() > 0 {
+		if err = a.passwords.CheckReuse(password, previous); err != nil {
+			return "", err
+		}
+	}
+
+	return a.passwords.Hash(password)
+}
+
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprint(os.Stderr, "Repeat password: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	if string(first) != string(second) {
+		return "", errors.New("passwords do not match")
+	}
+
+	return string(first), nil
+}
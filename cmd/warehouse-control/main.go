@@ -4,18 +4,19 @@ import (
 	"WarehouseControl/internal/config"
 	"WarehouseControl/internal/http-server/handlers"
 	authMiddleware "WarehouseControl/internal/http-server/handlers/middleware"
-	"WarehouseControl/internal/http-server/middleware/mwlogger"
+	"WarehouseControl/internal/lib/jwtkeys"
 	"WarehouseControl/internal/lib/logger/handlers/slogpretty"
 	"WarehouseControl/internal/lib/logger/sl"
+	"WarehouseControl/internal/lib/passwords"
 	"WarehouseControl/internal/storage/postgres"
+	"context"
 	"errors"
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 const (
@@ -38,52 +39,98 @@ func main() {
 		os.Exit(1)
 	}
 
+	signingKeys, err := jwtkeys.Load(cfg.Auth.Signing)
+	if err != nil {
+		log.Error("failed to load jwt signing keys", sl.Err(err))
+		os.Exit(1)
+	}
+
+	hasher, err := passwords.New(cfg.Auth.Password)
+	if err != nil {
+		log.Error("failed to init password hasher", sl.Err(err))
+		os.Exit(1)
+	}
+
+	sessions, err := authMiddleware.NewSessionCookies(cfg.Auth.Session)
+	if err != nil {
+		log.Error("invalid session config", sl.Err(err))
+		os.Exit(1)
+	}
+
+	if oidcCfg := cfg.Auth.OIDC; oidcCfg.Enabled && (oidcCfg.IssuerURL == "" || oidcCfg.ClientID == "" || oidcCfg.RedirectURL == "") {
+		log.Error("oidc is enabled but issuer_url, client_id or redirect_url is empty")
+		os.Exit(1)
+	}
+
 	// Инициализация репозиториев
 	userStorage := postgres.NewUserStorage(storage.DB)
 	itemStorage := postgres.NewItemStorage(storage.DB)
 	historyStorage := postgres.NewHistoryStorage(storage.DB)
+	tokenStorage := postgres.NewTokenStorage(storage.DB)
+	passwordResetStorage := postgres.NewPasswordResetStorage(storage.DB)
+	loginAttemptStorage := postgres.NewLoginAttemptStorage(storage.DB)
+	apiKeyStorage := postgres.NewAPIKeyStorage(storage.DB)
+	twoFactorStorage := postgres.NewTwoFactorStorage(storage.DB)
+	authEventStorage := postgres.NewAuthEventStorage(storage.DB)
+	warehouseStorage := postgres.NewWarehouseStorage(storage.DB)
+	categoryStorage := postgres.NewCategoryStorage(storage.DB)
+	movementStorage := postgres.NewStockMovementStorage(storage.DB)
 
 	// Инициализация хендлеров
-	authHandler := handlers.NewAuthHandler(userStorage, "secret-key", log)
+	authHandler := handlers.NewAuthHandler(
+		userStorage,
+		tokenStorage,
+		signingKeys,
+		hasher,
+		cfg.Auth.AccessTokenTTL,
+		cfg.Auth.RefreshTokenTTL,
+		sessions,
+		loginAttemptStorage,
+		cfg.Auth.LoginThrottle,
+		twoFactorStorage,
+		cfg.Auth.TwoFactor,
+		authEventStorage,
+		log,
+	)
+	twoFactorHandler := handlers.NewTwoFactorHandler(authHandler, log)
+	oidcHandler := handlers.NewOIDCHandler(authHandler, cfg.Auth.OIDC, log)
 	itemsHandler := handlers.NewItemsHandler(itemStorage, log)
 	historyHandler := handlers.NewHistoryHandler(historyStorage, log)
-
-	router := chi.NewRouter()
-
-	router.Use(middleware.RequestID)
-	router.Use(mwlogger.New(log))
-	router.Use(middleware.Recoverer)
-	router.Use(middleware.URLFormat)
-
-	// Раздача статических файлов
-	fs := http.FileServer(http.Dir("./static/"))
-	router.Handle("/static/*", http.StripPrefix("/static/", fs))
-
-	// Главная страница - проверяем авторизацию через JS
-	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "./static/index.html")
-	})
-
-	// Страница логина
-	router.Get("/login.html", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "./static/login.html")
-	})
-
-	// Публичные API маршруты
-	router.Post("/login", authHandler.Login)
-
-	// Защищенные API маршруты - применяем middleware
-	router.Group(func(r chi.Router) {
-		r.Use(authMiddleware.AuthMiddleware("secret-key", log))
-
-		r.Post("/items", itemsHandler.CreateItem)
-		r.Get("/items", itemsHandler.GetAllItems)
-		r.Get("/items/{id}", itemsHandler.GetItemByID)
-		r.Put("/items/{id}", itemsHandler.UpdateItem)
-		r.Delete("/items/{id}", itemsHandler.DeleteItem)
-		r.Get("/history", historyHandler.GetAllHistory)
-		r.Get("/history/{id}", historyHandler.GetHistoryByItemID)
-	})
+	usersHandler := handlers.NewUsersHandler(userStorage, tokenStorage, hasher, log)
+	apiKeysHandler := handlers.NewAPIKeysHandler(apiKeyStorage, log)
+	authEventsHandler := handlers.NewAuthEventsHandler(authEventStorage, log)
+	warehousesHandler := handlers.NewWarehousesHandler(warehouseStorage, log)
+	categoriesHandler := handlers.NewCategoriesHandler(categoryStorage, log)
+	movementsHandler := handlers.NewMovementsHandler(movementStorage, itemStorage, log)
+	passwordHandler := handlers.NewPasswordHandler(
+		userStorage,
+		tokenStorage,
+		passwordResetStorage,
+		hasher,
+		cfg.Auth.PasswordResetTTL,
+		log,
+	)
+
+	// Фоновая очистка истекших токенов
+	cleanupCtx, stopCleanup := context.WithCancel(context.Background())
+	go authHandler.CleanupExpiredTokens(cleanupCtx, time.Hour)
+
+	router := newRouter(routes{
+		auth:         authHandler,
+		twoFactor:    twoFactorHandler,
+		oidc:         oidcHandler,
+		password:     passwordHandler,
+		items:        itemsHandler,
+		history:      historyHandler,
+		users:        usersHandler,
+		apiKeys:      apiKeysHandler,
+		authEvents:   authEventsHandler,
+		warehouses:   warehousesHandler,
+		categories:   categoriesHandler,
+		movements:    movementsHandler,
+		authenticate: authMiddleware.AuthMiddleware(signingKeys, tokenStorage, apiKeyStorage, authEventStorage, sessions, log),
+		oidcEnabled:  cfg.Auth.OIDC.Enabled,
+	}, log)
 
 	log.Info("starting server", slog.String("address", cfg.HTTPServer.Address))
 
@@ -109,6 +156,8 @@ func main() {
 
 	log.Info("application stopping", slog.String("signal", sign.String()))
 
+	stopCleanup()
+
 	if err = srv.Shutdown(nil); err != nil {
 		log.Error("failed to shutdown server", sl.Err(err))
 	}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"WarehouseControl/internal/http-server/handlers"
+	authMiddleware "WarehouseControl/internal/http-server/handlers/middleware"
+	"WarehouseControl/internal/http-server/middleware/mwlogger"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// routes - хендлеры, из которых собирается роутер сервера
+type routes struct {
+	auth       *handlers.AuthHandler
+	twoFactor  *handlers.TwoFactorHandler
+	oidc       *handlers.OIDCHandler
+	password   *handlers.PasswordHandler
+	items      *handlers.ItemsHandler
+	history    *handlers.HistoryHandler
+	users      *handlers.UsersHandler
+	apiKeys    *handlers.APIKeysHandler
+	authEvents *handlers.AuthEventsHandler
+	warehouses *handlers.WarehousesHandler
+	categories *handlers.CategoriesHandler
+	movements  *handlers.MovementsHandler
+	// authenticate проверяет JWT или API ключ на защищенных маршрутах
+	authenticate func(http.Handler) http.Handler
+	oidcEnabled  bool
+}
+
+// newRouter собирает маршруты сервера. middleware.URLFormat не используется:
+// он отрезает расширение у любого пути, из-за чего /.well-known/jwks.json
+// и коды товаров с точкой (/items/by-code/ABC.1) не находились. Расширение
+// учитывается только у выгрузок через параметр маршрута {format}
+func newRouter(rt routes, log *slog.Logger) *chi.Mux {
+	router := chi.NewRouter()
+
+	router.Use(middleware.RequestID)
+	router.Use(mwlogger.New(log))
+	router.Use(middleware.Recoverer)
+
+	// Раздача статических файлов
+	fs := http.FileServer(http.Dir("./static/"))
+	router.Handle("/static/*", http.StripPrefix("/static/", fs))
+
+	// Главная страница - проверяем авторизацию через JS
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "./static/index.html")
+	})
+
+	// Страница логина
+	router.Get("/login.html", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "./static/login.html")
+	})
+
+	// Публичные API маршруты
+	router.Post("/login", rt.auth.Login)
+	router.Post("/auth/refresh", rt.auth.Refresh)
+	router.Post("/auth/2fa/enroll", rt.twoFactor.LoginEnroll)
+	router.Post("/auth/2fa/verify", rt.twoFactor.LoginVerify)
+	router.Post("/auth/password-reset", rt.password.ResetPassword)
+	router.Get("/.well-known/jwks.json", rt.auth.JWKS)
+	router.Get("/auth/providers", rt.oidc.Providers)
+
+	// Вход через OIDC провайдер, локальный /login остается запасным
+	if rt.oidcEnabled {
+		router.Get("/auth/oidc/login", rt.oidc.Login)
+		router.Get("/auth/oidc/callback", rt.oidc.Callback)
+	}
+
+	// Защищенные API маршруты - применяем middleware
+	router.Group(func(r chi.Router) {
+		r.Use(rt.authenticate)
+
+		// Маршруты пользовательской сессии недоступны API ключам
+		r.Group(func(r chi.Router) {
+			r.Use(authMiddleware.RequireUserSession(log))
+
+			r.Post("/auth/logout", rt.auth.Logout)
+			r.Put("/me/password", rt.password.ChangePassword)
+			r.Get("/me/2fa", rt.twoFactor.Status)
+			r.Post("/me/2fa/enroll", rt.twoFactor.Enroll)
+			r.Post("/me/2fa/confirm", rt.twoFactor.Confirm)
+			r.Post("/me/2fa/recovery-codes", rt.twoFactor.RegenerateRecoveryCodes)
+			r.Delete("/me/2fa", rt.twoFactor.Disable)
+		})
+
+		// Проверка прав по роли пользователя
+		perm := func(p authMiddleware.Permission) func(http.Handler) http.Handler {
+			return authMiddleware.RequirePermission(p, log)
+		}
+
+		r.With(perm(authMiddleware.PermItemsCreate)).Post("/items", rt.items.CreateItem)
+		r.With(perm(authMiddleware.PermItemsCreate), perm(authMiddleware.PermItemsUpdate)).Post("/items/import", rt.items.ImportItems)
+		r.With(perm(authMiddleware.PermItemsRead)).Get("/items", rt.items.GetAllItems)
+		r.With(perm(authMiddleware.PermItemsRead)).Get("/items/search", rt.items.SearchItems)
+		r.With(perm(authMiddleware.PermItemsRead)).Get("/items/export", rt.items.ExportItems)
+		r.With(perm(authMiddleware.PermItemsRead)).Get("/items/export.{format}", rt.items.ExportItems)
+		r.With(perm(authMiddleware.PermItemsRead)).Get("/items/trash", rt.items.GetTrash)
+		r.With(perm(authMiddleware.PermItemsPurge)).Delete("/items/trash/{id}", rt.items.PurgeItem)
+		r.With(perm(authMiddleware.PermItemsRead)).Get("/items/{id}", rt.items.GetItemByID)
+		r.With(perm(authMiddleware.PermItemsRead)).Get("/items/by-code/{code}", rt.items.GetItemByCode)
+		r.With(perm(authMiddleware.PermItemsUpdate)).Put("/items/{id}", rt.items.UpdateItem)
+		r.With(perm(authMiddleware.PermItemsUpdate)).Patch("/items/{id}", rt.items.PatchItem)
+		r.With(perm(authMiddleware.PermItemsDelete)).Delete("/items/{id}", rt.items.DeleteItem)
+		r.With(perm(authMiddleware.PermItemsDelete)).Post("/items/{id}/restore", rt.items.RestoreItem)
+		r.With(perm(authMiddleware.PermItemsUpdate)).Put("/items/{id}/stock/{locationID}", rt.items.SetItemStock)
+		r.With(perm(authMiddleware.PermItemsUpdate)).Post("/items/{id}/receive", rt.movements.Receive)
+		r.With(perm(authMiddleware.PermItemsUpdate)).Post("/items/{id}/issue", rt.movements.Issue)
+		r.With(perm(authMiddleware.PermItemsUpdate)).Post("/items/{id}/adjust", rt.movements.Adjust)
+		r.With(perm(authMiddleware.PermItemsUpdate)).Post("/items/{id}/transfer", rt.movements.Transfer)
+		r.With(perm(authMiddleware.PermHistoryRead)).Get("/items/{id}/movements", rt.movements.ListMovements)
+		r.With(perm(authMiddleware.PermHistoryRead)).Get("/history", rt.history.GetAllHistory)
+		r.With(perm(authMiddleware.PermHistoryRead)).Get("/history/export", rt.history.ExportHistory)
+		r.With(perm(authMiddleware.PermHistoryRead)).Get("/history/export.{format}", rt.history.ExportHistory)
+		r.With(perm(authMiddleware.PermHistoryRead)).Get("/history/{id}", rt.history.GetHistoryByItemID)
+
+		r.With(perm(authMiddleware.PermItemsRead)).Get("/tags", rt.items.ListTags)
+
+		// Категории товаров: читают все, изменяют manager и admin
+		r.Route("/categories", func(r chi.Router) {
+			r.With(perm(authMiddleware.PermItemsRead)).Get("/", rt.categories.ListCategories)
+			r.With(perm(authMiddleware.PermItemsRead)).Get("/{id}", rt.categories.GetCategory)
+			r.With(perm(authMiddleware.PermCategoriesManage)).Post("/", rt.categories.CreateCategory)
+			r.With(perm(authMiddleware.PermCategoriesManage)).Put("/{id}", rt.categories.RenameCategory)
+			r.With(perm(authMiddleware.PermCategoriesManage)).Post("/{id}/move", rt.categories.MoveCategory)
+			r.With(perm(authMiddleware.PermCategoriesManage)).Delete("/{id}", rt.categories.DeleteCategory)
+		})
+
+		// Склады и места хранения: читают все, изменяет только admin
+		r.Route("/warehouses", func(r chi.Router) {
+			r.With(perm(authMiddleware.PermItemsRead)).Get("/", rt.warehouses.ListWarehouses)
+			r.With(perm(authMiddleware.PermItemsRead)).Get("/{id}/locations", rt.warehouses.ListLocations)
+			r.With(perm(authMiddleware.PermWarehousesManage)).Post("/", rt.warehouses.CreateWarehouse)
+			r.With(perm(authMiddleware.PermWarehousesManage)).Put("/{id}", rt.warehouses.UpdateWarehouse)
+			r.With(perm(authMiddleware.PermWarehousesManage)).Delete("/{id}", rt.warehouses.DeleteWarehouse)
+			r.With(perm(authMiddleware.PermWarehousesManage)).Post("/{id}/locations", rt.warehouses.CreateLocation)
+		})
+		r.Route("/locations", func(r chi.Router) {
+			r.With(perm(authMiddleware.PermItemsRead)).Get("/{id}/stock", rt.warehouses.GetLocationStock)
+			r.With(perm(authMiddleware.PermWarehousesManage)).Put("/{id}", rt.warehouses.UpdateLocation)
+			r.With(perm(authMiddleware.PermWarehousesManage)).Delete("/{id}", rt.warehouses.DeleteLocation)
+		})
+
+		// Управление пользователями - только admin
+		r.Route("/users", func(r chi.Router) {
+			r.Use(perm(authMiddleware.PermUsersManage))
+
+			r.Get("/", rt.users.ListUsers)
+			r.Post("/", rt.users.CreateUser)
+			r.Put("/{id}/role", rt.users.UpdateUserRole)
+			r.Post("/{id}/disable", rt.users.DisableUser)
+			r.Post("/{id}/enable", rt.users.EnableUser)
+			r.Delete("/{id}", rt.users.DeleteUser)
+			r.Delete("/{id}/sessions", rt.users.RevokeSessions)
+			r.Post("/{id}/password-reset", rt.password.CreateResetToken)
+			r.Delete("/{id}/2fa", rt.twoFactor.Reset)
+		})
+
+		// API ключи сервисных учетных записей - только admin
+		r.Route("/api-keys", func(r chi.Router) {
+			r.Use(perm(authMiddleware.PermAPIKeysManage))
+
+			r.Get("/", rt.apiKeys.ListAPIKeys)
+			r.Post("/", rt.apiKeys.CreateAPIKey)
+			r.Delete("/{id}", rt.apiKeys.RevokeAPIKey)
+		})
+
+		// Журнал входов и отклоненных токенов - только admin
+		r.With(perm(authMiddleware.PermAuditRead)).Get("/auth-events", rt.authEvents.ListAuthEvents)
+	})
+
+	return router
+}
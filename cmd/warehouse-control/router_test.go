@@ -0,0 +1,216 @@
+package main
+
+import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/http-server/handlers"
+	authMiddleware "WarehouseControl/internal/http-server/handlers/middleware"
+	"WarehouseControl/internal/lib/jwtkeys"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"WarehouseControl/internal/storage/postgres"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type notRevoked struct{}
+
+func (notRevoked) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return false, nil
+}
+
+// codeItems находит товары только по точному коду
+type codeItems struct {
+	postgres.ItemStorageI
+	items map[string]*models.Item
+}
+
+func (s codeItems) StreamItems(ctx context.Context, query models.ItemQuery, fn func(*models.Item) error) error {
+	return nil
+}
+
+func (s codeItems) GetItemByCode(ctx context.Context, code string) (*models.Item, error) {
+	item, ok := s.items[code]
+	if !ok {
+		return nil, storage.ErrItemNotFound
+	}
+	return item, nil
+}
+
+func testKeys(t *testing.T) *jwtkeys.KeySet {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "ed25519.pem")
+	if err = os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := jwtkeys.Load(config.Signing{
+		Issuer:    "warehouse-control",
+		Audience:  "warehouse-control",
+		ActiveKey: "ed-1",
+		Keys:      []config.SigningKey{{ID: "ed-1", Algorithm: jwtkeys.AlgEdDSA, PrivateKeyFile: file}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// testToken - access токен viewer, которому доступно чтение товаров
+func testToken(t *testing.T, keys *jwtkeys.KeySet) string {
+	t.Helper()
+
+	now := time.Now()
+	token, err := keys.Sign(&models.JWTClaims{
+		Username: "viewer",
+		Role:     models.RoleViewer,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti-1",
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func testRouter(t *testing.T, keys *jwtkeys.KeySet, items postgres.ItemStorageI) http.Handler {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessions, err := authMiddleware.NewSessionCookies(config.Session{Mode: authMiddleware.SessionModeBearer, SameSite: "strict"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newRouter(routes{
+		auth: handlers.NewAuthHandler(nil, nil, keys, nil, time.Minute, time.Hour, sessions,
+			nil, config.LoginThrottle{}, nil, config.TwoFactor{}, nil, log),
+		items:        handlers.NewItemsHandler(items, log),
+		authenticate: authMiddleware.AuthMiddleware(keys, notRevoked{}, nil, nil, sessions, log),
+	}, log)
+}
+
+func TestJWKSRoute(t *testing.T) {
+	keys := testKeys(t)
+	router := testRouter(t, keys, nil)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var set jwtkeys.JWKS
+	if err := json.NewDecoder(rec.Body).Decode(&set); err != nil {
+		t.Fatalf("decode jwks: %v", err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kid != "ed-1" || set.Keys[0].Kty != "OKP" {
+		t.Fatalf("keys = %+v, want one OKP key ed-1", set.Keys)
+	}
+}
+
+// Формат выгрузки можно задать расширением пути, без расширения - csv
+func TestItemExportRoute(t *testing.T) {
+	keys := testKeys(t)
+	router := testRouter(t, keys, codeItems{})
+	token := testToken(t, keys)
+
+	tests := []struct {
+		path        string
+		contentType string
+	}{
+		{"/items/export", "text/csv; charset=utf-8"},
+		{"/items/export.ndjson", "application/x-ndjson"},
+		{"/items/export.xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{"/items/export?format=ndjson", "application/x-ndjson"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Fatalf("content type = %q, want %q", got, tt.contentType)
+			}
+		})
+	}
+}
+
+// Коды с точкой ищутся целиком: ABC.1 не должен находить ABC
+func TestItemByCodeRoute(t *testing.T) {
+	keys := testKeys(t)
+	router := testRouter(t, keys, codeItems{items: map[string]*models.Item{
+		"ABC":      {ID: 1, SKU: "ABC"},
+		"ABC.1":    {ID: 2, SKU: "ABC.1"},
+		"PAL.json": {ID: 3, SKU: "PAL.json"},
+	}})
+
+	token := testToken(t, keys)
+
+	tests := []struct {
+		code   string
+		status int
+		id     int
+	}{
+		{"ABC", http.StatusOK, 1},
+		{"ABC.1", http.StatusOK, 2},
+		{"PAL.json", http.StatusOK, 3},
+		{"ABC.2", http.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/items/by-code/"+tt.code, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Data models.Item `json:"data"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Data.ID != tt.id {
+				t.Fatalf("item id = %d, want %d", resp.Data.ID, tt.id)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ type Config struct {
 	Env        string     `yaml:"env" env-default:"local"`
 	Database   Database   `yaml:"database"`
 	HTTPServer HTTPServer `yaml:"http_server"`
+	Auth       Auth       `yaml:"auth"`
 }
 
 type Database struct {
@@ -28,6 +29,106 @@ type HTTPServer struct {
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
 }
 
+type Auth struct {
+	AccessTokenTTL   time.Duration `yaml:"access_token_ttl" env-default:"15m"`
+	RefreshTokenTTL  time.Duration `yaml:"refresh_token_ttl" env-default:"720h"`
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl" env-default:"1h"`
+	Signing          Signing       `yaml:"signing"`
+	Password         Password      `yaml:"password"`
+	Session          Session       `yaml:"session"`
+	LoginThrottle    LoginThrottle `yaml:"login_throttle"`
+	TwoFactor        TwoFactor     `yaml:"two_factor"`
+	OIDC             OIDC          `yaml:"oidc"`
+}
+
+// Session - как веб-интерфейс получает токены. bearer: токены в теле ответа,
+// клиент хранит их сам. cookie: токены в HttpOnly cookie, изменяющие запросы
+// защищены double-submit CSRF токеном. Заголовок Authorization работает в обоих режимах
+type Session struct {
+	Mode         string `yaml:"mode" env-default:"bearer"` // bearer или cookie
+	CookieSecure bool   `yaml:"cookie_secure" env-default:"true"`
+	SameSite     string `yaml:"same_site" env-default:"strict"` // strict или lax
+	CookieDomain string `yaml:"cookie_domain"`
+}
+
+// Password - хеширование и политика паролей. Хеш, созданный другим алгоритмом
+// или с другими параметрами, пересчитывается при успешном входе пользователя
+type Password struct {
+	Algorithm  string `yaml:"algorithm" env-default:"argon2id"` // argon2id или bcrypt
+	Argon2     Argon2 `yaml:"argon2"`
+	BcryptCost int    `yaml:"bcrypt_cost" env-default:"12"`
+	MinLength  int    `yaml:"min_length" env-default:"8"`
+	MaxLength  int    `yaml:"max_length" env-default:"128"`
+	// DenyListFile - список утекших паролей, по одному в строке
+	DenyListFile string `yaml:"deny_list_file"`
+	// HistorySize - сколько прежних паролей нельзя использовать повторно
+	HistorySize int `yaml:"history_size" env-default:"5"`
+}
+
+type Argon2 struct {
+	Memory      int `yaml:"memory" env-default:"65536"` // KiB
+	Iterations  int `yaml:"iterations" env-default:"3"`
+	Parallelism int `yaml:"parallelism" env-default:"2"`
+}
+
+// TwoFactor - второй фактор TOTP (RFC 6238) при входе по паролю. Для ролей
+// из EnforcedRoles он обязателен: пользователь без TOTP регистрирует его при входе
+type TwoFactor struct {
+	Issuer        string        `yaml:"issuer" env-default:"WarehouseControl"`
+	EnforcedRoles []string      `yaml:"enforced_roles"`
+	ChallengeTTL  time.Duration `yaml:"challenge_ttl" env-default:"5m"`
+	MaxAttempts   int           `yaml:"max_attempts" env-default:"5"`
+	RecoveryCodes int           `yaml:"recovery_codes" env-default:"10"`
+}
+
+// OIDC - вход через корпоративный OpenID Connect провайдер (authorization code + PKCE).
+// Роль берется из RoleClaim через RoleMapping; если ни одно значение не
+// сопоставлено, используется DefaultRole, а при пустом DefaultRole вход запрещен
+type OIDC struct {
+	Enabled       bool              `yaml:"enabled" env-default:"false"`
+	IssuerURL     string            `yaml:"issuer_url"`
+	ClientID      string            `yaml:"client_id"`
+	ClientSecret  string            `yaml:"client_secret" env:"OIDC_CLIENT_SECRET"`
+	RedirectURL   string            `yaml:"redirect_url"`
+	Scopes        []string          `yaml:"scopes" env-default:"openid,profile,email"`
+	UsernameClaim string            `yaml:"username_claim" env-default:"preferred_username"`
+	RoleClaim     string            `yaml:"role_claim" env-default:"groups"`
+	RoleMapping   map[string]string `yaml:"role_mapping"`
+	DefaultRole   string            `yaml:"default_role"`
+}
+
+// LoginThrottle - защита POST /login от перебора. После каждой неудачи
+// вход блокируется на BaseDelay * 2^(n-1), но не больше MaxDelay. После
+// MaxFailures неудач подряд учетная запись блокируется на LockoutDuration
+type LoginThrottle struct {
+	MaxFailures     int           `yaml:"max_failures" env-default:"5"`
+	IPMaxFailures   int           `yaml:"ip_max_failures" env-default:"20"`
+	BaseDelay       time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay        time.Duration `yaml:"max_delay" env-default:"1m"`
+	LockoutDuration time.Duration `yaml:"lockout_duration" env-default:"15m"`
+	FailureWindow   time.Duration `yaml:"failure_window" env-default:"1h"`
+}
+
+// Signing - ключи подписи JWT. Токены подписываются ключом ActiveKey,
+// проверяются любым из Keys, что позволяет ротировать ключи без разлогина
+type Signing struct {
+	Issuer    string       `yaml:"issuer" env-default:"warehouse-control"`
+	Audience  string       `yaml:"audience" env-default:"warehouse-control"`
+	ActiveKey string       `yaml:"active_key" env-required:"true"`
+	Keys      []SigningKey `yaml:"keys"`
+}
+
+type SigningKey struct {
+	ID        string `yaml:"id"`
+	Algorithm string `yaml:"algorithm"` // HS256, RS256 или EdDSA
+	// HS256: секрет читается из файла или переменной окружения
+	SecretFile string `yaml:"secret_file"`
+	SecretEnv  string `yaml:"secret_env"`
+	// RS256/EdDSA: PEM файлы. Ключ без приватной части используется только для проверки
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+}
+
 func MustLoad() *Config {
 	path := fetchConfigPath()
 
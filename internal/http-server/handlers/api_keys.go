@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/http-server/handlers/middleware"
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/lib/tokens"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"WarehouseControl/internal/storage/postgres"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+// apiKeyPrefix - префикс, по которому ключи легко найти в логах и секретах
+const apiKeyPrefix = "wck_"
+
+var apiKeyNameRe = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+type APIKeysHandler struct {
+	apiKeyStorage postgres.APIKeyStorageI
+	validate      *validator.Validate
+	log           *slog.Logger
+}
+
+func NewAPIKeysHandler(apiKeyStorage postgres.APIKeyStorageI, log *slog.Logger) *APIKeysHandler {
+	validate := validator.New()
+	validate.RegisterValidation("apikeyname", func(fl validator.FieldLevel) bool {
+		return apiKeyNameRe.MatchString(fl.Field().String())
+	})
+
+	return &APIKeysHandler{
+		apiKeyStorage: apiKeyStorage,
+		validate:      validate,
+		log:           log,
+	}
+}
+
+type createAPIKeyRequest struct {
+	// Имя с префиксом apikey: попадает в item_history.changed_by, поэтому вместе
+	// с префиксом не длиннее username
+	Name      string          `json:"name" validate:"required,min=3,max=43,apikeyname"`
+	Role      models.UserRole `json:"role" validate:"required,oneof=admin manager viewer"`
+	ExpiresAt *time.Time      `json:"expires_at"`
+}
+
+type createAPIKeyResponse struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+func (h *APIKeysHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.api_keys.ListAPIKeys"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	keys, err := h.apiKeyStorage.ListAPIKeys(r.Context())
+	if err != nil {
+		log.Error("failed to get api keys", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get api keys"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data []*models.APIKey `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     keys,
+	})
+}
+
+// CreateAPIKey выпускает ключ. Сам ключ возвращается только в этом ответе,
+// в базе хранится его хеш
+func (h *APIKeysHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.api_keys.CreateAPIKey"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn("invalid request body", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		log.Warn("api key expiry in the past")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("expires_at must be in the future"))
+		return
+	}
+
+	secret, err := tokens.Generate()
+	if err != nil {
+		log.Error("failed to generate api key", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+	rawKey := apiKeyPrefix + secret
+
+	key := &models.APIKey{
+		Name:      req.Name,
+		Prefix:    rawKey[:len(apiKeyPrefix)+8],
+		KeyHash:   tokens.Hash(rawKey),
+		Role:      req.Role,
+		CreatedBy: claims.Username,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	if err = h.apiKeyStorage.CreateAPIKey(r.Context(), key); err != nil {
+		if errors.Is(err, storage.ErrAPIKeyExists) {
+			log.Warn("api key already exists", slog.String("name", req.Name))
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(response.Error("api key with this name already exists"))
+			return
+		}
+		log.Error("failed to create api key", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to create api key"))
+		return
+	}
+
+	log.Info("api key created",
+		slog.String("name", key.Name),
+		slog.String("role", string(key.Role)),
+		slog.String("created_by", claims.Username),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data createAPIKeyResponse `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     createAPIKeyResponse{APIKey: key, Key: rawKey},
+	})
+}
+
+func (h *APIKeysHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.api_keys.RevokeAPIKey"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Warn("invalid api key id", slog.String("id", idStr))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid api key id"))
+		return
+	}
+
+	if err = h.apiKeyStorage.RevokeAPIKey(r.Context(), id); err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Warn("api key not found", slog.Int("id", id))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("api key not found"))
+			return
+		}
+		log.Error("failed to revoke api key", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to revoke api key"))
+		return
+	}
+
+	log.Info("api key revoked", slog.Int("id", id))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
@@ -1,29 +1,70 @@
 package handlers
 
 import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/http-server/handlers/middleware"
 	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/lib/jwtkeys"
+	"WarehouseControl/internal/lib/passwords"
+	"WarehouseControl/internal/lib/tokens"
 	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
 	"WarehouseControl/internal/storage/postgres"
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthHandler struct {
-	userStorage postgres.UserStorageI
-	jwtSecret   string
-	log         *slog.Logger
+	userStorage     postgres.UserStorageI
+	tokenStorage    postgres.TokenStorageI
+	keys            *jwtkeys.KeySet
+	passwords       *passwords.Hasher
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	sessions        *middleware.SessionCookies
+	throttle        *loginThrottle
+	twoFactor       postgres.TwoFactorStorageI
+	twoFactorCfg    config.TwoFactor
+	authEvents      postgres.AuthEventStorageI
+	log             *slog.Logger
 }
 
-func NewAuthHandler(userStorage postgres.UserStorageI, jwtSecret string, log *slog.Logger) *AuthHandler {
+func NewAuthHandler(
+	userStorage postgres.UserStorageI,
+	tokenStorage postgres.TokenStorageI,
+	keys *jwtkeys.KeySet,
+	hasher *passwords.Hasher,
+	accessTokenTTL time.Duration,
+	refreshTokenTTL time.Duration,
+	sessions *middleware.SessionCookies,
+	loginAttempts postgres.LoginAttemptStorageI,
+	throttleCfg config.LoginThrottle,
+	twoFactor postgres.TwoFactorStorageI,
+	twoFactorCfg config.TwoFactor,
+	authEvents postgres.AuthEventStorageI,
+	log *slog.Logger,
+) *AuthHandler {
 	return &AuthHandler{
-		userStorage: userStorage,
-		jwtSecret:   jwtSecret,
-		log:         log,
+		userStorage:     userStorage,
+		tokenStorage:    tokenStorage,
+		keys:            keys,
+		passwords:       hasher,
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
+		sessions:        sessions,
+		throttle:        &loginThrottle{storage: loginAttempts, cfg: throttleCfg},
+		twoFactor:       twoFactor,
+		twoFactorCfg:    twoFactorCfg,
+		authEvents:      authEvents,
+		log:             log,
 	}
 }
 
@@ -33,7 +74,22 @@ type loginRequest struct {
 }
 
 type loginResponse struct {
-	Token string `json:"token"`
+	Token        string          `json:"token,omitempty"`
+	RefreshToken string          `json:"refresh_token,omitempty"`
+	ExpiresIn    int             `json:"expires_in"`
+	Role         models.UserRole `json:"role"`
+	// В режиме cookie токены не попадают в тело, клиент получает только CSRF токен
+	CSRFToken string `json:"csrf_token,omitempty"`
+	// Коды восстановления возвращаются один раз - при регистрации TOTP во время входа
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -52,45 +108,408 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := middleware.ClientIP(r)
+
+	wait, err := h.throttle.retryAfter(r.Context(), req.Username, ip)
+	if err != nil {
+		log.Error("failed to check login throttle", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+	if wait > 0 {
+		log.Warn("login throttled",
+			slog.String("username", req.Username),
+			slog.String("ip", ip),
+			slog.Duration("retry_after", wait),
+		)
+		h.event(r, log, models.AuthEventLogin, models.AuthOutcomeBlocked, req.Username, nil, "throttled")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(response.Error("too many failed login attempts, try again later"))
+		return
+	}
+
 	user, err := h.userStorage.GetUserByUsername(r.Context(), req.Username)
 	if err != nil {
 		log.Warn("user not found", slog.String("username", req.Username))
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(response.Error("invalid credentials"))
+		h.loginFailed(w, r, log, req.Username, ip, nil, "unknown_user")
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+	needsRehash, err := h.passwords.Verify(user.PasswordHash, req.Password)
+	if err != nil {
+		if !errors.Is(err, passwords.ErrMismatch) {
+			log.Warn("failed to verify password hash", slog.String("username", req.Username), slog.String("error", err.Error()))
+		}
 		log.Warn("invalid password", slog.String("username", req.Username))
+		h.loginFailed(w, r, log, req.Username, ip, user, "invalid_password")
+		return
+	}
+
+	if user.Disabled {
+		log.Warn("user is disabled", slog.String("username", req.Username))
+		h.loginFailed(w, r, log, req.Username, ip, user, "user_disabled")
+		return
+	}
+
+	// Пользователи OIDC не имеют локального пароля
+	if user.AuthProvider != models.AuthProviderLocal {
+		log.Warn("password login for external user", slog.String("username", req.Username))
+		h.loginFailed(w, r, log, req.Username, ip, user, "external_user")
+		return
+	}
+
+	if err = h.throttle.registerSuccess(r.Context(), user.Username); err != nil {
+		log.Error("failed to reset login attempts", slog.String("error", err.Error()))
+	}
+
+	if needsRehash {
+		h.rehashPassword(r.Context(), log, user, req.Password)
+	}
+
+	required, enrolled, err := h.secondFactorRequired(r.Context(), user)
+	if err != nil {
+		log.Error("failed to check second factor", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+	if required {
+		h.event(r, log, models.AuthEventLogin, models.AuthOutcomeChallenge, user.Username, user, "")
+		h.startMFAChallenge(w, r, log, user, enrolled)
+		return
+	}
+
+	resp, err := h.startSession(r.Context(), user)
+	if err != nil {
+		log.Error("failed to start session", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	h.event(r, log, models.AuthEventLogin, models.AuthOutcomeSuccess, user.Username, user, "")
+
+	h.writeTokens(w, log, resp, h.sessions.Use(r))
+}
+
+func (h *AuthHandler) loginFailed(w http.ResponseWriter, r *http.Request, log *slog.Logger, username, ip string, user *models.User, reason string) {
+	h.event(r, log, models.AuthEventLogin, models.AuthOutcomeFailure, username, user, reason)
+
+	locked, err := h.throttle.registerFailure(r.Context(), username, ip)
+	if err != nil {
+		log.Error("failed to register login failure", slog.String("error", err.Error()))
+	}
+	if locked {
+		log.Warn("login locked out", slog.String("username", username), slog.String("ip", ip))
+		h.event(r, log, models.AuthEventLockout, models.AuthOutcomeFailure, username, user, "")
+	}
+
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(response.Error("invalid credentials"))
+}
+
+// Refresh обменивает refresh токен на новую пару токенов. Предъявленный
+// refresh токен отзывается - каждый токен можно использовать только один раз.
+// Токен из cookie сессии принимается только с CSRF токеном, новая пара
+// возвращается тем же способом, которым был передан старый токен
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.auth.Refresh"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	var req refreshRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Warn("invalid request body", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response.Error("invalid request body"))
+			return
+		}
+	}
+
+	fromCookie := false
+	if req.RefreshToken == "" {
+		req.RefreshToken = h.sessions.RefreshToken(r)
+		fromCookie = req.RefreshToken != ""
+	}
+	if req.RefreshToken == "" {
+		log.Warn("refresh token is missing")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+	if fromCookie && !h.sessions.CheckCSRF(r) {
+		log.Warn("csrf token is missing or invalid")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(response.Error("invalid csrf token"))
+		return
+	}
+
+	refreshToken, err := tokens.Generate()
+	if err != nil {
+		log.Error("failed to generate refresh token", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	session := h.newSession(refreshToken)
+	err = h.tokenStorage.RotateRefreshToken(r.Context(), tokens.Hash(req.RefreshToken), session)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrTokenReused):
+			log.Warn("refresh token reuse detected, all user sessions revoked")
+			h.event(r, log, models.AuthEventRefresh, models.AuthOutcomeFailure, "", h.userByID(r, session.UserID), "reuse_detected")
+		case errors.Is(err, storage.ErrTokenNotFound), errors.Is(err, storage.ErrTokenExpired):
+			log.Warn("invalid refresh token", slog.String("error", err.Error()))
+			h.event(r, log, models.AuthEventRefresh, models.AuthOutcomeFailure, "", nil, "invalid_token")
+		default:
+			log.Error("failed to rotate refresh token", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response.Error("internal server error"))
+			return
+		}
+		if fromCookie {
+			h.sessions.Clear(w)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(response.Error("invalid refresh token"))
+		return
+	}
+
+	user, err := h.userStorage.GetUserByID(r.Context(), session.UserID)
+	if err != nil || user.Disabled {
+		log.Warn("refresh for missing or disabled user", slog.Int("user_id", session.UserID))
+		h.event(r, log, models.AuthEventRefresh, models.AuthOutcomeFailure, "", user, "user_disabled")
+		if _, err = h.tokenStorage.RevokeUserSessions(r.Context(), session.UserID); err != nil {
+			log.Error("failed to revoke sessions", slog.String("error", err.Error()))
+		}
+		if fromCookie {
+			h.sessions.Clear(w)
+		}
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(response.Error("invalid credentials"))
+		json.NewEncoder(w).Encode(response.Error("invalid refresh token"))
 		return
 	}
 
+	resp, err := h.signTokens(user, session, refreshToken)
+	if err != nil {
+		log.Error("failed to sign token", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	h.event(r, log, models.AuthEventRefresh, models.AuthOutcomeSuccess, user.Username, user, "")
+
+	h.writeTokens(w, log, resp, fromCookie)
+}
+
+// Logout отзывает текущий access токен и, если передан в теле или cookie,
+// связанный refresh токен. В режиме cookie cookie сессии удаляются
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.auth.Logout"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	var req logoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Warn("invalid request body", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response.Error("invalid request body"))
+			return
+		}
+	}
+
+	if req.RefreshToken == "" {
+		req.RefreshToken = h.sessions.RefreshToken(r)
+	}
+
+	if req.RefreshToken != "" {
+		user, err := h.userStorage.GetUserByUsername(r.Context(), claims.Username)
+		if err == nil {
+			err = h.tokenStorage.RevokeRefreshToken(r.Context(), tokens.Hash(req.RefreshToken), user.ID)
+		}
+		if err != nil && !errors.Is(err, storage.ErrTokenNotFound) {
+			log.Error("failed to revoke refresh token", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response.Error("internal server error"))
+			return
+		}
+	}
+
+	if err := h.tokenStorage.RevokeAccessToken(r.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+		log.Error("failed to revoke access token", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	log.Info("user logged out", slog.String("username", claims.Username))
+	h.event(r, log, models.AuthEventLogout, models.AuthOutcomeSuccess, claims.Username, nil, "")
+
+	if h.sessions.Enabled() {
+		h.sessions.Clear(w)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
+
+// JWKS публикует публичные ключи подписи для других внутренних сервисов
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.keys.JWKS())
+}
+
+// CleanupExpiredTokens периодически удаляет истекшие записи о токенах до отмены ctx
+func (h *AuthHandler) CleanupExpiredTokens(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.tokenStorage.DeleteExpiredTokens(ctx); err != nil {
+				h.log.Error("failed to delete expired tokens", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// newSession готовит запись о сессии: jti нового access токена и хеш refresh токена
+func (h *AuthHandler) newSession(refreshToken string) *models.RefreshToken {
+	now := time.Now()
+	return &models.RefreshToken{
+		TokenHash:       tokens.Hash(refreshToken),
+		AccessJTI:       tokens.ID(),
+		AccessExpiresAt: now.Add(h.accessTokenTTL),
+		ExpiresAt:       now.Add(h.refreshTokenTTL),
+	}
+}
+
+// rehashPassword пересчитывает хеш, созданный устаревшим алгоритмом или параметрами.
+// Пароль не меняется, поэтому история паролей не пополняется. Ошибка не мешает входу
+func (h *AuthHandler) rehashPassword(ctx context.Context, log *slog.Logger, user *models.User, password string) {
+	hash, err := h.passwords.Hash(password)
+	if err == nil {
+		err = h.userStorage.UpdatePasswordHash(ctx, user.ID, hash)
+	}
+	if err != nil {
+		log.Error("failed to upgrade password hash", slog.String("username", user.Username), slog.String("error", err.Error()))
+		return
+	}
+
+	user.PasswordHash = hash
+	log.Info("password hash upgraded", slog.String("username", user.Username))
+}
+
+// event записывает событие в журнал auth_events. user может быть nil,
+// если пользователь не найден; тогда сохраняется только введенное имя
+func (h *AuthHandler) event(r *http.Request, log *slog.Logger, typ models.AuthEventType, outcome models.AuthOutcome, username string, user *models.User, reason string) {
+	event := &models.AuthEvent{
+		Type:     typ,
+		Outcome:  outcome,
+		Username: username,
+		Reason:   reason,
+	}
+	if user != nil {
+		event.UserID = &user.ID
+		if event.Username == "" {
+			event.Username = user.Username
+		}
+	}
+
+	middleware.RecordAuthEvent(r, h.authEvents, log, event)
+}
+
+// userByID нужен только для журнала, поэтому ошибка поиска не важна
+func (h *AuthHandler) userByID(r *http.Request, id int) *models.User {
+	user, err := h.userStorage.GetUserByID(r.Context(), id)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// startSession создает новую сессию пользователя и выпускает для нее пару токенов
+func (h *AuthHandler) startSession(ctx context.Context, user *models.User) (*loginResponse, error) {
+	refreshToken, err := tokens.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	session := h.newSession(refreshToken)
+	session.UserID = user.ID
+	if err = h.tokenStorage.CreateRefreshToken(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return h.signTokens(user, session, refreshToken)
+}
+
+func (h *AuthHandler) signTokens(user *models.User, session *models.RefreshToken, refreshToken string) (*loginResponse, error) {
 	// Создаем JWT токен
 	claims := &models.JWTClaims{
 		Username: user.Username,
 		Role:     user.Role,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        session.AccessJTI,
+			ExpiresAt: jwt.NewNumericDate(session.AccessExpiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(h.jwtSecret))
+	tokenString, err := h.keys.Sign(claims)
 	if err != nil {
-		log.Error("failed to sign token", slog.String("error", err.Error()))
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(response.Error("internal server error"))
-		return
+		return nil, err
+	}
+
+	return &loginResponse{
+		Token:        tokenString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.accessTokenTTL.Seconds()),
+		Role:         user.Role,
+	}, nil
+}
+
+// writeTokens отдает токены в теле ответа или, если cookies, в HttpOnly cookie сессии
+func (h *AuthHandler) writeTokens(w http.ResponseWriter, log *slog.Logger, resp *loginResponse, cookies bool) {
+	if cookies {
+		csrfToken, err := h.sessions.Set(w, resp.Token, h.accessTokenTTL, resp.RefreshToken, h.refreshTokenTTL)
+		if err != nil {
+			log.Error("failed to set session cookies", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response.Error("internal server error"))
+			return
+		}
+		resp.Token, resp.RefreshToken, resp.CSRFToken = "", "", csrfToken
 	}
 
-	resp := loginResponse{Token: tokenString}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(struct {
 		response.Response
-		Data loginResponse `json:"data,omitempty"`
+		Data *loginResponse `json:"data,omitempty"`
 	}{
 		Response: response.Response{Status: response.StatusOK},
 		Data:     resp,
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage/postgres"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultAuthEventsLimit = 100
+	maxAuthEventsLimit     = 1000
+)
+
+type AuthEventsHandler struct {
+	authEvents postgres.AuthEventStorageI
+	log        *slog.Logger
+}
+
+func NewAuthEventsHandler(authEvents postgres.AuthEventStorageI, log *slog.Logger) *AuthEventsHandler {
+	return &AuthEventsHandler{
+		authEvents: authEvents,
+		log:        log,
+	}
+}
+
+// ListAuthEvents возвращает журнал событий аутентификации, новые первыми.
+// Фильтры: username, type, outcome, ip, from/to (RFC 3339), limit, offset
+func (h *AuthEventsHandler) ListAuthEvents(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.auth_events.ListAuthEvents"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	filter, err := parseAuthEventFilter(r)
+	if err != nil {
+		log.Warn("invalid auth events filter", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	events, err := h.authEvents.ListAuthEvents(r.Context(), filter)
+	if err != nil {
+		log.Error("failed to get auth events", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get auth events"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data []*models.AuthEvent `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     events,
+	})
+}
+
+func parseAuthEventFilter(r *http.Request) (models.AuthEventFilter, error) {
+	q := r.URL.Query()
+
+	filter := models.AuthEventFilter{
+		Username: q.Get("username"),
+		Type:     models.AuthEventType(q.Get("type")),
+		Outcome:  models.AuthOutcome(q.Get("outcome")),
+		IP:       q.Get("ip"),
+		Limit:    defaultAuthEventsLimit,
+	}
+
+	if filter.Type != "" && !filter.Type.IsValid() {
+		return filter, errors.New("invalid event type")
+	}
+	if filter.Outcome != "" && !filter.Outcome.IsValid() {
+		return filter, errors.New("invalid outcome")
+	}
+
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid '%s' time, expected RFC 3339", name)
+		}
+		*dst = &t
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuthEventsLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxAuthEventsLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return filter, errors.New("offset must be a non-negative integer")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"WarehouseControl/internal/storage/postgres"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type CategoriesHandler struct {
+	categoryStorage postgres.CategoryStorageI
+	validate        *validator.Validate
+	log             *slog.Logger
+}
+
+func NewCategoriesHandler(categoryStorage postgres.CategoryStorageI, log *slog.Logger) *CategoriesHandler {
+	return &CategoriesHandler{
+		categoryStorage: categoryStorage,
+		validate:        validator.New(),
+		log:             log,
+	}
+}
+
+type createCategoryRequest struct {
+	ParentID *int   `json:"parent_id"`
+	Name     string `json:"name" validate:"required,max=100,excludesall=/"`
+}
+
+type renameCategoryRequest struct {
+	Name string `json:"name" validate:"required,max=100,excludesall=/"`
+}
+
+// moveCategoryRequest - parent_id null переносит категорию в корень
+type moveCategoryRequest struct {
+	ParentID *int `json:"parent_id"`
+}
+
+// ListCategories возвращает дерево категорий
+func (h *CategoriesHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.categories.ListCategories"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	categories, err := h.categoryStorage.ListCategories(r.Context())
+	if err != nil {
+		log.Error("failed to get categories", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get categories"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data []*models.Category `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     models.CategoryTree(categories),
+	})
+}
+
+func (h *CategoriesHandler) GetCategory(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.categories.GetCategory"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid category id")
+	if !ok {
+		return
+	}
+
+	category, err := h.categoryStorage.GetCategoryByID(r.Context(), id)
+	if err != nil {
+		h.writeError(w, log, err, "failed to get category")
+		return
+	}
+
+	h.writeCategory(w, http.StatusOK, category)
+}
+
+func (h *CategoriesHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.categories.CreateCategory"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	var req createCategoryRequest
+	if !h.decode(w, r, log, &req) {
+		return
+	}
+
+	category := &models.Category{ParentID: req.ParentID, Name: strings.TrimSpace(req.Name)}
+	if err := h.categoryStorage.CreateCategory(r.Context(), category); err != nil {
+		h.writeError(w, log, err, "failed to create category")
+		return
+	}
+
+	log.Info("category created", slog.Int("id", category.ID), slog.String("path", category.Path))
+
+	h.writeCategory(w, http.StatusCreated, category)
+}
+
+// RenameCategory меняет название категории, место в дереве не меняется
+func (h *CategoriesHandler) RenameCategory(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.categories.RenameCategory"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid category id")
+	if !ok {
+		return
+	}
+
+	var req renameCategoryRequest
+	if !h.decode(w, r, log, &req) {
+		return
+	}
+
+	category, err := h.categoryStorage.RenameCategory(r.Context(), id, strings.TrimSpace(req.Name))
+	if err != nil {
+		h.writeError(w, log, err, "failed to rename category")
+		return
+	}
+
+	log.Info("category renamed", slog.Int("id", id), slog.String("path", category.Path))
+
+	h.writeCategory(w, http.StatusOK, category)
+}
+
+// MoveCategory переносит категорию вместе с подкатегориями и товарами в другую категорию
+func (h *CategoriesHandler) MoveCategory(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.categories.MoveCategory"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid category id")
+	if !ok {
+		return
+	}
+
+	var req moveCategoryRequest
+	if !h.decode(w, r, log, &req) {
+		return
+	}
+
+	category, err := h.categoryStorage.MoveCategory(r.Context(), id, req.ParentID)
+	if err != nil {
+		h.writeError(w, log, err, "failed to move category")
+		return
+	}
+
+	log.Info("category moved", slog.Int("id", id), slog.String("path", category.Path))
+
+	h.writeCategory(w, http.StatusOK, category)
+}
+
+func (h *CategoriesHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.categories.DeleteCategory"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid category id")
+	if !ok {
+		return
+	}
+
+	if err := h.categoryStorage.DeleteCategory(r.Context(), id); err != nil {
+		h.writeError(w, log, err, "failed to delete category")
+		return
+	}
+
+	log.Info("category deleted", slog.Int("id", id))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
+
+func (h *CategoriesHandler) writeCategory(w http.ResponseWriter, status int, category *models.Category) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.Category `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     category,
+	})
+}
+
+func (h *CategoriesHandler) decode(w http.ResponseWriter, r *http.Request, log *slog.Logger, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Warn("invalid request body", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return false
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return false
+	}
+
+	return true
+}
+
+// writeError переводит ошибки storage категорий в коды ответа
+func (h *CategoriesHandler) writeError(w http.ResponseWriter, log *slog.Logger, err error, msg string) {
+	var status int
+	switch {
+	case errors.Is(err, storage.ErrCategoryNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrCategoryExists), errors.Is(err, storage.ErrCategoryInUse),
+		errors.Is(err, storage.ErrCategoryCycle):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrInvalidCategoryParent):
+		status = http.StatusBadRequest
+	default:
+		log.Error(msg, slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error(msg))
+		return
+	}
+
+	log.Warn(msg, slog.String("error", err.Error()))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response.Error(err.Error()))
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/lib/export"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var itemExportHeader = []string{"id", "sku", "name", "quantity", "unit", "barcodes", "category_id", "tags", "stock", "version", "created_at", "updated_at"}
+
+var historyExportHeader = []string{"id", "item_id", "action", "changed_by", "changed_at", "location_id", "old_values", "new_values"}
+
+type itemRecord struct {
+	*models.Item
+}
+
+// Cells - количество в базовой единице unit, штрихкоды через пробел, теги через запятую,
+// остатки как "склад/место: количество" через точку с запятой
+func (r itemRecord) Cells() []interface{} {
+	var category interface{}
+	if r.CategoryID != nil {
+		category = *r.CategoryID
+	}
+	stock := make([]string, len(r.Stock))
+	for i, st := range r.Stock {
+		stock[i] = fmt.Sprintf("%s/%s: %s", st.WarehouseCode, st.LocationCode, st.Quantity)
+	}
+	return []interface{}{r.ID, r.SKU, r.Name, r.Quantity.Float64(), r.Unit, strings.Join(r.Barcodes, " "), category, strings.Join(r.Tags, ", "),
+		strings.Join(stock, "; "), r.Version, r.CreatedAt, r.UpdatedAt}
+}
+
+type historyRecord struct {
+	*models.ItemHistory
+}
+
+// Cells - снимки old_values и new_values записываются JSON строкой
+func (r historyRecord) Cells() []interface{} {
+	var location interface{}
+	if r.LocationID != nil {
+		location = *r.LocationID
+	}
+	return []interface{}{r.ID, r.ItemID, string(r.Action), r.ChangedBy, r.ChangedAt, location,
+		jsonCell(r.OldValues), jsonCell(r.NewValues)}
+}
+
+func jsonCell(v models.JSONB) string {
+	if v == nil {
+		return ""
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// ExportItems выгружает товары файлом: GET /items/export?format=csv|xlsx|ndjson
+// или GET /items/export.xlsx. Фильтры и сортировка те же, что у GET /items,
+// limit и cursor не учитываются - выгружаются все товары под фильтрами
+func (h *ItemsHandler) ExportItems(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.items.ExportItems"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	format, err := exportFormat(r)
+	if err != nil {
+		log.Warn("invalid export format", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	query, err := parseItemQuery(r)
+	if err != nil {
+		log.Warn("invalid query", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	err = streamExport(w, log, format, "items", itemExportHeader, func(write func(export.Record) error) error {
+		return h.itemStorage.StreamItems(r.Context(), query, func(item *models.Item) error {
+			return write(itemRecord{item})
+		})
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidSort) {
+			log.Warn("invalid query", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response.Error(err.Error()))
+			return
+		}
+		log.Error("failed to export items", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to export items"))
+	}
+}
+
+// ExportHistory выгружает журнал изменений файлом: GET /history/export?format=...
+// с фильтрами GET /history. Записи идут в порядке появления
+func (h *HistoryHandler) ExportHistory(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.history.ExportHistory"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	format, err := exportFormat(r)
+	if err != nil {
+		log.Warn("invalid export format", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		log.Warn("invalid history filter", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	err = streamExport(w, log, format, "history", historyExportHeader, func(write func(export.Record) error) error {
+		return h.historyStorage.StreamHistory(r.Context(), filter, func(record *models.ItemHistory) error {
+			return write(historyRecord{record})
+		})
+	})
+	if err != nil {
+		log.Error("failed to export history", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to export history"))
+	}
+}
+
+// exportFormat берет формат из параметра format или расширения пути
+// (маршрут /items/export.{format}), по умолчанию csv
+func exportFormat(r *http.Request) (export.Format, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = chi.URLParam(r, "format")
+	}
+	if format == "" {
+		return export.FormatCSV, nil
+	}
+	return export.ParseFormat(format)
+}
+
+// streamExport пишет записи из stream в ответ по мере их чтения из базы. Заголовки
+// ответа отправляются с первой записью, поэтому ошибка до нее возвращается
+// и обрабатывается как обычно. Ошибка после начала выгрузки только записывается
+// в лог, а соединение обрывается, чтобы клиент не принял неполный файл за целый
+func streamExport(w http.ResponseWriter, log *slog.Logger, format export.Format, name string, header []string,
+	stream func(write func(export.Record) error) error) error {
+	var ew *export.Writer
+	rows := 0
+
+	start := func() error {
+		// Выгрузка может идти дольше общего таймаута записи сервера
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().Format("2006-01-02"), format))
+
+		var err error
+		ew, err = export.NewWriter(w, format, name, header)
+		return err
+	}
+
+	err := stream(func(rec export.Record) error {
+		if ew == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		rows++
+		return ew.Write(rec)
+	})
+	if err == nil && ew == nil {
+		err = start()
+	}
+	if err == nil {
+		err = ew.Close()
+	}
+
+	if err != nil && ew != nil {
+		log.Error("export interrupted", slog.Int("rows", rows), slog.String("error", err.Error()))
+		panic(http.ErrAbortHandler)
+	}
+	if err == nil {
+		log.Info("export finished", slog.String("format", string(format)), slog.Int("rows", rows))
+	}
+	return err
+}
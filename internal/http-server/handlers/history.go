@@ -5,6 +5,7 @@ import (
 	"WarehouseControl/internal/models"
 	"WarehouseControl/internal/storage/postgres"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -59,6 +60,8 @@ func (h *HistoryHandler) GetHistoryByItemID(w http.ResponseWriter, r *http.Reque
 	})
 }
 
+// GetAllHistory возвращает журнал изменений, новые записи первыми. Фильтры:
+// item_id, action, changed_by, from и to (RFC 3339, to не включается)
 func (h *HistoryHandler) GetAllHistory(w http.ResponseWriter, r *http.Request) {
 	const op = "handlers.history.GetAllHistory"
 
@@ -67,7 +70,15 @@ func (h *HistoryHandler) GetAllHistory(w http.ResponseWriter, r *http.Request) {
 		slog.String("request_id", r.Header.Get("X-Request-ID")),
 	)
 
-	history, err := h.historyStorage.GetAllHistory(r.Context())
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		log.Warn("invalid history filter", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	history, err := h.historyStorage.GetAllHistory(r.Context(), filter)
 	if err != nil {
 		log.Error("failed to get all history", slog.String("error", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -84,3 +95,29 @@ func (h *HistoryHandler) GetAllHistory(w http.ResponseWriter, r *http.Request) {
 		Data:     history,
 	})
 }
+
+func parseHistoryFilter(r *http.Request) (models.HistoryFilter, error) {
+	q := r.URL.Query()
+
+	filter := models.HistoryFilter{
+		Action:    models.HistoryAction(q.Get("action")),
+		ChangedBy: q.Get("changed_by"),
+	}
+
+	if filter.Action != "" && !filter.Action.IsValid() {
+		return filter, errors.New("invalid action")
+	}
+
+	var err error
+	if filter.ItemID, err = queryInt(q.Get("item_id"), "item_id"); err != nil {
+		return filter, err
+	}
+	if filter.From, err = queryTime(q.Get("from"), "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = queryTime(q.Get("to"), "to"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
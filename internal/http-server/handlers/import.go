@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/http-server/handlers/middleware"
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/lib/itemimport"
+	"WarehouseControl/internal/models"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxImportSize - файл импорта читается в память целиком
+const maxImportSize = 10 << 20
+
+// ImportItems загружает товары из CSV или XLSX в теле запроса. Формат задается
+// Content-Type или параметром format, сопоставление колонок - параметрами map.<поле>,
+// ключ сопоставления с товарами - match_by (sku или name), dry_run=true только проверяет
+func (h *ItemsHandler) ImportItems(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.items.ImportItems"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	opts, dryRun, err := parseImportOptions(r)
+	if err != nil {
+		log.Warn("invalid import options", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxImportSize+1))
+	if err != nil {
+		log.Warn("failed to read import file", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+	if len(data) > maxImportSize {
+		log.Warn("import file is too large")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		json.NewEncoder(w).Encode(response.Error("import file must not exceed 10 MB"))
+		return
+	}
+
+	rows, err := itemimport.Parse(data, opts)
+	if err != nil {
+		log.Warn("invalid import file", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	report, err := h.itemStorage.ImportItems(r.Context(), rows, models.ImportOptions{MatchBy: opts.MatchBy, DryRun: dryRun}, claims.Username)
+	if err != nil {
+		log.Error("failed to import items", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to import items"))
+		return
+	}
+
+	log.Info("items import processed",
+		slog.String("username", claims.Username),
+		slog.Bool("dry_run", dryRun),
+		slog.Bool("committed", report.Committed),
+		slog.Int("created", report.Created),
+		slog.Int("updated", report.Updated),
+		slog.Int("failed", report.Failed),
+	)
+
+	resp := response.OK()
+	w.Header().Set("Content-Type", "application/json")
+	if report.Failed > 0 {
+		// Импорт с ошибками не сохраняется целиком, отчет показывает строки для исправления
+		resp = response.Error("import has invalid rows, nothing was saved")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.ImportReport `json:"data"`
+	}{
+		Response: resp,
+		Data:     report,
+	})
+}
+
+func parseImportOptions(r *http.Request) (itemimport.Options, bool, error) {
+	q := r.URL.Query()
+	opts := itemimport.Options{
+		Format:  itemimport.Format(q.Get("format")),
+		Mapping: itemimport.Mapping{},
+		MatchBy: models.ImportMatch(q.Get("match_by")),
+	}
+
+	if opts.Format == "" {
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch contentType {
+		case itemimport.CSVContentType:
+			opts.Format = itemimport.FormatCSV
+		case itemimport.XLSXContentType:
+			opts.Format = itemimport.FormatXLSX
+		default:
+			return opts, false, errors.New("format must be set by Content-Type (text/csv or xlsx) or the format parameter")
+		}
+	}
+	if opts.Format != itemimport.FormatCSV && opts.Format != itemimport.FormatXLSX {
+		return opts, false, errors.New("format must be csv or xlsx")
+	}
+
+	if opts.MatchBy == "" {
+		opts.MatchBy = models.ImportMatchSKU
+	}
+	if !opts.MatchBy.IsValid() {
+		return opts, false, errors.New("match_by must be sku or name")
+	}
+
+	for key, values := range q {
+		if field, ok := strings.CutPrefix(key, "map."); ok {
+			opts.Mapping[field] = values[0]
+		}
+	}
+
+	dryRun := false
+	if v := q.Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			return opts, false, errors.New("dry_run must be true or false")
+		}
+	}
+
+	return opts, dryRun, nil
+}
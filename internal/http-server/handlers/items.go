@@ -3,31 +3,120 @@ package handlers
 import (
 	"WarehouseControl/internal/http-server/handlers/middleware"
 	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/lib/barcode"
+	"WarehouseControl/internal/lib/jsonpatch"
 	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
 	"WarehouseControl/internal/storage/postgres"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
 )
 
 type ItemsHandler struct {
 	itemStorage postgres.ItemStorageI
+	validate    *validator.Validate
 	log         *slog.Logger
 }
 
 func NewItemsHandler(itemStorage postgres.ItemStorageI, log *slog.Logger) *ItemsHandler {
+	validate := validator.New()
+	validate.RegisterValidation("sku", func(fl validator.FieldLevel) bool {
+		return models.ValidSKU(fl.Field().String())
+	})
+	validate.RegisterValidation("barcode", func(fl validator.FieldLevel) bool {
+		return barcode.Valid(fl.Field().String())
+	})
+	validate.RegisterValidation("tag", func(fl validator.FieldLevel) bool {
+		return validTag(fl.Field().String())
+	})
+	validate.RegisterValidation("unit", func(fl validator.FieldLevel) bool {
+		return models.ValidUnit(fl.Field().String())
+	})
+
 	return &ItemsHandler{
 		itemStorage: itemStorage,
+		validate:    validate,
 		log:         log,
 	}
 }
 
+// LocationID - место хранения начального остатка, по умолчанию место приемки.
+// Quantity задается в базовой единице Unit, по умолчанию штуки
 type createItemRequest struct {
-	Name     string `json:"name" validate:"required"`
-	Quantity int    `json:"quantity"`
+	SKU           string           `json:"sku" validate:"required,max=64,sku"`
+	Name          string           `json:"name" validate:"required,max=100"`
+	Quantity      models.Quantity  `json:"quantity" validate:"min=0"`
+	Unit          string           `json:"unit" validate:"required,unit"`
+	UnitPrecision int              `json:"unit_precision" validate:"min=0,max=3"`
+	Units         models.ItemUnits `json:"units" validate:"max=10,unique=Unit,dive"`
+	Barcodes      []string         `json:"barcodes" validate:"max=20,unique,dive,barcode"`
+	CategoryID    *int             `json:"category_id"`
+	Tags          []string         `json:"tags" validate:"max=20,unique,dive,tag"`
+	LocationID    *int             `json:"location_id"`
+}
+
+// normalizeTags приводит теги к нижнему регистру и сортирует, чтобы теги
+// сравнивались без учета регистра и порядок не давал лишних записей в истории
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, len(tags))
+	for i, tag := range tags {
+		normalized[i] = strings.ToLower(strings.TrimSpace(tag))
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// validTag - тег от 1 до 50 символов без запятых и управляющих символов,
+// запятая разделяет теги в фильтре
+func validTag(tag string) bool {
+	if tag == "" || utf8.RuneCountInString(tag) > 50 {
+		return false
+	}
+	for _, r := range tag {
+		if r == ',' || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeItemUnits приводит коды единиц к нижнему регистру, без базовой единицы - штуки
+func normalizeItemUnits(unit string, units models.ItemUnits) (string, models.ItemUnits) {
+	unit = models.NormalizeUnit(unit)
+	if unit == "" {
+		unit = models.DefaultUnit
+	}
+	normalized := make(models.ItemUnits, len(units))
+	for i, u := range units {
+		normalized[i] = models.ItemUnit{Unit: models.NormalizeUnit(u.Unit), Factor: u.Factor}
+	}
+	return unit, normalized
+}
+
+// unitsError проверяет, что дополнительные единицы не повторяют базовую
+func unitsError(unit string, units models.ItemUnits) error {
+	for _, u := range units {
+		if u.Unit == unit {
+			return fmt.Errorf("unit %s is the base unit and cannot be an alternate unit", unit)
+		}
+	}
+	return nil
 }
 
 func (h *ItemsHandler) CreateItem(w http.ResponseWriter, r *http.Request) {
@@ -53,13 +142,43 @@ func (h *ItemsHandler) CreateItem(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response.Error("invalid request body"))
 		return
 	}
+	req.Tags = normalizeTags(req.Tags)
+	req.Unit, req.Units = normalizeItemUnits(req.Unit, req.Units)
+
+	if err := h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return
+	}
+	if err := unitsError(req.Unit, req.Units); err != nil {
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
 
 	item := &models.Item{
-		Name:     req.Name,
-		Quantity: req.Quantity,
+		SKU:           req.SKU,
+		Name:          req.Name,
+		Quantity:      req.Quantity,
+		Unit:          req.Unit,
+		UnitPrecision: req.UnitPrecision,
+		Units:         req.Units,
+		Barcodes:      req.Barcodes,
+		CategoryID:    req.CategoryID,
+		Tags:          req.Tags,
+	}
+	if req.LocationID != nil && req.Quantity > 0 {
+		item.Stock = []models.ItemStock{{LocationID: *req.LocationID, Quantity: req.Quantity}}
 	}
 
 	if err := h.itemStorage.CreateItem(r.Context(), item, claims.Username); err != nil {
+		if writeItemConflict(w, log, err) || writeStockError(w, log, err) || writeCategoryError(w, log, err) {
+			return
+		}
 		log.Error("failed to create item", slog.String("error", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(response.Error("failed to create item"))
@@ -67,6 +186,7 @@ func (h *ItemsHandler) CreateItem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", itemETag(item))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(struct {
 		response.Response
@@ -77,29 +197,239 @@ func (h *ItemsHandler) CreateItem(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// itemListMeta - сведения о странице списка товаров. Next - ссылка на следующую
+// страницу с теми же фильтрами, пустая на последней странице
+type itemListMeta struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+}
+
+// GetAllItems возвращает страницу товаров. Параметры query: name, quantity_min,
+// quantity_max, updated_from, updated_to (RFC 3339), category_id (с подкатегориями),
+// tag (все теги), sort, order (asc|desc), limit, cursor
 func (h *ItemsHandler) GetAllItems(w http.ResponseWriter, r *http.Request) {
-	const op = "handlers.items.GetAllItems"
+	h.listItems(w, r, "handlers.items.GetAllItems", false)
+}
 
+// GetTrash возвращает товары из корзины с теми же фильтрами и страницами, что GetAllItems
+func (h *ItemsHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	h.listItems(w, r, "handlers.items.GetTrash", true)
+}
+
+func (h *ItemsHandler) listItems(w http.ResponseWriter, r *http.Request, op string, deleted bool) {
 	log := h.log.With(
 		slog.String("op", op),
 		slog.String("request_id", r.Header.Get("X-Request-ID")),
 	)
 
-	items, err := h.itemStorage.GetAllItems(r.Context())
+	query, err := parseItemQuery(r)
+	query.Deleted = deleted
 	if err != nil {
+		log.Warn("invalid query", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	page, err := h.itemStorage.GetAllItems(r.Context(), query)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) || errors.Is(err, storage.ErrInvalidSort) {
+			log.Warn("invalid query", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response.Error(err.Error()))
+			return
+		}
 		log.Error("failed to get items", slog.String("error", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(response.Error("failed to get items"))
 		return
 	}
 
+	meta := itemListMeta{Total: page.Total, Limit: query.Limit, NextCursor: page.NextCursor}
+	if page.NextCursor != "" {
+		next := r.URL.Query()
+		next.Set("cursor", page.NextCursor)
+		meta.Next = r.URL.Path + "?" + next.Encode()
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(struct {
 		response.Response
-		Data []*models.Item `json:"data,omitempty"`
+		Data []*models.Item `json:"data"`
+		Meta itemListMeta   `json:"meta"`
 	}{
 		Response: response.Response{Status: response.StatusOK},
-		Data:     items,
+		Data:     page.Items,
+		Meta:     meta,
+	})
+}
+
+// parseItemQuery читает фильтры списка товаров. limit по умолчанию 50, не больше 1000
+func parseItemQuery(r *http.Request) (models.ItemQuery, error) {
+	q := r.URL.Query()
+	query := models.ItemQuery{
+		Name:   strings.TrimSpace(q.Get("name")),
+		Sort:   q.Get("sort"),
+		Cursor: q.Get("cursor"),
+		Limit:  50,
+	}
+
+	if query.Sort != "" && !models.IsItemSortField(query.Sort) {
+		return query, fmt.Errorf("sort must be one of: %s", strings.Join(models.ItemSortFields, ", "))
+	}
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, errors.New("order must be asc or desc")
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			return query, errors.New("limit must be between 1 and 1000")
+		}
+		query.Limit = n
+	}
+
+	var err error
+	if query.MinQuantity, err = queryQuantity(q.Get("quantity_min"), "quantity_min"); err != nil {
+		return query, err
+	}
+	if query.MaxQuantity, err = queryQuantity(q.Get("quantity_max"), "quantity_max"); err != nil {
+		return query, err
+	}
+	if query.UpdatedFrom, err = queryTime(q.Get("updated_from"), "updated_from"); err != nil {
+		return query, err
+	}
+	if query.UpdatedTo, err = queryTime(q.Get("updated_to"), "updated_to"); err != nil {
+		return query, err
+	}
+	if query.CategoryID, err = queryInt(q.Get("category_id"), "category_id"); err != nil {
+		return query, err
+	}
+
+	// tag можно повторять или перечислять через запятую
+	for _, v := range q["tag"] {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				query.Tags = append(query.Tags, tag)
+			}
+		}
+	}
+
+	return query, nil
+}
+
+func queryInt(v, name string) (*int, error) {
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+	return &n, nil
+}
+
+func queryQuantity(v, name string) (*models.Quantity, error) {
+	if v == "" {
+		return nil, nil
+	}
+	q, err := models.ParseQuantity(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number with at most %d decimal places", name, models.QuantityScale)
+	}
+	return &q, nil
+}
+
+func queryTime(v, name string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return &t, nil
+}
+
+// SearchItems ищет товары по названию, артикулу и штрихкоду: GET /items/search?q=...&limit=20.
+// Лучшие совпадения идут первыми, опечатки допускаются
+func (h *ItemsHandler) SearchItems(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.items.SearchItems"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	text := strings.TrimSpace(r.URL.Query().Get("q"))
+	if text == "" || len([]rune(text)) > 200 {
+		log.Warn("invalid search query", slog.String("q", text))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("q must be between 1 and 200 characters"))
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			log.Warn("invalid limit", slog.String("limit", v))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response.Error("limit must be between 1 and 100"))
+			return
+		}
+		limit = n
+	}
+
+	results, err := h.itemStorage.SearchItems(r.Context(), text, limit)
+	if err != nil {
+		log.Error("failed to search items", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to search items"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data []*models.ItemSearchResult `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     results,
+	})
+}
+
+// ListTags возвращает теги действующих товаров с числом товаров
+func (h *ItemsHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.items.ListTags"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	tags, err := h.itemStorage.ListTags(r.Context())
+	if err != nil {
+		log.Error("failed to get tags", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get tags"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data []models.Tag `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     tags,
 	})
 }
 
@@ -122,13 +452,20 @@ func (h *ItemsHandler) GetItemByID(w http.ResponseWriter, r *http.Request) {
 
 	item, err := h.itemStorage.GetItemByID(r.Context(), id)
 	if err != nil {
-		log.Warn("item not found", slog.Int("id", id))
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(response.Error("item not found"))
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Warn("item not found", slog.Int("id", id))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("item not found"))
+			return
+		}
+		log.Error("failed to get item", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get item"))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", itemETag(item))
 	json.NewEncoder(w).Encode(struct {
 		response.Response
 		Data *models.Item `json:"data,omitempty"`
@@ -138,9 +475,62 @@ func (h *ItemsHandler) GetItemByID(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetItemByCode находит товар по отсканированному штрихкоду или по артикулу
+func (h *ItemsHandler) GetItemByCode(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.items.GetItemByCode"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	code := chi.URLParam(r, "code")
+	if code == "" || len(code) > 64 {
+		log.Warn("invalid item code", slog.String("code", code))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid item code"))
+		return
+	}
+
+	item, err := h.itemStorage.GetItemByCode(r.Context(), code)
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Warn("item not found", slog.String("code", code))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("item not found"))
+			return
+		}
+		log.Error("failed to get item", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get item"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", itemETag(item))
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.Item `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     item,
+	})
+}
+
+// LocationID - место, к которому применяется изменение общего остатка
+// updateItemRequest заменяет товар целиком: без category_id товар остается
+// без категории, без tags - без тегов, без unit - в штуках без дополнительных единиц
 type updateItemRequest struct {
-	Name     string `json:"name" validate:"required"`
-	Quantity int    `json:"quantity"`
+	SKU           string           `json:"sku" validate:"required,max=64,sku"`
+	Name          string           `json:"name" validate:"required,max=100"`
+	Quantity      models.Quantity  `json:"quantity" validate:"min=0"`
+	Unit          string           `json:"unit" validate:"required,unit"`
+	UnitPrecision int              `json:"unit_precision" validate:"min=0,max=3"`
+	Units         models.ItemUnits `json:"units" validate:"max=10,unique=Unit,dive"`
+	Barcodes      []string         `json:"barcodes" validate:"max=20,unique,dive,barcode"`
+	CategoryID    *int             `json:"category_id"`
+	Tags          []string         `json:"tags" validate:"max=20,unique,dive,tag"`
+	LocationID    *int             `json:"location_id"`
 }
 
 func (h *ItemsHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
@@ -175,14 +565,64 @@ func (h *ItemsHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response.Error("invalid request body"))
 		return
 	}
+	req.Tags = normalizeTags(req.Tags)
+	req.Unit, req.Units = normalizeItemUnits(req.Unit, req.Units)
+
+	if err = h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return
+	}
+	if err = unitsError(req.Unit, req.Units); err != nil {
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	version, ok := parseIfMatch(w, r, log)
+	if !ok {
+		return
+	}
 
 	item := &models.Item{
-		ID:       id,
-		Name:     req.Name,
-		Quantity: req.Quantity,
+		ID:            id,
+		Version:       version,
+		SKU:           req.SKU,
+		Name:          req.Name,
+		Quantity:      req.Quantity,
+		Unit:          req.Unit,
+		UnitPrecision: req.UnitPrecision,
+		Units:         req.Units,
+		Barcodes:      req.Barcodes,
+		CategoryID:    req.CategoryID,
+		Tags:          req.Tags,
+	}
+	if req.LocationID != nil {
+		item.Stock = []models.ItemStock{{LocationID: *req.LocationID}}
 	}
 
 	if err = h.itemStorage.UpdateItem(r.Context(), item, claims.Username); err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Warn("item not found", slog.Int("id", id))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("item not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrVersionConflict) {
+			h.writeVersionConflict(w, r, log, id, err)
+			return
+		}
+		if errors.Is(err, storage.ErrVersionRequired) {
+			writeVersionRequired(w, log, id)
+			return
+		}
+		if writeItemConflict(w, log, err) || writeStockError(w, log, err) || writeCategoryError(w, log, err) {
+			return
+		}
 		log.Error("failed to update item", slog.String("error", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(response.Error("failed to update item"))
@@ -190,6 +630,318 @@ func (h *ItemsHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", itemETag(item))
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.Item `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     item,
+	})
+}
+
+// maxPatchSize - патч товара небольшой, большее тело считается ошибкой клиента
+const maxPatchSize = 64 << 10
+
+// PatchItem частично обновляет товар: application/merge-patch+json (RFC 7396)
+// или application/json-patch+json (RFC 6902) применяется к JSON товара.
+// Меняются только поля, которые патч действительно изменил
+func (h *ItemsHandler) PatchItem(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.items.PatchItem"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Warn("invalid item id", slog.String("id", idStr))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid item id"))
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != jsonpatch.MergePatchContentType && contentType != jsonpatch.JSONPatchContentType {
+		log.Warn("unsupported patch content type", slog.String("content_type", contentType))
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		json.NewEncoder(w).Encode(response.Error("content type must be " + jsonpatch.MergePatchContentType + " or " + jsonpatch.JSONPatchContentType))
+		return
+	}
+
+	version, ok := parseIfMatch(w, r, log)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPatchSize+1))
+	if err != nil || len(body) > maxPatchSize {
+		log.Warn("invalid request body")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+
+	current, err := h.itemStorage.GetItemByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Warn("item not found", slog.Int("id", id))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("item not found"))
+			return
+		}
+		log.Error("failed to get item", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get item"))
+		return
+	}
+
+	if version > 0 && version != current.Version {
+		h.writeVersionConflict(w, r, log, id, &storage.VersionConflictError{Expected: version, Actual: current.Version})
+		return
+	}
+
+	patched, err := applyItemPatch(current, contentType, body)
+	if err != nil {
+		log.Warn("patch rejected", slog.String("error", err.Error()))
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	patched.Tags = normalizeTags(patched.Tags)
+	patched.Unit, patched.Units = normalizeItemUnits(patched.Unit, patched.Units)
+	req := updateItemRequest{SKU: patched.SKU, Name: patched.Name, Quantity: patched.Quantity, Unit: patched.Unit,
+		UnitPrecision: patched.UnitPrecision, Units: patched.Units, Barcodes: patched.Barcodes,
+		CategoryID: patched.CategoryID, Tags: patched.Tags}
+	if err = h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid patched item", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return
+	}
+	if err = unitsError(patched.Unit, patched.Units); err != nil {
+		log.Warn("invalid patched item", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	patch := diffItem(current, patched)
+	if version == 0 && patch.Quantity != nil {
+		writeVersionRequired(w, log, id)
+		return
+	}
+
+	item := current
+	if !patch.IsEmpty() {
+		// Версия прочитанного товара защищает от изменений между чтением и записью
+		item, err = h.itemStorage.PatchItem(r.Context(), id, current.Version, patch, claims.Username)
+		if err != nil {
+			if errors.Is(err, storage.ErrItemNotFound) {
+				log.Warn("item not found", slog.Int("id", id))
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(response.Error("item not found"))
+				return
+			}
+			if errors.Is(err, storage.ErrVersionConflict) {
+				h.writeVersionConflict(w, r, log, id, err)
+				return
+			}
+			if writeItemConflict(w, log, err) || writeStockError(w, log, err) || writeCategoryError(w, log, err) {
+				return
+			}
+			log.Error("failed to patch item", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response.Error("failed to patch item"))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", itemETag(item))
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.Item `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     item,
+	})
+}
+
+// applyItemPatch применяет патч к JSON товара. Служебные поля и остатки по местам
+// патчем не меняются, неизвестные поля запрещены
+func applyItemPatch(current *models.Item, contentType string, patch []byte) (*models.Item, error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType == jsonpatch.MergePatchContentType {
+		doc, err = jsonpatch.MergePatch(doc, patch)
+	} else {
+		doc, err = jsonpatch.Apply(doc, patch)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var patched models.Item
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&patched); err != nil {
+		return nil, fmt.Errorf("patched document is not a valid item: %w", err)
+	}
+
+	if patched.ID != current.ID || patched.Version != current.Version ||
+		!patched.CreatedAt.Equal(current.CreatedAt) || !patched.UpdatedAt.Equal(current.UpdatedAt) ||
+		!reflect.DeepEqual(patched.Stock, current.Stock) || patched.DeletedAt != nil {
+		return nil, errors.New("fields id, version, created_at, updated_at, deleted_at and stock are read-only")
+	}
+
+	if patched.Barcodes == nil {
+		patched.Barcodes = []string{}
+	}
+	return &patched, nil
+}
+
+// diffItem оставляет в патче только поля, отличающиеся от текущего товара
+func diffItem(current, patched *models.Item) models.ItemPatch {
+	var patch models.ItemPatch
+	if patched.SKU != current.SKU {
+		patch.SKU = &patched.SKU
+	}
+	if patched.Name != current.Name {
+		patch.Name = &patched.Name
+	}
+	if patched.Quantity != current.Quantity {
+		patch.Quantity = &patched.Quantity
+	}
+	if patched.Unit != current.Unit {
+		patch.Unit = &patched.Unit
+	}
+	if patched.UnitPrecision != current.UnitPrecision {
+		patch.UnitPrecision = &patched.UnitPrecision
+	}
+	if !reflect.DeepEqual(patched.Units, current.Units) {
+		patch.Units = &patched.Units
+	}
+	if !reflect.DeepEqual(patched.Barcodes, current.Barcodes) {
+		patch.Barcodes = &patched.Barcodes
+	}
+	if !reflect.DeepEqual(patched.CategoryID, current.CategoryID) {
+		categoryID := 0
+		if patched.CategoryID != nil {
+			categoryID = *patched.CategoryID
+		}
+		patch.CategoryID = &categoryID
+	}
+	if !reflect.DeepEqual(patched.Tags, current.Tags) {
+		patch.Tags = &patched.Tags
+	}
+	return patch
+}
+
+// setStockRequest - quantity в единице unit, без unit - в базовой единице товара
+type setStockRequest struct {
+	Quantity models.Quantity `json:"quantity" validate:"min=0"`
+	Unit     string          `json:"unit"`
+}
+
+// SetItemStock устанавливает остаток товара в месте хранения, 0 убирает товар из места
+func (h *ItemsHandler) SetItemStock(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.items.SetItemStock"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Warn("invalid item id", slog.String("id", idStr))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid item id"))
+		return
+	}
+
+	locationStr := chi.URLParam(r, "locationID")
+	locationID, err := strconv.Atoi(locationStr)
+	if err != nil {
+		log.Warn("invalid location id", slog.String("location_id", locationStr))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid location id"))
+		return
+	}
+
+	var req setStockRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn("invalid request body", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+
+	if err = h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return
+	}
+
+	if err = h.itemStorage.SetStock(r.Context(), id, locationID, req.Quantity, models.NormalizeUnit(req.Unit), claims.Username); err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Warn("item not found", slog.Int("id", id))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("item not found"))
+			return
+		}
+		if writeStockError(w, log, err) {
+			return
+		}
+		log.Error("failed to set stock", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to set stock"))
+		return
+	}
+
+	item, err := h.itemStorage.GetItemByID(r.Context(), id)
+	if err != nil {
+		log.Error("failed to get item", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get item"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", itemETag(item))
 	json.NewEncoder(w).Encode(struct {
 		response.Response
 		Data *models.Item `json:"data,omitempty"`
@@ -224,7 +976,22 @@ func (h *ItemsHandler) DeleteItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = h.itemStorage.DeleteItem(r.Context(), id, claims.Username); err != nil {
+	version, ok := parseIfMatch(w, r, log)
+	if !ok {
+		return
+	}
+
+	if err = h.itemStorage.DeleteItem(r.Context(), id, version, claims.Username); err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Warn("item not found", slog.Int("id", id))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("item not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrVersionConflict) {
+			h.writeVersionConflict(w, r, log, id, err)
+			return
+		}
 		log.Error("failed to delete item", slog.String("error", err.Error()))
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(response.Error("failed to delete item"))
@@ -234,3 +1001,205 @@ func (h *ItemsHandler) DeleteItem(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response.OK())
 }
+
+// RestoreItem возвращает товар из корзины
+func (h *ItemsHandler) RestoreItem(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.items.RestoreItem"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	id, ok := urlID(w, r, log, "id", "invalid item id")
+	if !ok {
+		return
+	}
+
+	item, err := h.itemStorage.RestoreItem(r.Context(), id, claims.Username)
+	if err != nil {
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Warn("item not found in trash", slog.Int("id", id))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("item not found in trash"))
+			return
+		}
+		log.Error("failed to restore item", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to restore item"))
+		return
+	}
+
+	log.Info("item restored", slog.Int("id", id), slog.String("username", claims.Username))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", itemETag(item))
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.Item `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     item,
+	})
+}
+
+// PurgeItem окончательно удаляет товар из корзины. История товара сохраняется
+func (h *ItemsHandler) PurgeItem(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.items.PurgeItem"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	id, ok := urlID(w, r, log, "id", "invalid item id")
+	if !ok {
+		return
+	}
+
+	if err := h.itemStorage.PurgeItem(r.Context(), id, claims.Username); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrItemNotFound):
+			log.Warn("item not found", slog.Int("id", id))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("item not found"))
+		case errors.Is(err, storage.ErrItemNotDeleted):
+			log.Warn("item is not in trash", slog.Int("id", id))
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(response.Error(err.Error()))
+		default:
+			log.Error("failed to purge item", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response.Error("failed to purge item"))
+		}
+		return
+	}
+
+	log.Info("item purged", slog.Int("id", id), slog.String("username", claims.Username))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
+
+// writeItemConflict отвечает 409, если артикул или штрихкод уже занят другим товаром
+func writeItemConflict(w http.ResponseWriter, log *slog.Logger, err error) bool {
+	if !errors.Is(err, storage.ErrSKUExists) && !errors.Is(err, storage.ErrBarcodeExists) {
+		return false
+	}
+
+	log.Warn("item code conflict", slog.String("error", err.Error()))
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(response.Error(err.Error()))
+	return true
+}
+
+// writeCategoryError отвечает 400, если категория товара не существует
+func writeCategoryError(w http.ResponseWriter, log *slog.Logger, err error) bool {
+	if !errors.Is(err, storage.ErrCategoryNotFound) {
+		return false
+	}
+
+	log.Warn("category not found", slog.String("error", err.Error()))
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(response.Error(err.Error()))
+	return true
+}
+
+// writeStockError отвечает на ошибки остатков: неизвестное место, неизвестная единица
+// и количество не по точности единицы - 400, нехватка остатка в месте и смена единицы
+// товара с остатком - 409
+func writeStockError(w http.ResponseWriter, log *slog.Logger, err error) bool {
+	switch {
+	case errors.Is(err, storage.ErrLocationNotFound):
+		log.Warn("location not found", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, storage.ErrUnknownUnit), errors.Is(err, storage.ErrQuantityPrecision):
+		log.Warn("invalid quantity unit", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, storage.ErrInsufficientStock), errors.Is(err, storage.ErrNoDefaultLocation),
+		errors.Is(err, storage.ErrUnitInUse):
+		log.Warn("stock conflict", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusConflict)
+	default:
+		return false
+	}
+
+	json.NewEncoder(w).Encode(response.Error(err.Error()))
+	return true
+}
+
+// itemETag - ETag товара, меняется вместе с версией
+func itemETag(item *models.Item) string {
+	return fmt.Sprintf(`"%d"`, item.Version)
+}
+
+// parseIfMatch читает ожидаемую версию товара из обязательного заголовка If-Match.
+// "*" отключает проверку версии, но не разрешает менять quantity. Без заголовка
+// отвечает 428, при неверном формате 400
+func parseIfMatch(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int, bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		log.Warn("missing If-Match header")
+		w.WriteHeader(http.StatusPreconditionRequired)
+		json.NewEncoder(w).Encode(response.Error("If-Match header with item ETag is required"))
+		return 0, false
+	}
+	if value == "*" {
+		return 0, true
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(value, "W/"), `"`))
+	if err != nil || version < 1 {
+		log.Warn("invalid If-Match header", slog.String("if_match", value))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid If-Match header"))
+		return 0, false
+	}
+	return version, true
+}
+
+// writeVersionRequired отвечает 428 на изменение общего остатка с If-Match: *.
+// Остаток задается абсолютным значением, поэтому без версии он мог бы затереть
+// параллельные движения
+func writeVersionRequired(w http.ResponseWriter, log *slog.Logger, id int) {
+	log.Warn("quantity change without item version", slog.Int("id", id))
+	w.WriteHeader(http.StatusPreconditionRequired)
+	json.NewEncoder(w).Encode(response.Error(storage.ErrVersionRequired.Error()))
+}
+
+// writeVersionConflict отвечает 412 и возвращает текущее состояние товара,
+// чтобы клиент мог показать, с какой версией произошел конфликт
+func (h *ItemsHandler) writeVersionConflict(w http.ResponseWriter, r *http.Request, log *slog.Logger, id int, err error) {
+	log.Warn("item version conflict", slog.Int("id", id), slog.String("error", err.Error()))
+
+	current, getErr := h.itemStorage.GetItemByID(r.Context(), id)
+	if getErr == nil {
+		w.Header().Set("ETag", itemETag(current))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.Item `json:"data,omitempty"`
+	}{
+		Response: response.Error(err.Error()),
+		Data:     current,
+	})
+}
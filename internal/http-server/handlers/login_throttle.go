@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage/postgres"
+	"context"
+	"time"
+)
+
+// loginThrottle считает неудачные попытки входа по имени пользователя и по IP.
+// Состояние хранится в Postgres, поэтому переживает перезапуск и общее для всех инстансов
+type loginThrottle struct {
+	storage postgres.LoginAttemptStorageI
+	cfg     config.LoginThrottle
+}
+
+// retryAfter возвращает, сколько осталось ждать до следующей попытки входа
+func (t *loginThrottle) retryAfter(ctx context.Context, username, ip string) (time.Duration, error) {
+	var wait time.Duration
+
+	for _, k := range []struct {
+		scope models.AttemptScope
+		key   string
+	}{
+		{models.AttemptScopeUsername, username},
+		{models.AttemptScopeIP, ip},
+	} {
+		blockedUntil, err := t.storage.GetBlockedUntil(ctx, k.scope, k.key)
+		if err != nil {
+			return 0, err
+		}
+		if d := time.Until(blockedUntil); d > wait {
+			wait = d
+		}
+	}
+
+	return wait, nil
+}
+
+// registerFailure засчитывает неудачу и сообщает, заблокирован ли после нее вход
+func (t *loginThrottle) registerFailure(ctx context.Context, username, ip string) (bool, error) {
+	userLocked, err := t.fail(ctx, models.AttemptScopeUsername, username, t.cfg.MaxFailures)
+	if err != nil {
+		return false, err
+	}
+	ipLocked, err := t.fail(ctx, models.AttemptScopeIP, ip, t.cfg.IPMaxFailures)
+	if err != nil {
+		return false, err
+	}
+	return userLocked || ipLocked, nil
+}
+
+// registerSuccess сбрасывает счетчик пользователя. Счетчик IP не сбрасывается,
+// чтобы один удачный вход не обнулял перебор чужих учетных записей с того же адреса
+func (t *loginThrottle) registerSuccess(ctx context.Context, username string) error {
+	return t.storage.Reset(ctx, models.AttemptScopeUsername, username)
+}
+
+func (t *loginThrottle) fail(ctx context.Context, scope models.AttemptScope, key string, maxFailures int) (bool, error) {
+	failures, err := t.storage.RegisterFailure(ctx, scope, key, t.cfg.FailureWindow)
+	if err != nil {
+		return false, err
+	}
+
+	// Каждая неудача сверх порога продлевает блокировку и тоже считается блокировкой
+	return failures >= maxFailures, t.storage.Block(ctx, scope, key, t.delay(failures, maxFailures))
+}
+
+func (t *loginThrottle) delay(failures, maxFailures int) time.Duration {
+	if failures >= maxFailures {
+		return t.cfg.LockoutDuration
+	}
+
+	d := t.cfg.BaseDelay
+	for i := 1; i < failures && d < t.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > t.cfg.MaxDelay {
+		d = t.cfg.MaxDelay
+	}
+
+	return d
+}
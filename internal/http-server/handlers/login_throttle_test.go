@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/models"
+	"context"
+	"testing"
+	"time"
+)
+
+// memoryAttempts - счетчики неудач в памяти. Моменты блокировки возвращаются
+// в поясе dbZone, как lib/pq возвращает TIMESTAMPTZ в поясе сессии базы
+type memoryAttempts struct {
+	failures map[string]int
+	blocked  map[string]time.Time
+	dbZone   *time.Location
+}
+
+func newMemoryAttempts() *memoryAttempts {
+	return &memoryAttempts{failures: map[string]int{}, blocked: map[string]time.Time{}, dbZone: time.UTC}
+}
+
+func (m *memoryAttempts) GetBlockedUntil(ctx context.Context, scope models.AttemptScope, key string) (time.Time, error) {
+	return m.blocked[string(scope)+":"+key], nil
+}
+
+func (m *memoryAttempts) RegisterFailure(ctx context.Context, scope models.AttemptScope, key string, window time.Duration) (int, error) {
+	m.failures[string(scope)+":"+key]++
+	return m.failures[string(scope)+":"+key], nil
+}
+
+func (m *memoryAttempts) Block(ctx context.Context, scope models.AttemptScope, key string, delay time.Duration) error {
+	until := time.Now().In(m.dbZone).Add(delay)
+	if until.After(m.blocked[string(scope)+":"+key]) {
+		m.blocked[string(scope)+":"+key] = until
+	}
+	return nil
+}
+
+func (m *memoryAttempts) Reset(ctx context.Context, scope models.AttemptScope, key string) error {
+	delete(m.failures, string(scope)+":"+key)
+	return nil
+}
+
+var testThrottleConfig = config.LoginThrottle{
+	MaxFailures:     3,
+	IPMaxFailures:   100,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutDuration: 15 * time.Minute,
+	FailureWindow:   time.Hour,
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	throttle := &loginThrottle{storage: newMemoryAttempts(), cfg: testThrottleConfig}
+
+	tests := []struct {
+		failure int
+		locked  bool
+		wait    time.Duration
+	}{
+		{1, false, time.Second},
+		{2, false, 2 * time.Second},
+		{3, true, 15 * time.Minute},
+		{4, true, 15 * time.Minute},
+		{5, true, 15 * time.Minute},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		locked, err := throttle.registerFailure(ctx, "alice", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked != tt.locked {
+			t.Errorf("failure %d: locked = %v, want %v", tt.failure, locked, tt.locked)
+		}
+
+		wait, err := throttle.retryAfter(ctx, "alice", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait > tt.wait || wait < tt.wait-time.Second {
+			t.Errorf("failure %d: retry after %v, want about %v", tt.failure, wait, tt.wait)
+		}
+	}
+}
+
+// Блокировка не зависит от того, в каких поясах работают приложение и база
+func TestLoginThrottleTimeZones(t *testing.T) {
+	local := time.Local
+	defer func() { time.Local = local }()
+
+	tests := []struct {
+		name    string
+		appZone *time.Location
+		dbZone  *time.Location
+	}{
+		{"app ahead of db", time.FixedZone("UTC+7", 7*3600), time.UTC},
+		{"app behind db", time.FixedZone("UTC-5", -5*3600), time.FixedZone("UTC+3", 3*3600)},
+		{"same non-utc zone", time.FixedZone("UTC+3", 3*3600), time.FixedZone("UTC+3", 3*3600)},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			time.Local = tt.appZone
+			attempts := newMemoryAttempts()
+			attempts.dbZone = tt.dbZone
+			throttle := &loginThrottle{storage: attempts, cfg: testThrottleConfig}
+
+			for i := 0; i < testThrottleConfig.MaxFailures; i++ {
+				if _, err := throttle.registerFailure(ctx, "alice", "10.0.0.1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			wait, err := throttle.retryAfter(ctx, "alice", "10.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := testThrottleConfig.LockoutDuration; wait > want || wait < want-time.Second {
+				t.Fatalf("retry after %v, want about %v", wait, want)
+			}
+		})
+	}
+}
@@ -2,25 +2,65 @@ package middleware
 
 import (
 	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/lib/jwtkeys"
+	"WarehouseControl/internal/lib/tokens"
 	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strings"
-
-	"github.com/golang-jwt/jwt/v5"
+	"time"
 )
 
 type contextKey string
 
-const UserContextKey contextKey = "user"
+const (
+	UserContextKey   contextKey = "user"
+	APIKeyContextKey contextKey = "api_key"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// TokenRevocationChecker - список отозванных токенов по jti
+type TokenRevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// APIKeyVerifier ищет API ключ сервисной учетной записи по хешу
+type APIKeyVerifier interface {
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int) error
+}
 
-func AuthMiddleware(secret string, log *slog.Logger) func(http.Handler) http.Handler {
+// AuthMiddleware принимает Bearer JWT в заголовке Authorization, API ключ
+// в заголовке X-API-Key или, в режиме cookie, JWT из cookie сессии. Для cookie
+// изменяющие запросы должны нести CSRF токен. Отклоненные токены и ключи
+// записываются в журнал событий аутентификации
+func AuthMiddleware(keys *jwtkeys.KeySet, revocations TokenRevocationChecker, apiKeys APIKeyVerifier, events AuthEventRecorder, sessions *SessionCookies, log *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+				authenticateAPIKey(w, r, next, apiKeys, events, apiKey, log)
+				return
+			}
+
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
+				if tokenString := sessions.AccessToken(r); tokenString != "" {
+					if !sessions.CheckCSRF(r) {
+						log.Warn("csrf token is missing or invalid")
+						rejectToken(r, events, log, "", "csrf_mismatch")
+						w.WriteHeader(http.StatusForbidden)
+						json.NewEncoder(w).Encode(response.Error("invalid csrf token"))
+						return
+					}
+					authenticateJWT(w, r, next, keys, revocations, events, tokenString, log)
+					return
+				}
+
 				log.Warn("authorization header is missing")
 				w.WriteHeader(http.StatusUnauthorized)
 				json.NewEncoder(w).Encode(response.Error("missing authorization header"))
@@ -35,26 +75,133 @@ func AuthMiddleware(secret string, log *slog.Logger) func(http.Handler) http.Han
 				return
 			}
 
-			claims := &models.JWTClaims{}
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				return []byte(secret), nil
-			})
+			authenticateJWT(w, r, next, keys, revocations, events, tokenString, log)
+		})
+	}
+}
 
-			if err != nil || !token.Valid {
-				log.Warn("invalid token", slog.String("error", err.Error()))
-				w.WriteHeader(http.StatusUnauthorized)
-				json.NewEncoder(w).Encode(response.Error("invalid token"))
-				return
-			}
+func authenticateJWT(w http.ResponseWriter, r *http.Request, next http.Handler, keys *jwtkeys.KeySet, revocations TokenRevocationChecker, events AuthEventRecorder, tokenString string, log *slog.Logger) {
+	claims := &models.JWTClaims{}
+	token, err := keys.Parse(tokenString, claims)
 
-			// Сохраняем в контекст с правильным ключом
-			ctx := context.WithValue(r.Context(), UserContextKey, claims)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
+	if err != nil || !token.Valid {
+		// Подпись или claims не прошли проверку, поэтому имя в токене выбрал сам клиент:
+		// в журнал событий оно не попадает, только в лог с пометкой unverified
+		attrs := []any{slog.String("unverified_username", claims.Username)}
+		// Токен может оказаться невалидным и без ошибки разбора
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		log.Warn("invalid token", attrs...)
+		rejectToken(r, events, log, "", "invalid_token")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(response.Error("invalid token"))
+		return
+	}
+
+	if claims.ID == "" {
+		log.Warn("token has no jti", slog.String("username", claims.Username))
+		rejectToken(r, events, log, claims.Username, "missing_jti")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(response.Error("invalid token"))
+		return
+	}
+
+	revoked, err := revocations.IsTokenRevoked(r.Context(), claims.ID)
+	if err != nil {
+		log.Error("failed to check token revocation", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+	if revoked {
+		log.Warn("token is revoked", slog.String("username", claims.Username), slog.String("jti", claims.ID))
+		rejectToken(r, events, log, claims.Username, "revoked")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(response.Error("token is revoked"))
+		return
 	}
+
+	// Сохраняем в контекст с правильным ключом
+	ctx := context.WithValue(r.Context(), UserContextKey, claims)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, apiKeys APIKeyVerifier, events AuthEventRecorder, apiKey string, log *slog.Logger) {
+	key, err := apiKeys.GetAPIKeyByHash(r.Context(), tokens.Hash(apiKey))
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Warn("unknown api key")
+			rejectToken(r, events, log, "", "unknown_api_key")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(response.Error("invalid api key"))
+			return
+		}
+		log.Error("failed to get api key", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	if !key.IsActive(time.Now()) {
+		log.Warn("api key is revoked or expired", slog.String("api_key", key.Name))
+		rejectToken(r, events, log, key.Username(), "inactive_api_key")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(response.Error("invalid api key"))
+		return
+	}
+
+	if err = apiKeys.TouchAPIKey(r.Context(), key.ID); err != nil {
+		log.Error("failed to update api key usage", slog.String("error", err.Error()))
+	}
+
+	// Ключ действует от имени apikey:<name>: через app.username оно попадает
+	// в item_history.changed_by и не совпадает ни с одним пользователем
+	claims := &models.JWTClaims{
+		Username: key.Username(),
+		Role:     key.Role,
+	}
+
+	ctx := context.WithValue(r.Context(), UserContextKey, claims)
+	ctx = context.WithValue(ctx, APIKeyContextKey, key)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func rejectToken(r *http.Request, events AuthEventRecorder, log *slog.Logger, username, reason string) {
+	RecordAuthEvent(r, events, log, &models.AuthEvent{
+		Type:     models.AuthEventTokenRejected,
+		Outcome:  models.AuthOutcomeFailure,
+		Username: username,
+		Reason:   reason,
+	})
 }
 
 func GetUserFromContext(ctx context.Context) (*models.JWTClaims, bool) {
 	user, ok := ctx.Value(UserContextKey).(*models.JWTClaims)
 	return user, ok
 }
+
+// GetAPIKeyFromContext возвращает API ключ, если запрос аутентифицирован им, а не JWT
+func GetAPIKeyFromContext(ctx context.Context) (*models.APIKey, bool) {
+	key, ok := ctx.Value(APIKeyContextKey).(*models.APIKey)
+	return key, ok
+}
+
+// RequireUserSession отклоняет запросы, аутентифицированные API ключом,
+// для маршрутов, имеющих смысл только для пользовательской сессии
+func RequireUserSession(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key, ok := GetAPIKeyFromContext(r.Context()); ok {
+				log.Warn("api key used on user session route",
+					slog.String("api_key", key.Name),
+					slog.String("path", r.URL.Path),
+				)
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(response.Error("not available for api keys"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"WarehouseControl/internal/models"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// AuthEventRecorder - журнал событий аутентификации (таблица auth_events)
+type AuthEventRecorder interface {
+	RecordAuthEvent(ctx context.Context, event *models.AuthEvent) error
+}
+
+// RecordAuthEvent дополняет событие IP, User-Agent и request_id запроса и сохраняет его.
+// Ошибка записи только логируется: недоступность журнала не должна блокировать вход
+func RecordAuthEvent(r *http.Request, recorder AuthEventRecorder, log *slog.Logger, event *models.AuthEvent) {
+	event.Username = truncate(event.Username, 255)
+	event.IP = ClientIP(r)
+	event.UserAgent = truncate(r.UserAgent(), 512)
+	event.RequestID = chiMiddleware.GetReqID(r.Context())
+
+	if err := recorder.RecordAuthEvent(r.Context(), event); err != nil {
+		log.Error("failed to record auth event",
+			slog.String("type", string(event.Type)),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/lib/jwtkeys"
+	"WarehouseControl/internal/lib/logger/handlers/slogdiscard"
+	"WarehouseControl/internal/lib/tokens"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type memoryAPIKeys map[string]*models.APIKey
+
+func (m memoryAPIKeys) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	key, ok := m[keyHash]
+	if !ok {
+		return nil, storage.ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func (m memoryAPIKeys) TouchAPIKey(ctx context.Context, id int) error {
+	return nil
+}
+
+type memoryEvents []*models.AuthEvent
+
+func (m *memoryEvents) RecordAuthEvent(ctx context.Context, event *models.AuthEvent) error {
+	*m = append(*m, event)
+	return nil
+}
+
+// Ключ действует от имени apikey:<name>, даже если его имя совпадает с именем пользователя
+func TestAPIKeyUsername(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	apiKeys := memoryAPIKeys{
+		tokens.Hash("wck_active"):  {ID: 1, Name: "admin", Role: models.RoleManager},
+		tokens.Hash("wck_expired"): {ID: 2, Name: "erp-sync", Role: models.RoleManager, ExpiresAt: &expired},
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		status   int
+		username string
+		event    string
+	}{
+		{"active key", "wck_active", http.StatusOK, "apikey:admin", ""},
+		{"expired key", "wck_expired", http.StatusUnauthorized, "", "apikey:erp-sync"},
+		{"unknown key", "wck_unknown", http.StatusUnauthorized, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events memoryEvents
+			var username string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claims, _ := GetUserFromContext(r.Context())
+				username = claims.Username
+			})
+			handler := AuthMiddleware(nil, nil, apiKeys, &events, nil, slogdiscard.NewDiscardLogger())(next)
+
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			req.Header.Set(APIKeyHeader, tt.key)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if username != tt.username {
+				t.Fatalf("username = %q, want %q", username, tt.username)
+			}
+			if tt.status != http.StatusOK && (len(events) != 1 || events[0].Username != tt.event) {
+				t.Fatalf("events = %+v, want one rejection for %q", events, tt.event)
+			}
+		})
+	}
+}
+
+type notRevoked struct{}
+
+func (notRevoked) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return false, nil
+}
+
+func testKeySet(t *testing.T, env, secret string) *jwtkeys.KeySet {
+	t.Helper()
+
+	t.Setenv(env, secret)
+	keys, err := jwtkeys.Load(config.Signing{
+		Issuer:    "warehouse-control",
+		Audience:  "warehouse-control",
+		ActiveKey: "hs-1",
+		Keys:      []config.SigningKey{{ID: "hs-1", Algorithm: jwtkeys.AlgHS256, SecretEnv: env}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// Имя из непроверенного токена выбирает клиент, поэтому в журнал оно не пишется
+func TestInvalidTokenUsername(t *testing.T) {
+	keys := testKeySet(t, "AUTH_TEST_SECRET", "0123456789abcdef0123456789abcdef")
+	forger := testKeySet(t, "AUTH_TEST_FORGED_SECRET", "fedcba9876543210fedcba9876543210")
+
+	sign := func(keys *jwtkeys.KeySet, expiresAt time.Time) string {
+		token, err := keys.Sign(&models.JWTClaims{
+			Username: "admin",
+			Role:     models.RoleAdmin,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        "jti-1",
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"valid token", sign(keys, time.Now().Add(time.Minute)), http.StatusOK},
+		{"forged signature", sign(forger, time.Now().Add(time.Minute)), http.StatusUnauthorized},
+		{"expired", sign(keys, time.Now().Add(-time.Minute)), http.StatusUnauthorized},
+		{"malformed", "not-a-jwt", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events memoryEvents
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			handler := AuthMiddleware(keys, notRevoked{}, nil, &events, nil, slogdiscard.NewDiscardLogger())(next)
+
+			req := httptest.NewRequest(http.MethodGet, "/items", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK {
+				if len(events) != 0 {
+					t.Fatalf("events = %+v, want none", events)
+				}
+				return
+			}
+			if len(events) != 1 || events[0].Reason != "invalid_token" || events[0].Username != "" {
+				t.Fatalf("events = %+v, want invalid_token without username", events)
+			}
+		})
+	}
+}
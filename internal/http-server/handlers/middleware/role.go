@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/models"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+type Permission string
+
+const (
+	PermItemsRead     Permission = "items:read"
+	PermItemsCreate   Permission = "items:create"
+	PermItemsUpdate   Permission = "items:update"
+	PermItemsDelete   Permission = "items:delete"
+	PermHistoryRead   Permission = "history:read"
+	PermUsersManage   Permission = "users:manage"
+	PermAPIKeysManage Permission = "api_keys:manage"
+	PermAuditRead     Permission = "audit:read"
+	// PermWarehousesManage - создание и изменение складов и мест хранения
+	PermWarehousesManage Permission = "warehouses:manage"
+	// PermItemsPurge - окончательное удаление товаров из корзины
+	PermItemsPurge Permission = "items:purge"
+	// PermCategoriesManage - создание, переименование, перенос и удаление категорий
+	PermCategoriesManage Permission = "categories:manage"
+)
+
+// rolePermissions - матрица прав: viewer только читает,
+// manager дополнительно создает и редактирует товары и категории, admin еще и удаляет,
+// восстанавливает и очищает корзину, управляет пользователями, API ключами и складами, читает журнал входов
+var rolePermissions = map[models.UserRole][]Permission{
+	models.RoleViewer: {
+		PermItemsRead,
+		PermHistoryRead,
+	},
+	models.RoleManager: {
+		PermItemsRead,
+		PermHistoryRead,
+		PermItemsCreate,
+		PermItemsUpdate,
+		PermCategoriesManage,
+	},
+	models.RoleAdmin: {
+		PermItemsRead,
+		PermHistoryRead,
+		PermItemsCreate,
+		PermItemsUpdate,
+		PermItemsDelete,
+		PermUsersManage,
+		PermAPIKeysManage,
+		PermAuditRead,
+		PermWarehousesManage,
+		PermItemsPurge,
+		PermCategoriesManage,
+	},
+}
+
+func HasPermission(role models.UserRole, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission должен подключаться после AuthMiddleware
+func RequirePermission(perm Permission, log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := GetUserFromContext(r.Context())
+			if !ok {
+				log.Error("user not found in context",
+					slog.String("permission", string(perm)),
+					slog.String("request_id", chiMiddleware.GetReqID(r.Context())),
+				)
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(response.Error("unauthorized"))
+				return
+			}
+
+			if !HasPermission(claims.Role, perm) {
+				log.Warn("access denied",
+					slog.String("username", claims.Username),
+					slog.String("role", string(claims.Role)),
+					slog.String("permission", string(perm)),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", chiMiddleware.GetReqID(r.Context())),
+				)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(response.Error("access denied: role " + string(claims.Role) + " lacks permission " + string(perm)))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/lib/tokens"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	SessionModeBearer = "bearer"
+	SessionModeCookie = "cookie"
+
+	AccessCookie  = "wc_access"
+	RefreshCookie = "wc_refresh"
+	CSRFCookie    = "wc_csrf"
+	CSRFHeader    = "X-CSRF-Token"
+
+	// AuthModeHeader: "bearer" в режиме cookie просит вернуть токены в теле
+	// ответа, как раньше. Нужен API клиентам, которые входят по паролю
+	AuthModeHeader = "X-Auth-Mode"
+
+	// refresh cookie нужна только /auth/refresh и /auth/logout
+	refreshCookiePath = "/auth"
+)
+
+// SessionCookies выдает и читает cookie сессии веб-интерфейса
+type SessionCookies struct {
+	cfg      config.Session
+	sameSite http.SameSite
+}
+
+func NewSessionCookies(cfg config.Session) (*SessionCookies, error) {
+	if cfg.Mode != SessionModeBearer && cfg.Mode != SessionModeCookie {
+		return nil, fmt.Errorf("unsupported session mode %q", cfg.Mode)
+	}
+
+	c := &SessionCookies{cfg: cfg}
+	switch cfg.SameSite {
+	case "strict":
+		c.sameSite = http.SameSiteStrictMode
+	case "lax":
+		c.sameSite = http.SameSiteLaxMode
+	default:
+		return nil, fmt.Errorf("unsupported same_site %q", cfg.SameSite)
+	}
+
+	return c, nil
+}
+
+// Enabled - включен ли режим cookie
+func (c *SessionCookies) Enabled() bool {
+	return c != nil && c.cfg.Mode == SessionModeCookie
+}
+
+// Use сообщает, передавать ли токены этого запроса через cookie
+func (c *SessionCookies) Use(r *http.Request) bool {
+	return c.Enabled() && r.Header.Get(AuthModeHeader) != SessionModeBearer
+}
+
+// Set устанавливает cookie с токенами и новый CSRF токен и возвращает его
+func (c *SessionCookies) Set(w http.ResponseWriter, accessToken string, accessTTL time.Duration, refreshToken string, refreshTTL time.Duration) (string, error) {
+	csrfToken, err := tokens.Generate()
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, c.cookie(AccessCookie, accessToken, "/", accessTTL, true))
+	http.SetCookie(w, c.cookie(RefreshCookie, refreshToken, refreshCookiePath, refreshTTL, true))
+	// CSRF токен читает JavaScript, чтобы повторить его в заголовке
+	http.SetCookie(w, c.cookie(CSRFCookie, csrfToken, "/", refreshTTL, false))
+
+	return csrfToken, nil
+}
+
+// Clear удаляет cookie сессии
+func (c *SessionCookies) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, c.cookie(AccessCookie, "", "/", -1, true))
+	http.SetCookie(w, c.cookie(RefreshCookie, "", refreshCookiePath, -1, true))
+	http.SetCookie(w, c.cookie(CSRFCookie, "", "/", -1, false))
+}
+
+func (c *SessionCookies) AccessToken(r *http.Request) string {
+	return c.value(r, AccessCookie)
+}
+
+func (c *SessionCookies) RefreshToken(r *http.Request) string {
+	return c.value(r, RefreshCookie)
+}
+
+// CheckCSRF проверяет double-submit токен: для методов, изменяющих данные,
+// заголовок X-CSRF-Token должен совпадать с cookie. Чужой сайт может заставить
+// браузер отправить cookie, но не может прочитать ее значение
+func (c *SessionCookies) CheckCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	cookie := c.value(r, CSRFCookie)
+	header := r.Header.Get(CSRFHeader)
+	return cookie != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+func (c *SessionCookies) value(r *http.Request, name string) string {
+	if !c.Enabled() {
+		return ""
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func (c *SessionCookies) cookie(name, value, path string, ttl time.Duration, httpOnly bool) *http.Cookie {
+	maxAge := int(ttl.Seconds())
+	if ttl < 0 {
+		maxAge = -1
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   c.cfg.CookieDomain,
+		MaxAge:   maxAge,
+		HttpOnly: httpOnly,
+		Secure:   c.cfg.CookieSecure,
+		SameSite: c.sameSite,
+	}
+}
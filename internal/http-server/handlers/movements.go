@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/http-server/handlers/middleware"
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"WarehouseControl/internal/storage/postgres"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type MovementsHandler struct {
+	movementStorage postgres.StockMovementStorageI
+	itemStorage     postgres.ItemStorageI
+	validate        *validator.Validate
+	log             *slog.Logger
+}
+
+func NewMovementsHandler(movementStorage postgres.StockMovementStorageI, itemStorage postgres.ItemStorageI, log *slog.Logger) *MovementsHandler {
+	return &MovementsHandler{
+		movementStorage: movementStorage,
+		itemStorage:     itemStorage,
+		validate:        validator.New(),
+		log:             log,
+	}
+}
+
+// quantityMovementRequest - приход и расход, количество всегда положительное,
+// знак определяется типом движения. Количество задается в единице unit
+// (базовой или дополнительной единице товара), без unit - в базовой
+type quantityMovementRequest struct {
+	LocationID *int            `json:"location_id"`
+	Quantity   models.Quantity `json:"quantity" validate:"gt=0"`
+	Unit       string          `json:"unit"`
+	Reason     string          `json:"reason" validate:"required"`
+	Note       string          `json:"note" validate:"max=500"`
+}
+
+type adjustMovementRequest struct {
+	LocationID *int            `json:"location_id"`
+	Delta      models.Quantity `json:"delta" validate:"required"`
+	Unit       string          `json:"unit"`
+	Reason     string          `json:"reason" validate:"required"`
+	Note       string          `json:"note" validate:"max=500"`
+}
+
+type transferMovementRequest struct {
+	FromLocationID int             `json:"from_location_id" validate:"required"`
+	ToLocationID   int             `json:"to_location_id" validate:"required,nefield=FromLocationID"`
+	Quantity       models.Quantity `json:"quantity" validate:"gt=0"`
+	Unit           string          `json:"unit"`
+	Note           string          `json:"note" validate:"max=500"`
+}
+
+type movementResponse struct {
+	Movements []*models.StockMovement `json:"movements"`
+	Item      *models.Item            `json:"item"`
+}
+
+// Receive оформляет приход товара в место хранения
+func (h *MovementsHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	h.quantityMovement(w, r, "handlers.movements.Receive", models.MovementReceive, 1)
+}
+
+// Issue оформляет расход товара из места хранения
+func (h *MovementsHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	h.quantityMovement(w, r, "handlers.movements.Issue", models.MovementIssue, -1)
+}
+
+func (h *MovementsHandler) quantityMovement(w http.ResponseWriter, r *http.Request, op string, movementType models.MovementType, sign int) {
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	itemID, ok := urlID(w, r, log, "id", "invalid item id")
+	if !ok {
+		return
+	}
+
+	var req quantityMovementRequest
+	if !h.decode(w, r, log, &req) {
+		return
+	}
+
+	movement := &models.StockMovement{
+		ItemID: itemID,
+		Type:   movementType,
+		Reason: req.Reason,
+		Note:   req.Note,
+	}
+	quantity := req.Quantity
+	if sign < 0 {
+		quantity = -quantity
+	}
+	setMovementQuantity(movement, quantity, req.Unit)
+	if req.LocationID != nil {
+		movement.LocationID = *req.LocationID
+	}
+
+	h.record(w, r, log, movement)
+}
+
+// Adjust оформляет корректировку остатка со знаком, например по результату инвентаризации
+func (h *MovementsHandler) Adjust(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.movements.Adjust"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	itemID, ok := urlID(w, r, log, "id", "invalid item id")
+	if !ok {
+		return
+	}
+
+	var req adjustMovementRequest
+	if !h.decode(w, r, log, &req) {
+		return
+	}
+
+	movement := &models.StockMovement{
+		ItemID: itemID,
+		Type:   models.MovementAdjust,
+		Reason: req.Reason,
+		Note:   req.Note,
+	}
+	setMovementQuantity(movement, req.Delta, req.Unit)
+	if req.LocationID != nil {
+		movement.LocationID = *req.LocationID
+	}
+
+	h.record(w, r, log, movement)
+}
+
+// setMovementQuantity задает количество движения со знаком. С единицей оно
+// сохраняется как введенное, а Delta в базовой единице вычисляет storage
+func setMovementQuantity(movement *models.StockMovement, quantity models.Quantity, unit string) {
+	if unit = models.NormalizeUnit(unit); unit == "" {
+		movement.Delta = quantity
+		return
+	}
+	movement.EnteredQuantity, movement.EnteredUnit = &quantity, &unit
+}
+
+func (h *MovementsHandler) record(w http.ResponseWriter, r *http.Request, log *slog.Logger, movement *models.StockMovement) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	if !movement.Type.ValidReason(movement.Reason) {
+		log.Warn("invalid movement reason", slog.String("type", string(movement.Type)), slog.String("reason", movement.Reason))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(fmt.Sprintf("reason %q is not allowed for %s movements", movement.Reason, movement.Type)))
+		return
+	}
+
+	if err := h.movementStorage.RecordMovement(r.Context(), movement, claims.Username); err != nil {
+		h.writeError(w, log, err)
+		return
+	}
+
+	log.Info("stock movement recorded",
+		slog.Int("item_id", movement.ItemID),
+		slog.String("type", string(movement.Type)),
+		slog.String("delta", movement.Delta.String()),
+		slog.String("unit", movement.Unit),
+	)
+
+	h.writeMovements(w, r, log, movement.ItemID, []*models.StockMovement{movement})
+}
+
+// Transfer перемещает остаток товара между местами хранения
+func (h *MovementsHandler) Transfer(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.movements.Transfer"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	itemID, ok := urlID(w, r, log, "id", "invalid item id")
+	if !ok {
+		return
+	}
+
+	var req transferMovementRequest
+	if !h.decode(w, r, log, &req) {
+		return
+	}
+
+	movements, err := h.movementStorage.Transfer(r.Context(), itemID, req.FromLocationID, req.ToLocationID, req.Quantity,
+		models.NormalizeUnit(req.Unit), req.Note, claims.Username)
+	if err != nil {
+		h.writeError(w, log, err)
+		return
+	}
+
+	log.Info("stock transferred",
+		slog.Int("item_id", itemID),
+		slog.Int("from_location_id", req.FromLocationID),
+		slog.Int("to_location_id", req.ToLocationID),
+		slog.String("quantity", movements[1].Delta.String()),
+		slog.String("unit", movements[1].Unit),
+	)
+
+	h.writeMovements(w, r, log, itemID, movements)
+}
+
+// ListMovements возвращает журнал движений товара
+func (h *MovementsHandler) ListMovements(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.movements.ListMovements"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	itemID, ok := urlID(w, r, log, "id", "invalid item id")
+	if !ok {
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r)
+	if err != nil {
+		log.Warn("invalid query", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	movements, err := h.movementStorage.ListMovements(r.Context(), itemID, limit, offset)
+	if err != nil {
+		log.Error("failed to get stock movements", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get stock movements"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data []*models.StockMovement `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     movements,
+	})
+}
+
+// writeMovements отвечает проведенными движениями и актуальными остатками товара
+func (h *MovementsHandler) writeMovements(w http.ResponseWriter, r *http.Request, log *slog.Logger, itemID int, movements []*models.StockMovement) {
+	item, err := h.itemStorage.GetItemByID(r.Context(), itemID)
+	if err != nil {
+		log.Error("failed to get item", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get item"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data movementResponse `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     movementResponse{Movements: movements, Item: item},
+	})
+}
+
+func (h *MovementsHandler) decode(w http.ResponseWriter, r *http.Request, log *slog.Logger, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Warn("invalid request body", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return false
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return false
+	}
+
+	return true
+}
+
+func (h *MovementsHandler) writeError(w http.ResponseWriter, log *slog.Logger, err error) {
+	if errors.Is(err, storage.ErrItemNotFound) {
+		log.Warn("item not found", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(response.Error("item not found"))
+		return
+	}
+	if writeStockError(w, log, err) {
+		return
+	}
+
+	log.Error("failed to record stock movement", slog.String("error", err.Error()))
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(response.Error("failed to record stock movement"))
+}
+
+// parseLimitOffset читает limit (по умолчанию 100, не больше 1000) и offset из query
+func parseLimitOffset(r *http.Request) (int, int, error) {
+	q := r.URL.Query()
+
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			return 0, 0, errors.New("limit must be between 1 and 1000")
+		}
+		limit = n
+	}
+
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/lib/oidc"
+	"WarehouseControl/internal/lib/tokens"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcFlowTTL    = 10 * time.Minute
+)
+
+// rolePriority - при нескольких сопоставленных ролях выбирается самая широкая
+var rolePriority = map[models.UserRole]int{
+	models.RoleViewer:  1,
+	models.RoleManager: 2,
+	models.RoleAdmin:   3,
+}
+
+// OIDCHandler - вход через OpenID Connect провайдер. Локальные учетные
+// записи и POST /login продолжают работать как запасной вход
+type OIDCHandler struct {
+	auth     *AuthHandler
+	provider *oidc.Provider
+	cfg      config.OIDC
+	log      *slog.Logger
+}
+
+func NewOIDCHandler(auth *AuthHandler, cfg config.OIDC, log *slog.Logger) *OIDCHandler {
+	return &OIDCHandler{
+		auth:     auth,
+		provider: oidc.NewProvider(cfg.IssuerURL, cfg.ClientID, cfg.ClientSecret, cfg.RedirectURL, cfg.Scopes),
+		cfg:      cfg,
+		log:      log,
+	}
+}
+
+// oidcFlow - параметры начатого входа, хранятся в cookie до возврата от провайдера
+type oidcFlow struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+type authProvidersResponse struct {
+	Local bool `json:"local"`
+	OIDC  bool `json:"oidc"`
+}
+
+// Providers сообщает странице входа, доступен ли вход через SSO
+func (h *OIDCHandler) Providers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data authProvidersResponse `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     authProvidersResponse{Local: true, OIDC: h.cfg.Enabled},
+	})
+}
+
+// Login начинает authorization code flow и перенаправляет на страницу входа провайдера
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.oidc.Login"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	var flow oidcFlow
+	for _, v := range []*string{&flow.State, &flow.Nonce, &flow.CodeVerifier} {
+		token, err := tokens.Generate()
+		if err != nil {
+			log.Error("failed to generate oidc flow params", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response.Error("internal server error"))
+			return
+		}
+		*v = token
+	}
+
+	authURL, err := h.provider.AuthCodeURL(r.Context(), flow.State, flow.Nonce, flow.CodeVerifier)
+	if err != nil {
+		log.Error("failed to build authorization url", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(response.Error("identity provider is unavailable"))
+		return
+	}
+
+	value, _ := json.Marshal(flow)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		Path:     "/auth/oidc",
+		MaxAge:   int(oidcFlowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback завершает вход: проверяет state, обменивает code на токены,
+// проверяет ID токен и создает пользователя при первом входе.
+// Токены приложения передаются странице входа во фрагменте URL. Если пользователю
+// нужен второй фактор, вместо токенов передается pre-auth токен, как в POST /login
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.oidc.Callback"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	flow, err := readOIDCFlow(r)
+	// cookie одноразовая
+	http.SetCookie(w, &http.Cookie{Name: oidcFlowCookie, Path: "/auth/oidc", MaxAge: -1, HttpOnly: true})
+	if err != nil {
+		log.Warn("oidc flow cookie is missing or invalid")
+		h.fail(w, r, log, "", "sso_session_expired")
+		return
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		log.Warn("identity provider returned error", slog.String("error", e), slog.String("description", q.Get("error_description")))
+		h.fail(w, r, log, "", "sso_denied")
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(flow.State)) != 1 {
+		log.Warn("oidc state mismatch")
+		h.fail(w, r, log, "", "sso_session_expired")
+		return
+	}
+
+	tokenResp, err := h.provider.Exchange(r.Context(), q.Get("code"), flow.CodeVerifier)
+	if err != nil {
+		log.Error("failed to exchange authorization code", slog.String("error", err.Error()))
+		h.fail(w, r, log, "", "sso_failed")
+		return
+	}
+
+	claims, err := h.provider.VerifyIDToken(r.Context(), tokenResp.IDToken, flow.Nonce)
+	if err != nil {
+		log.Warn("invalid id token", slog.String("error", err.Error()))
+		h.fail(w, r, log, "", "sso_failed")
+		return
+	}
+
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	username, _ := claims[h.cfg.UsernameClaim].(string)
+	if subject == "" || len(username) < 3 || len(username) > 50 {
+		log.Warn("id token has no usable subject or username",
+			slog.String("sub", subject),
+			slog.String("username_claim", h.cfg.UsernameClaim),
+		)
+		h.fail(w, r, log, "", "sso_failed")
+		return
+	}
+
+	role, ok := h.mapRole(claims[h.cfg.RoleClaim])
+	if !ok {
+		log.Warn("no role mapped for oidc user", slog.String("username", username))
+		h.fail(w, r, log, username, "sso_no_role")
+		return
+	}
+
+	user, err := h.provisionUser(r, log, issuer+"|"+subject, username, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserExists), errors.Is(err, storage.ErrUsernameReserved):
+			log.Warn("oidc username is taken by local account", slog.String("username", username))
+			h.fail(w, r, log, username, "sso_account_conflict")
+		case errors.Is(err, errUserDisabled):
+			log.Warn("user is disabled", slog.String("username", username))
+			h.fail(w, r, log, username, "sso_denied")
+		default:
+			log.Error("failed to provision oidc user", slog.String("error", err.Error()))
+			h.fail(w, r, log, username, "sso_failed")
+		}
+		return
+	}
+
+	required, enrolled, err := h.auth.secondFactorRequired(r.Context(), user)
+	if err != nil {
+		log.Error("failed to check second factor", slog.String("error", err.Error()))
+		h.fail(w, r, log, username, "sso_failed")
+		return
+	}
+	if required {
+		h.startMFAChallenge(w, r, log, user, enrolled)
+		return
+	}
+
+	resp, err := h.auth.startSession(r.Context(), user)
+	if err != nil {
+		log.Error("failed to start session", slog.String("error", err.Error()))
+		h.fail(w, r, log, username, "sso_failed")
+		return
+	}
+
+	log.Info("user logged in via oidc", slog.String("username", user.Username), slog.String("role", string(user.Role)))
+	h.auth.event(r, log, models.AuthEventOIDCLogin, models.AuthOutcomeSuccess, user.Username, user, "")
+
+	fragment := url.Values{
+		"expires_in": {strconv.Itoa(resp.ExpiresIn)},
+		"role":       {string(resp.Role)},
+	}
+	// В режиме cookie токены не попадают в URL
+	if h.auth.sessions.Enabled() {
+		if _, err = h.auth.sessions.Set(w, resp.Token, h.auth.accessTokenTTL, resp.RefreshToken, h.auth.refreshTokenTTL); err != nil {
+			log.Error("failed to set session cookies", slog.String("error", err.Error()))
+			h.fail(w, r, log, username, "sso_failed")
+			return
+		}
+	} else {
+		fragment.Set("token", resp.Token)
+		fragment.Set("refresh_token", resp.RefreshToken)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, "/login.html#"+fragment.Encode(), http.StatusFound)
+}
+
+// startMFAChallenge передает странице входа pre-auth токен второго шага
+func (h *OIDCHandler) startMFAChallenge(w http.ResponseWriter, r *http.Request, log *slog.Logger, user *models.User, enrolled bool) {
+	challenge, err := h.auth.newMFAChallenge(r.Context(), user, enrolled)
+	if err != nil {
+		log.Error("failed to start mfa challenge", slog.String("error", err.Error()))
+		h.fail(w, r, log, user.Username, "sso_failed")
+		return
+	}
+
+	log.Info("second factor required", slog.String("username", user.Username), slog.Bool("enrolled", enrolled))
+	h.auth.event(r, log, models.AuthEventOIDCLogin, models.AuthOutcomeChallenge, user.Username, user, "")
+
+	fragment := url.Values{
+		"mfa_token":           {challenge.MFAToken},
+		"expires_in":          {strconv.Itoa(challenge.ExpiresIn)},
+		"enrollment_required": {strconv.FormatBool(challenge.EnrollmentRequired)},
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, "/login.html#"+fragment.Encode(), http.StatusFound)
+}
+
+var errUserDisabled = errors.New("user is disabled")
+
+// provisionUser находит пользователя по внешнему идентификатору или создает его
+// при первом входе. Роль синхронизируется с провайдером при каждом входе
+func (h *OIDCHandler) provisionUser(r *http.Request, log *slog.Logger, externalID, username string, role models.UserRole) (*models.User, error) {
+	ctx := r.Context()
+
+	user, err := h.auth.userStorage.GetUserByExternalID(ctx, externalID)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		user = &models.User{
+			Username:     username,
+			Role:         role,
+			AuthProvider: models.AuthProviderOIDC,
+			ExternalID:   &externalID,
+		}
+		if err = h.auth.userStorage.CreateUser(ctx, user); err != nil {
+			return nil, err
+		}
+		log.Info("oidc user provisioned", slog.String("username", username), slog.String("role", string(role)))
+		return user, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Disabled {
+		return nil, errUserDisabled
+	}
+
+	if user.Role != role {
+		err = h.auth.userStorage.UpdateUserRole(ctx, user.ID, role)
+		switch {
+		case errors.Is(err, storage.ErrLastAdmin):
+			log.Warn("keeping role of last admin", slog.String("username", user.Username))
+		case err != nil:
+			return nil, err
+		default:
+			// Сессии со старой ролью больше не действительны
+			if _, err = h.auth.tokenStorage.RevokeUserSessions(ctx, user.ID); err != nil {
+				return nil, err
+			}
+			log.Info("oidc user role synced",
+				slog.String("username", user.Username),
+				slog.String("old_role", string(user.Role)),
+				slog.String("new_role", string(role)),
+			)
+			user.Role = role
+		}
+	}
+
+	return user, nil
+}
+
+// mapRole сопоставляет значение claim (строка или массив строк) с ролью
+func (h *OIDCHandler) mapRole(claim interface{}) (models.UserRole, bool) {
+	var values []string
+	switch v := claim.(type) {
+	case string:
+		values = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	var role models.UserRole
+	for _, v := range values {
+		mapped := models.UserRole(h.cfg.RoleMapping[v])
+		if mapped.IsValid() && rolePriority[mapped] > rolePriority[role] {
+			role = mapped
+		}
+	}
+
+	if role == "" {
+		role = models.UserRole(h.cfg.DefaultRole)
+	}
+
+	return role, role.IsValid()
+}
+
+func readOIDCFlow(r *http.Request) (*oidcFlow, error) {
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var flow oidcFlow
+	if err = json.Unmarshal(raw, &flow); err != nil {
+		return nil, err
+	}
+	if flow.State == "" || flow.Nonce == "" || flow.CodeVerifier == "" {
+		return nil, errors.New("incomplete oidc flow")
+	}
+
+	return &flow, nil
+}
+
+func redirectLoginError(w http.ResponseWriter, r *http.Request, code string) {
+	http.Redirect(w, r, "/login.html#error="+url.QueryEscape(code), http.StatusFound)
+}
+
+// fail записывает неудачный вход через OIDC в журнал и возвращает на страницу входа
+func (h *OIDCHandler) fail(w http.ResponseWriter, r *http.Request, log *slog.Logger, username, code string) {
+	h.auth.event(r, log, models.AuthEventOIDCLogin, models.AuthOutcomeFailure, username, nil, code)
+	redirectLoginError(w, r, code)
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/lib/jwtkeys"
+	"WarehouseControl/internal/lib/logger/handlers/slogdiscard"
+	"WarehouseControl/internal/lib/oidc/oidctest"
+	"WarehouseControl/internal/lib/tokens"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"WarehouseControl/internal/storage/postgres"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryUsers хранит пользователей по внешнему идентификатору и, как UserStorage,
+// отклоняет имена API ключей. lastAdmin имитирует защиту последнего администратора
+type memoryUsers struct {
+	postgres.UserStorageI
+	users     []*models.User
+	lastAdmin bool
+}
+
+func (m *memoryUsers) GetUserByExternalID(ctx context.Context, externalID string) (*models.User, error) {
+	for _, u := range m.users {
+		if u.ExternalID != nil && *u.ExternalID == externalID {
+			copied := *u
+			return &copied, nil
+		}
+	}
+	return nil, storage.ErrUserNotFound
+}
+
+func (m *memoryUsers) CreateUser(ctx context.Context, user *models.User) error {
+	if models.IsAPIKeyUsername(user.Username) {
+		return storage.ErrUsernameReserved
+	}
+	for _, u := range m.users {
+		if u.Username == user.Username {
+			return storage.ErrUserExists
+		}
+	}
+	user.ID = len(m.users) + 1
+	copied := *user
+	m.users = append(m.users, &copied)
+	return nil
+}
+
+func (m *memoryUsers) UpdateUserRole(ctx context.Context, id int, role models.UserRole) error {
+	for _, u := range m.users {
+		if u.ID != id {
+			continue
+		}
+		if m.lastAdmin && u.Role == models.RoleAdmin && role != models.RoleAdmin {
+			return storage.ErrLastAdmin
+		}
+		u.Role = role
+		return nil
+	}
+	return storage.ErrUserNotFound
+}
+
+// memoryTokens считает созданные и отозванные сессии
+type memoryTokens struct {
+	postgres.TokenStorageI
+	created, revoked int
+}
+
+func (m *memoryTokens) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	m.created++
+	return nil
+}
+
+func (m *memoryTokens) RevokeUserSessions(ctx context.Context, userID int) (int, error) {
+	m.revoked++
+	return 1, nil
+}
+
+type memoryAuthEvents struct {
+	postgres.AuthEventStorageI
+	events []*models.AuthEvent
+}
+
+func (m *memoryAuthEvents) RecordAuthEvent(ctx context.Context, event *models.AuthEvent) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+type oidcTestEnv struct {
+	provider *oidctest.Server
+	handler  *OIDCHandler
+	users    *memoryUsers
+	tokens   *memoryTokens
+	events   *memoryAuthEvents
+	totp     *memoryTOTP
+}
+
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	t.Helper()
+
+	t.Setenv("OIDC_TEST_JWT_SECRET", "0123456789abcdef0123456789abcdef")
+	keys, err := jwtkeys.Load(config.Signing{
+		Issuer:    "warehouse-control",
+		Audience:  "warehouse-control",
+		ActiveKey: "hs-1",
+		Keys:      []config.SigningKey{{ID: "hs-1", Algorithm: jwtkeys.AlgHS256, SecretEnv: "OIDC_TEST_JWT_SECRET"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &oidcTestEnv{
+		provider: oidctest.NewServer(t, "warehouse-control"),
+		users:    &memoryUsers{},
+		tokens:   &memoryTokens{},
+		events:   &memoryAuthEvents{},
+		totp:     &memoryTOTP{},
+	}
+
+	log := slogdiscard.NewDiscardLogger()
+	auth := &AuthHandler{
+		userStorage:  env.users,
+		tokenStorage: env.tokens,
+		keys:         keys,
+		twoFactor:    env.totp,
+		twoFactorCfg: config.TwoFactor{EnforcedRoles: []string{"admin"}, ChallengeTTL: 5 * time.Minute},
+		authEvents:   env.events,
+		log:          log,
+	}
+	env.handler = NewOIDCHandler(auth, config.OIDC{
+		Enabled:       true,
+		IssuerURL:     env.provider.URL,
+		ClientID:      "warehouse-control",
+		RedirectURL:   "http://app.test/auth/oidc/callback",
+		Scopes:        []string{"openid", "profile"},
+		UsernameClaim: "preferred_username",
+		RoleClaim:     "groups",
+		RoleMapping:   map[string]string{"wh-admins": "admin", "wh-managers": "manager", "wh-viewers": "viewer"},
+	}, log)
+
+	return env
+}
+
+// login проходит вход через провайдер и возвращает фрагмент URL страницы входа.
+// tamper может изменить параметры возврата от провайдера
+func (e *oidcTestEnv) login(t *testing.T, tamper func(q url.Values)) url.Values {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	e.handler.Login(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %d", rec.Code)
+	}
+
+	code, state := e.provider.Authorize(t, rec.Header().Get("Location"))
+	q := url.Values{"code": {code}, "state": {state}}
+	if tamper != nil {
+		tamper(q)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+q.Encode(), nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	e.handler.Callback(rec, req)
+
+	location := rec.Header().Get("Location")
+	fragment, ok := strings.CutPrefix(location, "/login.html#")
+	if rec.Code != http.StatusFound || !ok {
+		t.Fatalf("callback = %d %q, want redirect to login page", rec.Code, location)
+	}
+	values, err := url.ParseQuery(fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestOIDCCallbackProvisionsUser(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	env.provider.Claims = map[string]interface{}{"preferred_username": "alice", "groups": []string{"staff", "wh-managers"}}
+
+	got := env.login(t, nil)
+	if got.Get("error") != "" || got.Get("token") == "" || got.Get("refresh_token") == "" || got.Get("role") != "manager" {
+		t.Fatalf("fragment = %v, want tokens for manager", got)
+	}
+
+	if len(env.users.users) != 1 {
+		t.Fatalf("users = %d, want 1", len(env.users.users))
+	}
+	user := env.users.users[0]
+	if user.Username != "alice" || user.Role != models.RoleManager || user.AuthProvider != models.AuthProviderOIDC ||
+		user.ExternalID == nil || *user.ExternalID != env.provider.URL+"|user-1" {
+		t.Fatalf("provisioned user = %+v", user)
+	}
+
+	// Повторный вход находит того же пользователя и синхронизирует роль
+	env.provider.Claims["groups"] = "wh-viewers"
+	got = env.login(t, nil)
+	if got.Get("role") != "viewer" || len(env.users.users) != 1 || env.users.users[0].Role != models.RoleViewer {
+		t.Fatalf("fragment = %v, users = %+v, want role synced to viewer", got, env.users.users)
+	}
+	if env.tokens.revoked != 1 {
+		t.Fatalf("sessions revoked %d times, want 1 after role change", env.tokens.revoked)
+	}
+}
+
+// Роль последнего администратора не понижается, но вход не запрещается
+func TestOIDCCallbackKeepsLastAdmin(t *testing.T) {
+	env := newOIDCTestEnv(t)
+	externalID := env.provider.URL + "|user-1"
+	env.users.users = []*models.User{{ID: 1, Username: "root", Role: models.RoleAdmin, AuthProvider: models.AuthProviderOIDC, ExternalID: &externalID}}
+	env.users.lastAdmin = true
+	env.provider.Claims = map[string]interface{}{"preferred_username": "root", "groups": "wh-viewers"}
+
+	// Для admin второй фактор обязателен, поэтому вход продолжается через mfa_token
+	got := env.login(t, nil)
+	if got.Get("error") != "" || got.Get("mfa_token") == "" {
+		t.Fatalf("fragment = %v, want admin login to continue", got)
+	}
+	if env.users.users[0].Role != models.RoleAdmin || env.tokens.revoked != 0 {
+		t.Fatalf("role = %s, revoked = %d, want admin kept without revocation", env.users.users[0].Role, env.tokens.revoked)
+	}
+}
+
+// SSO не обходит второй фактор: те же правила, что и у POST /login
+func TestOIDCCallbackRequiresSecondFactor(t *testing.T) {
+	tests := []struct {
+		name       string
+		group      string
+		totp       bool
+		mfa        bool
+		enrollment bool
+	}{
+		{"totp enabled", "wh-viewers", true, true, false},
+		{"enforced role without totp", "wh-admins", false, true, true},
+		{"enforced role with totp", "wh-admins", true, true, false},
+		{"optional and not enabled", "wh-viewers", false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOIDCTestEnv(t)
+			env.provider.Claims = map[string]interface{}{"preferred_username": "alice", "groups": tt.group}
+			if tt.totp {
+				// Пользователь уже создан первым входом, TOTP включен позже
+				externalID := env.provider.URL + "|user-1"
+				env.users.users = []*models.User{{ID: 1, Username: "alice", Role: models.RoleViewer, AuthProvider: models.AuthProviderOIDC, ExternalID: &externalID}}
+				env.totp.totp = &models.UserTOTP{UserID: 1, Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Enabled: true}
+			}
+
+			got := env.login(t, nil)
+			if !tt.mfa {
+				if got.Get("token") == "" || got.Get("mfa_token") != "" || env.tokens.created != 1 {
+					t.Fatalf("fragment = %v, want session tokens", got)
+				}
+				return
+			}
+
+			if got.Get("token") != "" || got.Get("refresh_token") != "" || env.tokens.created != 0 {
+				t.Fatalf("fragment = %v, sessions = %d, want no tokens before second factor", got, env.tokens.created)
+			}
+			if got.Get("mfa_token") == "" || got.Get("expires_in") != "300" || got.Get("enrollment_required") != strconv.FormatBool(tt.enrollment) {
+				t.Fatalf("fragment = %v, want mfa challenge with enrollment_required = %v", got, tt.enrollment)
+			}
+			if len(env.totp.challenges) != 1 || env.totp.challenges[0].TokenHash != tokens.Hash(got.Get("mfa_token")) {
+				t.Fatalf("challenges = %+v, want one for the issued mfa_token", env.totp.challenges)
+			}
+
+			last := env.events.events[len(env.events.events)-1]
+			if last.Type != models.AuthEventOIDCLogin || last.Outcome != models.AuthOutcomeChallenge {
+				t.Fatalf("event = %+v, want oidc challenge", last)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		tamper func(q url.Values)
+		users  func(env *oidcTestEnv)
+		error  string
+	}{
+		{name: "nonce mismatch", claims: map[string]interface{}{"nonce": "replayed"}, error: "sso_failed"},
+		{name: "wrong audience", claims: map[string]interface{}{"aud": "another-client"}, error: "sso_failed"},
+		{name: "expired id token", claims: map[string]interface{}{"exp": 1}, error: "sso_failed"},
+		{name: "state mismatch", tamper: func(q url.Values) { q.Set("state", "forged") }, error: "sso_session_expired"},
+		{name: "wrong code", tamper: func(q url.Values) { q.Set("code", "forged") }, error: "sso_failed"},
+		{name: "provider error", tamper: func(q url.Values) { q.Set("error", "access_denied") }, error: "sso_denied"},
+		{name: "no mapped role", claims: map[string]interface{}{"groups": "contractors"}, error: "sso_no_role"},
+		{name: "no username", claims: map[string]interface{}{"preferred_username": nil}, error: "sso_failed"},
+		{name: "username taken by local account", users: func(env *oidcTestEnv) {
+			env.users.users = []*models.User{{ID: 1, Username: "alice", Role: models.RoleViewer, AuthProvider: models.AuthProviderLocal}}
+		}, error: "sso_account_conflict"},
+		{name: "reserved username", claims: map[string]interface{}{"preferred_username": "apikey:erp"}, error: "sso_account_conflict"},
+		{name: "disabled user", users: func(env *oidcTestEnv) {
+			externalID := env.provider.URL + "|user-1"
+			env.users.users = []*models.User{{ID: 1, Username: "alice", Role: models.RoleManager, Disabled: true, ExternalID: &externalID}}
+		}, error: "sso_denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOIDCTestEnv(t)
+			env.provider.Claims = map[string]interface{}{"preferred_username": "alice", "groups": "wh-managers"}
+			for k, v := range tt.claims {
+				env.provider.Claims[k] = v
+			}
+			if tt.users != nil {
+				tt.users(env)
+			}
+			before := len(env.users.users)
+
+			got := env.login(t, tt.tamper)
+			if got.Get("error") != tt.error || got.Get("token") != "" {
+				t.Fatalf("fragment = %v, want error %s", got, tt.error)
+			}
+			if len(env.users.users) != before || env.tokens.created != 0 {
+				t.Fatalf("users = %d, sessions = %d, want nothing created", len(env.users.users), env.tokens.created)
+			}
+
+			last := env.events.events[len(env.events.events)-1]
+			if last.Type != models.AuthEventOIDCLogin || last.Outcome != models.AuthOutcomeFailure || last.Reason != tt.error {
+				t.Fatalf("event = %+v, want oidc failure %s", last, tt.error)
+			}
+		})
+	}
+}
+
+func TestOIDCMapRole(t *testing.T) {
+	h := &OIDCHandler{cfg: config.OIDC{
+		RoleMapping: map[string]string{"wh-admins": "admin", "wh-managers": "manager", "broken": "owner"},
+	}}
+	withDefault := &OIDCHandler{cfg: config.OIDC{RoleMapping: h.cfg.RoleMapping, DefaultRole: "viewer"}}
+
+	tests := []struct {
+		name    string
+		handler *OIDCHandler
+		claim   interface{}
+		role    models.UserRole
+		ok      bool
+	}{
+		{"string claim", h, "wh-managers", models.RoleManager, true},
+		{"widest role wins", h, []interface{}{"wh-managers", "wh-admins"}, models.RoleAdmin, true},
+		{"non-string items skipped", h, []interface{}{1, "wh-managers"}, models.RoleManager, true},
+		{"invalid mapping ignored", h, "broken", "", false},
+		{"no claim", h, nil, "", false},
+		{"unmapped without default", h, "staff", "", false},
+		{"unmapped with default", withDefault, "staff", models.RoleViewer, true},
+		{"mapped beats default", withDefault, "wh-admins", models.RoleAdmin, true},
+	}
+
+	for _, tt := range tests {
+		role, ok := tt.handler.mapRole(tt.claim)
+		if ok != tt.ok || (ok && role != tt.role) {
+			t.Errorf("%s: mapRole = (%s, %v), want (%s, %v)", tt.name, role, ok, tt.role, tt.ok)
+		}
+	}
+}
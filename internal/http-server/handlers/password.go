@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/http-server/handlers/middleware"
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/lib/passwords"
+	"WarehouseControl/internal/lib/tokens"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"WarehouseControl/internal/storage/postgres"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type PasswordHandler struct {
+	userStorage      postgres.UserStorageI
+	tokenStorage     postgres.TokenStorageI
+	resetStorage     postgres.PasswordResetStorageI
+	passwords        *passwords.Hasher
+	passwordResetTTL time.Duration
+	validate         *validator.Validate
+	log              *slog.Logger
+}
+
+func NewPasswordHandler(
+	userStorage postgres.UserStorageI,
+	tokenStorage postgres.TokenStorageI,
+	resetStorage postgres.PasswordResetStorageI,
+	hasher *passwords.Hasher,
+	passwordResetTTL time.Duration,
+	log *slog.Logger,
+) *PasswordHandler {
+	return &PasswordHandler{
+		userStorage:      userStorage,
+		tokenStorage:     tokenStorage,
+		resetStorage:     resetStorage,
+		passwords:        hasher,
+		passwordResetTTL: passwordResetTTL,
+		validate:         validator.New(),
+		log:              log,
+	}
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+type passwordResetResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ChangePassword меняет пароль текущего пользователя. Остальные
+// сессии пользователя завершаются, текущая остается активной
+func (h *PasswordHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.password.ChangePassword"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	var req changePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn("invalid request body", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return
+	}
+
+	user, err := h.userStorage.GetUserByUsername(r.Context(), claims.Username)
+	if err != nil {
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	if _, err = h.passwords.Verify(user.PasswordHash, req.CurrentPassword); err != nil {
+		log.Warn("invalid current password", slog.String("username", user.Username))
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(response.Error("invalid current password"))
+		return
+	}
+
+	hash, err := h.hashNewPassword(r.Context(), user, req.NewPassword)
+	if err != nil {
+		writePasswordError(w, log, err)
+		return
+	}
+
+	if err = h.userStorage.ChangePassword(r.Context(), user.ID, hash, h.passwords.HistorySize()); err != nil {
+		log.Error("failed to update password", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to update password"))
+		return
+	}
+
+	if _, err = h.tokenStorage.RevokeOtherUserSessions(r.Context(), user.ID, claims.ID); err != nil {
+		log.Error("failed to revoke other sessions", slog.String("error", err.Error()))
+	}
+
+	log.Info("password changed", slog.String("username", user.Username))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
+
+// CreateResetToken выпускает одноразовый токен сброса пароля пользователя.
+// Токен возвращается только один раз, в базе хранится его хеш
+func (h *PasswordHandler) CreateResetToken(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.password.CreateResetToken"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Warn("invalid user id", slog.String("id", idStr))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid user id"))
+		return
+	}
+
+	user, err := h.userStorage.GetUserByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", slog.Int("user_id", id))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("user not found"))
+			return
+		}
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	resetToken, err := tokens.Generate()
+	if err != nil {
+		log.Error("failed to generate reset token", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	token := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: tokens.Hash(resetToken),
+		CreatedBy: claims.Username,
+		ExpiresAt: time.Now().Add(h.passwordResetTTL),
+	}
+
+	if err = h.resetStorage.CreateResetToken(r.Context(), token); err != nil {
+		log.Error("failed to create reset token", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to create reset token"))
+		return
+	}
+
+	log.Info("password reset token issued",
+		slog.String("username", user.Username),
+		slog.String("created_by", claims.Username),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data passwordResetResponse `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     passwordResetResponse{Token: resetToken, ExpiresAt: token.ExpiresAt},
+	})
+}
+
+// ResetPassword устанавливает новый пароль по токену сброса
+// и завершает все существующие сессии пользователя
+func (h *PasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.password.ResetPassword"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn("invalid request body", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return
+	}
+
+	userID, err := h.resetPassword(r.Context(), tokens.Hash(req.Token), req.NewPassword)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrTokenNotFound), errors.Is(err, storage.ErrTokenUsed), errors.Is(err, storage.ErrTokenExpired):
+			log.Warn("invalid reset token", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response.Error("invalid or expired reset token"))
+		case errors.Is(err, passwords.ErrPolicy):
+			writePasswordError(w, log, err)
+		default:
+			log.Error("failed to reset password", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response.Error("failed to reset password"))
+		}
+		return
+	}
+
+	log.Info("password reset", slog.Int("user_id", userID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
+
+// resetPassword проверяет токен и новый пароль до того, как токен будет погашен,
+// чтобы пароль, отклоненный политикой, можно было исправить с тем же токеном
+func (h *PasswordHandler) resetPassword(ctx context.Context, tokenHash, password string) (int, error) {
+	userID, err := h.resetStorage.GetResetTokenUserID(ctx, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+
+	user, err := h.userStorage.GetUserByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	hash, err := h.hashNewPassword(ctx, user, password)
+	if err != nil {
+		return 0, err
+	}
+
+	return h.resetStorage.ResetPassword(ctx, tokenHash, hash, h.passwords.HistorySize())
+}
+
+// hashNewPassword проверяет новый пароль по политике и истории паролей пользователя
+// и возвращает его хеш
+func (h *PasswordHandler) hashNewPassword(ctx context.Context, user *models.User, password string) (string, error) {
+	if err := h.passwords.Check(user.Username, password); err != nil {
+		return "", err
+	}
+
+	if h.passwords.HistorySize() > 0 {
+		history, err := h.userStorage.GetPasswordHistory(ctx, user.ID)
+		if err != nil {
+			return "", err
+		}
+		if err = h.passwords.CheckReuse(password, append([]string{user.PasswordHash}, history...)); err != nil {
+			return "", err
+		}
+	}
+
+	return h.passwords.Hash(password)
+}
+
+// writePasswordError - нарушение политики показывается пользователю, остальные ошибки нет
+func writePasswordError(w http.ResponseWriter, log *slog.Logger, err error) {
+	if errors.Is(err, passwords.ErrPolicy) {
+		log.Warn("password rejected by policy", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	log.Error("failed to hash password", slog.String("error", err.Error()))
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(response.Error("internal server error"))
+}
@@ -0,0 +1,686 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/http-server/handlers/middleware"
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/lib/tokens"
+	"WarehouseControl/internal/lib/totp"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var errInvalidSecondFactor = errors.New("invalid second factor code")
+
+// TwoFactorHandler - регистрация TOTP и второй шаг входа по паролю
+type TwoFactorHandler struct {
+	auth *AuthHandler
+	log  *slog.Logger
+}
+
+func NewTwoFactorHandler(auth *AuthHandler, log *slog.Logger) *TwoFactorHandler {
+	return &TwoFactorHandler{auth: auth, log: log}
+}
+
+// mfaChallengeResponse - ответ POST /login, когда нужен второй фактор.
+// mfa_token действует challenge_ttl и только для маршрутов /auth/2fa/*
+type mfaChallengeResponse struct {
+	MFARequired        bool   `json:"mfa_required"`
+	MFAToken           string `json:"mfa_token"`
+	ExpiresIn          int    `json:"expires_in"`
+	EnrollmentRequired bool   `json:"enrollment_required"`
+}
+
+type mfaTokenRequest struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+type mfaVerifyRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type totpCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type totpEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode string `json:"qr_code"` // data:image/png;base64,...
+}
+
+type totpStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	Enforced          bool `json:"enforced"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// secondFactorRequired сообщает, нужен ли пользователю второй шаг входа
+// и завершена ли уже регистрация TOTP
+func (h *AuthHandler) secondFactorRequired(ctx context.Context, user *models.User) (required, enrolled bool, err error) {
+	t, err := h.twoFactor.GetTOTP(ctx, user.ID)
+	if err != nil && !errors.Is(err, storage.ErrTOTPNotFound) {
+		return false, false, err
+	}
+
+	if t != nil && t.Enabled {
+		return true, true, nil
+	}
+
+	return h.twoFactorEnforced(user.Role), false, nil
+}
+
+func (h *AuthHandler) twoFactorEnforced(role models.UserRole) bool {
+	return slices.Contains(h.twoFactorCfg.EnforcedRoles, string(role))
+}
+
+// newMFAChallenge сохраняет challenge второго шага входа и возвращает pre-auth токен.
+// Используется и при входе по паролю, и при входе через SSO
+func (h *AuthHandler) newMFAChallenge(ctx context.Context, user *models.User, enrolled bool) (*mfaChallengeResponse, error) {
+	mfaToken, err := tokens.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &models.MFAChallenge{
+		UserID:    user.ID,
+		TokenHash: tokens.Hash(mfaToken),
+		ExpiresAt: time.Now().Add(h.twoFactorCfg.ChallengeTTL),
+	}
+	if err = h.twoFactor.CreateMFAChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	return &mfaChallengeResponse{
+		MFARequired:        true,
+		MFAToken:           mfaToken,
+		ExpiresIn:          int(h.twoFactorCfg.ChallengeTTL.Seconds()),
+		EnrollmentRequired: !enrolled,
+	}, nil
+}
+
+// startMFAChallenge выдает pre-auth токен вместо пары токенов сессии
+func (h *AuthHandler) startMFAChallenge(w http.ResponseWriter, r *http.Request, log *slog.Logger, user *models.User, enrolled bool) {
+	challenge, err := h.newMFAChallenge(r.Context(), user, enrolled)
+	if err != nil {
+		log.Error("failed to start mfa challenge", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	log.Info("second factor required", slog.String("username", user.Username), slog.Bool("enrolled", enrolled))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *mfaChallengeResponse `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     challenge,
+	})
+}
+
+// checkSecondFactor проверяет TOTP код или, если передан, код восстановления
+func (h *AuthHandler) checkSecondFactor(ctx context.Context, t *models.UserTOTP, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		err := h.twoFactor.UseRecoveryCode(ctx, t.UserID, tokens.Hash(totp.NormalizeRecoveryCode(recoveryCode)))
+		if errors.Is(err, storage.ErrRecoveryCodeInvalid) {
+			return errInvalidSecondFactor
+		}
+		return err
+	}
+
+	step, ok := totp.Validate(t.Secret, code, time.Now())
+	if !ok {
+		return errInvalidSecondFactor
+	}
+
+	err := h.twoFactor.UseTOTPStep(ctx, t.UserID, step)
+	if errors.Is(err, storage.ErrTOTPCodeUsed) {
+		return errInvalidSecondFactor
+	}
+	return err
+}
+
+// newRecoveryCodes генерирует коды восстановления и их хеши для хранения
+func (h *AuthHandler) newRecoveryCodes() ([]string, []string, error) {
+	codes, err := totp.GenerateRecoveryCodes(h.twoFactorCfg.RecoveryCodes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = tokens.Hash(code)
+	}
+
+	return codes, hashes, nil
+}
+
+// enroll создает (или заменяет неподтвержденный) секрет TOTP пользователя
+func (h *AuthHandler) enroll(ctx context.Context, user *models.User) (*totpEnrollmentResponse, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.twoFactor.SaveTOTPSecret(ctx, user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	uri := totp.ProvisioningURI(h.twoFactorCfg.Issuer, user.Username, secret)
+	qr, err := totp.QRCode(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return &totpEnrollmentResponse{Secret: secret, URI: uri, QRCode: qr}, nil
+}
+
+// confirmEnrollment включает TOTP после проверки первого кода и возвращает коды восстановления
+func (h *AuthHandler) confirmEnrollment(ctx context.Context, userID int, code string) ([]string, error) {
+	t, err := h.twoFactor.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if t.Enabled {
+		return nil, storage.ErrTOTPAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(t.Secret, code, time.Now())
+	if !ok {
+		return nil, errInvalidSecondFactor
+	}
+
+	codes, hashes, err := h.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.twoFactor.EnableTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// useChallenge находит пользователя по pre-auth токену, засчитывая попытку
+func (h *TwoFactorHandler) useChallenge(w http.ResponseWriter, r *http.Request, log *slog.Logger, mfaToken string) (*models.User, bool) {
+	challenge, err := h.auth.twoFactor.UseMFAChallenge(r.Context(), tokens.Hash(mfaToken), h.auth.twoFactorCfg.MaxAttempts)
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Warn("invalid or expired mfa token")
+			h.auth.event(r, log, models.AuthEventMFAVerify, models.AuthOutcomeFailure, "", nil, "invalid_mfa_token")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(response.Error("invalid or expired mfa token"))
+			return nil, false
+		}
+		log.Error("failed to use mfa challenge", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return nil, false
+	}
+
+	user, err := h.auth.userStorage.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil || user.Disabled {
+		log.Warn("mfa challenge for missing or disabled user", slog.Int("user_id", challenge.UserID))
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(response.Error("invalid or expired mfa token"))
+		return nil, false
+	}
+
+	return user, true
+}
+
+// LoginEnroll выдает секрет TOTP пользователю, для роли которого второй фактор
+// обязателен, но еще не настроен. Доступен только по pre-auth токену
+func (h *TwoFactorHandler) LoginEnroll(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.two_factor.LoginEnroll"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	var req mfaTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" {
+		log.Warn("invalid request body")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+
+	user, ok := h.useChallenge(w, r, log, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	h.writeEnrollment(w, r, log, user)
+}
+
+// LoginVerify - второй шаг входа: проверяет TOTP код или код восстановления
+// и выдает пару токенов. Если регистрация TOTP не завершена, первый верный
+// код ее подтверждает, а в ответ добавляются коды восстановления
+func (h *TwoFactorHandler) LoginVerify(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.two_factor.LoginVerify"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	var req mfaVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		log.Warn("invalid request body")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+
+	user, ok := h.useChallenge(w, r, log, req.MFAToken)
+	if !ok {
+		return
+	}
+
+	t, err := h.auth.twoFactor.GetTOTP(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, storage.ErrTOTPNotFound) {
+		log.Error("failed to get totp", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	var recoveryCodes []string
+	switch {
+	case t == nil:
+		log.Warn("totp enrollment is not started", slog.String("username", user.Username))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("totp enrollment is not started"))
+		return
+	case !t.Enabled:
+		recoveryCodes, err = h.auth.confirmEnrollment(r.Context(), user.ID, req.Code)
+	default:
+		err = h.auth.checkSecondFactor(r.Context(), t, req.Code, req.RecoveryCode)
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidSecondFactor) {
+			log.Warn("invalid second factor", slog.String("username", user.Username))
+			h.auth.event(r, log, models.AuthEventMFAVerify, models.AuthOutcomeFailure, user.Username, user, "invalid_code")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(response.Error("invalid code"))
+			return
+		}
+		log.Error("failed to verify second factor", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	if err = h.auth.twoFactor.DeleteMFAChallenge(r.Context(), tokens.Hash(req.MFAToken)); err != nil {
+		log.Error("failed to delete mfa challenge", slog.String("error", err.Error()))
+	}
+
+	resp, err := h.auth.startSession(r.Context(), user)
+	if err != nil {
+		log.Error("failed to start session", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+	resp.RecoveryCodes = recoveryCodes
+
+	log.Info("second factor verified",
+		slog.String("username", user.Username),
+		slog.Bool("recovery_code", req.RecoveryCode != ""),
+	)
+
+	reason := ""
+	if req.RecoveryCode != "" {
+		reason = "recovery_code"
+	}
+	h.auth.event(r, log, models.AuthEventMFAVerify, models.AuthOutcomeSuccess, user.Username, user, reason)
+
+	h.auth.writeTokens(w, log, resp, h.auth.sessions.Use(r))
+}
+
+// Status - состояние второго фактора текущего пользователя
+func (h *TwoFactorHandler) Status(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.two_factor.Status"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	user, ok := h.currentUser(w, r, log)
+	if !ok {
+		return
+	}
+
+	status := totpStatusResponse{Enforced: h.auth.twoFactorEnforced(user.Role)}
+
+	t, err := h.auth.twoFactor.GetTOTP(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, storage.ErrTOTPNotFound) {
+		log.Error("failed to get totp", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	if t != nil && t.Enabled {
+		status.Enabled = true
+		if status.RecoveryCodesLeft, err = h.auth.twoFactor.CountRecoveryCodes(r.Context(), user.ID); err != nil {
+			log.Error("failed to count recovery codes", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response.Error("internal server error"))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data totpStatusResponse `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     status,
+	})
+}
+
+// Enroll начинает добровольную регистрацию TOTP текущим пользователем
+func (h *TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.two_factor.Enroll"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	user, ok := h.currentUser(w, r, log)
+	if !ok {
+		return
+	}
+
+	h.writeEnrollment(w, r, log, user)
+}
+
+// Confirm подтверждает регистрацию первым кодом из приложения
+func (h *TwoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.two_factor.Confirm"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	user, ok := h.currentUser(w, r, log)
+	if !ok {
+		return
+	}
+
+	var req totpCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		log.Warn("invalid request body")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+
+	codes, err := h.auth.confirmEnrollment(r.Context(), user.ID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidSecondFactor):
+			log.Warn("invalid totp code", slog.String("username", user.Username))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response.Error("invalid code"))
+		case errors.Is(err, storage.ErrTOTPNotFound):
+			log.Warn("totp enrollment is not started", slog.String("username", user.Username))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response.Error("totp enrollment is not started"))
+		case errors.Is(err, storage.ErrTOTPAlreadyEnabled):
+			log.Warn("totp already enabled", slog.String("username", user.Username))
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(response.Error("totp is already enabled"))
+		default:
+			log.Error("failed to enable totp", slog.String("error", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response.Error("internal server error"))
+		}
+		return
+	}
+
+	log.Info("totp enabled", slog.String("username", user.Username))
+
+	writeRecoveryCodes(w, codes)
+}
+
+// RegenerateRecoveryCodes выпускает новые коды восстановления взамен старых
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.two_factor.RegenerateRecoveryCodes"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	user, t, ok := h.verifyCurrentUser(w, r, log)
+	if !ok {
+		return
+	}
+
+	codes, hashes, err := h.auth.newRecoveryCodes()
+	if err == nil {
+		err = h.auth.twoFactor.ReplaceRecoveryCodes(r.Context(), t.UserID, hashes)
+	}
+	if err != nil {
+		log.Error("failed to replace recovery codes", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	log.Info("recovery codes regenerated", slog.String("username", user.Username))
+
+	writeRecoveryCodes(w, codes)
+}
+
+// Disable отключает TOTP текущего пользователя, если его роль этого не запрещает
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.two_factor.Disable"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	user, _, ok := h.verifyCurrentUser(w, r, log)
+	if !ok {
+		return
+	}
+
+	if h.auth.twoFactorEnforced(user.Role) {
+		log.Warn("attempt to disable enforced totp", slog.String("username", user.Username))
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(response.Error("two-factor authentication is required for role " + string(user.Role)))
+		return
+	}
+
+	if err := h.auth.twoFactor.DisableTOTP(r.Context(), user.ID); err != nil {
+		log.Error("failed to disable totp", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	log.Info("totp disabled", slog.String("username", user.Username))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
+
+// Reset - сброс TOTP пользователя администратором (например, при потере телефона).
+// Если второй фактор обязателен для роли, пользователь зарегистрирует его при следующем входе
+func (h *TwoFactorHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.two_factor.Reset"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Warn("invalid user id", slog.String("id", idStr))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid user id"))
+		return
+	}
+
+	if err = h.auth.twoFactor.DisableTOTP(r.Context(), id); err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			log.Warn("totp not configured", slog.Int("id", id))
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(response.Error("totp is not configured"))
+			return
+		}
+		log.Error("failed to reset totp", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	resetBy := ""
+	if claims, ok := middleware.GetUserFromContext(r.Context()); ok {
+		resetBy = claims.Username
+	}
+	log.Info("totp reset", slog.Int("user_id", id), slog.String("reset_by", resetBy))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
+
+func (h *TwoFactorHandler) writeEnrollment(w http.ResponseWriter, r *http.Request, log *slog.Logger, user *models.User) {
+	enrollment, err := h.auth.enroll(r.Context(), user)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPAlreadyEnabled) {
+			log.Warn("totp already enabled", slog.String("username", user.Username))
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(response.Error("totp is already enabled"))
+			return
+		}
+		log.Error("failed to start totp enrollment", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	log.Info("totp enrollment started", slog.String("username", user.Username))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *totpEnrollmentResponse `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     enrollment,
+	})
+}
+
+func (h *TwoFactorHandler) currentUser(w http.ResponseWriter, r *http.Request, log *slog.Logger) (*models.User, bool) {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		log.Error("user not found in context")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return nil, false
+	}
+
+	user, err := h.auth.userStorage.GetUserByUsername(r.Context(), claims.Username)
+	if err != nil {
+		log.Error("failed to get user", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return nil, false
+	}
+
+	return user, true
+}
+
+// verifyCurrentUser требует действующий код второго фактора для изменения его настроек
+func (h *TwoFactorHandler) verifyCurrentUser(w http.ResponseWriter, r *http.Request, log *slog.Logger) (*models.User, *models.UserTOTP, bool) {
+	user, ok := h.currentUser(w, r, log)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var req totpCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		log.Warn("invalid request body")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return nil, nil, false
+	}
+
+	t, err := h.auth.twoFactor.GetTOTP(r.Context(), user.ID)
+	if err == nil && !t.Enabled {
+		err = storage.ErrTOTPNotFound
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPNotFound) {
+			log.Warn("totp not enabled", slog.String("username", user.Username))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response.Error("totp is not enabled"))
+			return nil, nil, false
+		}
+		log.Error("failed to get totp", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return nil, nil, false
+	}
+
+	if err = h.auth.checkSecondFactor(r.Context(), t, req.Code, req.RecoveryCode); err != nil {
+		if errors.Is(err, errInvalidSecondFactor) {
+			log.Warn("invalid second factor", slog.String("username", user.Username))
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(response.Error("invalid code"))
+			return nil, nil, false
+		}
+		log.Error("failed to verify second factor", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return nil, nil, false
+	}
+
+	return user, t, true
+}
+
+func writeRecoveryCodes(w http.ResponseWriter, codes []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data recoveryCodesResponse `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     recoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"WarehouseControl/internal/storage/postgres"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// memoryTOTP повторяет условие UseTOTPStep: принимается только шаг новее последнего
+type memoryTOTP struct {
+	postgres.TwoFactorStorageI
+	lastStep   *int64
+	totp       *models.UserTOTP
+	challenges []*models.MFAChallenge
+}
+
+func (m *memoryTOTP) GetTOTP(ctx context.Context, userID int) (*models.UserTOTP, error) {
+	if m.totp == nil || m.totp.UserID != userID {
+		return nil, storage.ErrTOTPNotFound
+	}
+	return m.totp, nil
+}
+
+func (m *memoryTOTP) CreateMFAChallenge(ctx context.Context, challenge *models.MFAChallenge) error {
+	m.challenges = append(m.challenges, challenge)
+	return nil
+}
+
+func (m *memoryTOTP) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	if m.lastStep != nil && *m.lastStep >= step {
+		return storage.ErrTOTPCodeUsed
+	}
+	m.lastStep = &step
+	return nil
+}
+
+// totpCode - код RFC 6238 для шага step
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func TestCheckSecondFactorRejectsReplay(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	h := &AuthHandler{twoFactor: &memoryTOTP{}}
+	user := &models.UserTOTP{UserID: 1, Secret: secret, Enabled: true}
+	current := time.Now().Unix() / 30
+
+	tests := []struct {
+		name string
+		code string
+		err  error
+	}{
+		{"fresh code", totpCode(t, secret, current), nil},
+		{"same code again", totpCode(t, secret, current), errInvalidSecondFactor},
+		{"earlier step within skew", totpCode(t, secret, current-1), errInvalidSecondFactor},
+		{"next step", totpCode(t, secret, current+1), nil},
+		{"next step again", totpCode(t, secret, current+1), errInvalidSecondFactor},
+		{"wrong code", "000000", errInvalidSecondFactor},
+	}
+
+	// Проверки идут по порядку: каждая зависит от шага, принятого предыдущими
+	for _, tt := range tests {
+		err := h.checkSecondFactor(context.Background(), user, tt.code, "")
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: err = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/http-server/handlers/middleware"
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/lib/passwords"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"WarehouseControl/internal/storage/postgres"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+type UsersHandler struct {
+	userStorage  postgres.UserStorageI
+	tokenStorage postgres.TokenStorageI
+	passwords    *passwords.Hasher
+	validate     *validator.Validate
+	log          *slog.Logger
+}
+
+func NewUsersHandler(userStorage postgres.UserStorageI, tokenStorage postgres.TokenStorageI, hasher *passwords.Hasher, log *slog.Logger) *UsersHandler {
+	return &UsersHandler{
+		userStorage:  userStorage,
+		tokenStorage: tokenStorage,
+		passwords:    hasher,
+		validate:     validator.New(),
+		log:          log,
+	}
+}
+
+type createUserRequest struct {
+	Username string          `json:"username" validate:"required,min=3,max=50"`
+	Password string          `json:"password" validate:"required"`
+	Role     models.UserRole `json:"role" validate:"required,oneof=admin manager viewer"`
+}
+
+type updateUserRoleRequest struct {
+	Role models.UserRole `json:"role" validate:"required,oneof=admin manager viewer"`
+}
+
+func (h *UsersHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.users.ListUsers"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	users, err := h.userStorage.ListUsers(r.Context())
+	if err != nil {
+		log.Error("failed to get users", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get users"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data []*models.User `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     users,
+	})
+}
+
+func (h *UsersHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.users.CreateUser"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	var req createUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn("invalid request body", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return
+	}
+
+	if err := h.passwords.Check(req.Username, req.Password); err != nil {
+		log.Warn("password rejected by policy", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+		return
+	}
+
+	hash, err := h.passwords.Hash(req.Password)
+	if err != nil {
+		log.Error("failed to hash password", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("internal server error"))
+		return
+	}
+
+	user := &models.User{
+		Username:     req.Username,
+		PasswordHash: hash,
+		Role:         req.Role,
+	}
+
+	if err = h.userStorage.CreateUser(r.Context(), user); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Warn("user already exists", slog.String("username", req.Username))
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(response.Error("user already exists"))
+			return
+		}
+		if errors.Is(err, storage.ErrUsernameReserved) {
+			log.Warn("username is reserved", slog.String("username", req.Username))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response.Error(storage.ErrUsernameReserved.Error()))
+			return
+		}
+		log.Error("failed to create user", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to create user"))
+		return
+	}
+
+	log.Info("user created",
+		slog.String("username", user.Username),
+		slog.String("role", string(user.Role)),
+		slog.String("created_by", h.actor(r)),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.User `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     user,
+	})
+}
+
+func (h *UsersHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.users.UpdateUserRole"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := h.userID(w, r, log)
+	if !ok {
+		return
+	}
+
+	var req updateUserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn("invalid request body", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return
+	}
+
+	if err := h.userStorage.UpdateUserRole(r.Context(), id, req.Role); err != nil {
+		h.writeStorageError(w, log, err, "failed to update user role")
+		return
+	}
+
+	// Старые токены содержат прежнюю роль - отзываем их
+	h.revokeSessions(r, log, id)
+
+	log.Info("user role updated",
+		slog.Int("user_id", id),
+		slog.String("role", string(req.Role)),
+		slog.String("changed_by", h.actor(r)),
+	)
+
+	h.writeUser(w, r, log, id)
+}
+
+func (h *UsersHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, "handlers.users.DisableUser", true)
+}
+
+func (h *UsersHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, "handlers.users.EnableUser", false)
+}
+
+func (h *UsersHandler) setDisabled(w http.ResponseWriter, r *http.Request, op string, disabled bool) {
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := h.userID(w, r, log)
+	if !ok {
+		return
+	}
+
+	if err := h.userStorage.SetUserDisabled(r.Context(), id, disabled); err != nil {
+		h.writeStorageError(w, log, err, "failed to update user status")
+		return
+	}
+
+	if disabled {
+		h.revokeSessions(r, log, id)
+	}
+
+	log.Info("user status updated",
+		slog.Int("user_id", id),
+		slog.Bool("disabled", disabled),
+		slog.String("changed_by", h.actor(r)),
+	)
+
+	h.writeUser(w, r, log, id)
+}
+
+func (h *UsersHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.users.DeleteUser"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := h.userID(w, r, log)
+	if !ok {
+		return
+	}
+
+	if err := h.userStorage.DeleteUser(r.Context(), id); err != nil {
+		h.writeStorageError(w, log, err, "failed to delete user")
+		return
+	}
+
+	log.Info("user deleted",
+		slog.Int("user_id", id),
+		slog.String("deleted_by", h.actor(r)),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
+
+// RevokeSessions завершает все сессии пользователя
+func (h *UsersHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.users.RevokeSessions"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := h.userID(w, r, log)
+	if !ok {
+		return
+	}
+
+	if _, err := h.userStorage.GetUserByID(r.Context(), id); err != nil {
+		h.writeStorageError(w, log, err, "failed to revoke sessions")
+		return
+	}
+
+	revoked, err := h.tokenStorage.RevokeUserSessions(r.Context(), id)
+	if err != nil {
+		log.Error("failed to revoke sessions", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to revoke sessions"))
+		return
+	}
+
+	log.Info("user sessions revoked",
+		slog.Int("user_id", id),
+		slog.Int("sessions", revoked),
+		slog.String("revoked_by", h.actor(r)),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data struct {
+			Revoked int `json:"revoked"`
+		} `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data: struct {
+			Revoked int `json:"revoked"`
+		}{Revoked: revoked},
+	})
+}
+
+func (h *UsersHandler) revokeSessions(r *http.Request, log *slog.Logger, id int) {
+	if _, err := h.tokenStorage.RevokeUserSessions(r.Context(), id); err != nil {
+		log.Error("failed to revoke sessions", slog.Int("user_id", id), slog.String("error", err.Error()))
+	}
+}
+
+func (h *UsersHandler) userID(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int, bool) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Warn("invalid user id", slog.String("id", idStr))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid user id"))
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *UsersHandler) writeUser(w http.ResponseWriter, r *http.Request, log *slog.Logger, id int) {
+	user, err := h.userStorage.GetUserByID(r.Context(), id)
+	if err != nil {
+		h.writeStorageError(w, log, err, "failed to get user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.User `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     user,
+	})
+}
+
+func (h *UsersHandler) writeStorageError(w http.ResponseWriter, log *slog.Logger, err error, msg string) {
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound):
+		log.Warn("user not found")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(response.Error("user not found"))
+	case errors.Is(err, storage.ErrLastAdmin):
+		log.Warn("last admin protected", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(response.Error(err.Error()))
+	default:
+		log.Error(msg, slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error(msg))
+	}
+}
+
+func (h *UsersHandler) actor(r *http.Request) string {
+	claims, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		return ""
+	}
+	return claims.Username
+}
@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"WarehouseControl/internal/lib/api/response"
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"WarehouseControl/internal/storage/postgres"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+// locationCodeRe - коды складов и мест печатаются на стеллажных этикетках
+var locationCodeRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+type WarehousesHandler struct {
+	warehouseStorage postgres.WarehouseStorageI
+	validate         *validator.Validate
+	log              *slog.Logger
+}
+
+func NewWarehousesHandler(warehouseStorage postgres.WarehouseStorageI, log *slog.Logger) *WarehousesHandler {
+	validate := validator.New()
+	validate.RegisterValidation("locationcode", func(fl validator.FieldLevel) bool {
+		return locationCodeRe.MatchString(fl.Field().String())
+	})
+
+	return &WarehousesHandler{
+		warehouseStorage: warehouseStorage,
+		validate:         validate,
+		log:              log,
+	}
+}
+
+type warehouseRequest struct {
+	Code string `json:"code" validate:"required,max=32,locationcode"`
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type createLocationRequest struct {
+	ParentID *int                `json:"parent_id"`
+	Kind     models.LocationKind `json:"kind" validate:"required,oneof=zone aisle rack bin"`
+	Code     string              `json:"code" validate:"required,max=64,locationcode"`
+	Name     string              `json:"name" validate:"max=100"`
+}
+
+type updateLocationRequest struct {
+	Code string `json:"code" validate:"required,max=64,locationcode"`
+	Name string `json:"name" validate:"max=100"`
+}
+
+func (h *WarehousesHandler) ListWarehouses(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.warehouses.ListWarehouses"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	warehouses, err := h.warehouseStorage.ListWarehouses(r.Context())
+	if err != nil {
+		log.Error("failed to get warehouses", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get warehouses"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data []*models.Warehouse `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     warehouses,
+	})
+}
+
+func (h *WarehousesHandler) CreateWarehouse(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.warehouses.CreateWarehouse"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	var req warehouseRequest
+	if !h.decode(w, r, log, &req) {
+		return
+	}
+
+	warehouse := &models.Warehouse{Code: req.Code, Name: req.Name}
+	if err := h.warehouseStorage.CreateWarehouse(r.Context(), warehouse); err != nil {
+		h.writeError(w, log, err, "failed to create warehouse")
+		return
+	}
+
+	log.Info("warehouse created", slog.String("code", warehouse.Code))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.Warehouse `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     warehouse,
+	})
+}
+
+func (h *WarehousesHandler) UpdateWarehouse(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.warehouses.UpdateWarehouse"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid warehouse id")
+	if !ok {
+		return
+	}
+
+	var req warehouseRequest
+	if !h.decode(w, r, log, &req) {
+		return
+	}
+
+	warehouse := &models.Warehouse{ID: id, Code: req.Code, Name: req.Name}
+	if err := h.warehouseStorage.UpdateWarehouse(r.Context(), warehouse); err != nil {
+		h.writeError(w, log, err, "failed to update warehouse")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.Warehouse `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     warehouse,
+	})
+}
+
+func (h *WarehousesHandler) DeleteWarehouse(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.warehouses.DeleteWarehouse"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid warehouse id")
+	if !ok {
+		return
+	}
+
+	if err := h.warehouseStorage.DeleteWarehouse(r.Context(), id); err != nil {
+		h.writeError(w, log, err, "failed to delete warehouse")
+		return
+	}
+
+	log.Info("warehouse deleted", slog.Int("id", id))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
+
+func (h *WarehousesHandler) ListLocations(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.warehouses.ListLocations"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid warehouse id")
+	if !ok {
+		return
+	}
+
+	if _, err := h.warehouseStorage.GetWarehouseByID(r.Context(), id); err != nil {
+		h.writeError(w, log, err, "failed to get warehouse")
+		return
+	}
+
+	locations, err := h.warehouseStorage.ListLocations(r.Context(), id)
+	if err != nil {
+		log.Error("failed to get locations", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error("failed to get locations"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data []*models.Location `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     locations,
+	})
+}
+
+func (h *WarehousesHandler) CreateLocation(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.warehouses.CreateLocation"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid warehouse id")
+	if !ok {
+		return
+	}
+
+	var req createLocationRequest
+	if !h.decode(w, r, log, &req) {
+		return
+	}
+
+	location := &models.Location{
+		WarehouseID: id,
+		ParentID:    req.ParentID,
+		Kind:        req.Kind,
+		Code:        req.Code,
+		Name:        req.Name,
+	}
+	if err := h.warehouseStorage.CreateLocation(r.Context(), location); err != nil {
+		h.writeError(w, log, err, "failed to create location")
+		return
+	}
+
+	log.Info("location created", slog.Int("warehouse_id", id), slog.String("code", location.Code))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.Location `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     location,
+	})
+}
+
+func (h *WarehousesHandler) UpdateLocation(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.warehouses.UpdateLocation"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid location id")
+	if !ok {
+		return
+	}
+
+	var req updateLocationRequest
+	if !h.decode(w, r, log, &req) {
+		return
+	}
+
+	location := &models.Location{ID: id, Code: req.Code, Name: req.Name}
+	if err := h.warehouseStorage.UpdateLocation(r.Context(), location); err != nil {
+		h.writeError(w, log, err, "failed to update location")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data *models.Location `json:"data,omitempty"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     location,
+	})
+}
+
+func (h *WarehousesHandler) DeleteLocation(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.warehouses.DeleteLocation"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid location id")
+	if !ok {
+		return
+	}
+
+	if err := h.warehouseStorage.DeleteLocation(r.Context(), id); err != nil {
+		h.writeError(w, log, err, "failed to delete location")
+		return
+	}
+
+	log.Info("location deleted", slog.Int("id", id))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response.OK())
+}
+
+// GetLocationStock возвращает остатки всех товаров в месте хранения
+func (h *WarehousesHandler) GetLocationStock(w http.ResponseWriter, r *http.Request) {
+	const op = "handlers.warehouses.GetLocationStock"
+
+	log := h.log.With(
+		slog.String("op", op),
+		slog.String("request_id", r.Header.Get("X-Request-ID")),
+	)
+
+	id, ok := urlID(w, r, log, "id", "invalid location id")
+	if !ok {
+		return
+	}
+
+	stock, err := h.warehouseStorage.GetLocationStock(r.Context(), id)
+	if err != nil {
+		h.writeError(w, log, err, "failed to get location stock")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		response.Response
+		Data []models.ItemStock `json:"data"`
+	}{
+		Response: response.Response{Status: response.StatusOK},
+		Data:     stock,
+	})
+}
+
+// decode читает и валидирует тело запроса, при ошибке отвечает 400
+func (h *WarehousesHandler) decode(w http.ResponseWriter, r *http.Request, log *slog.Logger, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		log.Warn("invalid request body", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error("invalid request body"))
+		return false
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		var validateErr validator.ValidationErrors
+		errors.As(err, &validateErr)
+		log.Warn("invalid request", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.ValidationError(validateErr))
+		return false
+	}
+
+	return true
+}
+
+// writeError переводит ошибки storage складов и мест в коды ответа
+func (h *WarehousesHandler) writeError(w http.ResponseWriter, log *slog.Logger, err error, msg string) {
+	var status int
+	switch {
+	case errors.Is(err, storage.ErrWarehouseNotFound), errors.Is(err, storage.ErrLocationNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, storage.ErrWarehouseExists), errors.Is(err, storage.ErrLocationExists),
+		errors.Is(err, storage.ErrWarehouseInUse), errors.Is(err, storage.ErrLocationInUse):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrInvalidParent):
+		status = http.StatusBadRequest
+	default:
+		log.Error(msg, slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(response.Error(msg))
+		return
+	}
+
+	log.Warn(msg, slog.String("error", err.Error()))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response.Error(err.Error()))
+}
+
+func urlID(w http.ResponseWriter, r *http.Request, log *slog.Logger, param, msg string) (int, bool) {
+	idStr := chi.URLParam(r, param)
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		log.Warn(msg, slog.String(param, idStr))
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response.Error(msg))
+		return 0, false
+	}
+	return id, true
+}
@@ -38,6 +38,10 @@ func ValidationError(errs validator.ValidationErrors) Response {
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is a required field", err.Field()))
 		case "url":
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not a valid URL", err.Field()))
+		case "sku":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s may contain only letters, digits, '.', '_' and '-'", err.Field()))
+		case "barcode":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not a valid EAN/UPC barcode", err.Field()))
 		default:
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not valid", err.Field()))
 		}
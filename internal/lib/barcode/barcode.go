@@ -0,0 +1,32 @@
+// Package barcode проверяет штрихкоды семейства GTIN: EAN-8, UPC-A, EAN-13 и GTIN-14
+package barcode
+
+// Valid сообщает, что code - штрихкод GTIN допустимой длины с верной контрольной цифрой
+func Valid(code string) bool {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return checkDigit(code[:len(code)-1]) == code[len(code)-1]
+}
+
+// checkDigit считает контрольную цифру GTIN: веса 3 и 1 чередуются справа налево
+func checkDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,137 @@
+// Package export пишет выгрузки в CSV, XLSX и NDJSON построчно, без накопления в памяти
+package export
+
+import (
+	"WarehouseControl/internal/lib/xlsx"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatXLSX   Format = "xlsx"
+	FormatNDJSON Format = "ndjson"
+)
+
+var ErrUnsupportedFormat = errors.New("format must be csv, xlsx or ndjson")
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatXLSX, FormatNDJSON:
+		return f, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// Record - строка выгрузки. Cells - значения колонок таблицы в порядке заголовков,
+// в NDJSON запись сериализуется целиком через encoding/json
+type Record interface {
+	Cells() []interface{}
+}
+
+// Writer пишет записи в выбранном формате. Заголовки колонок пишутся сразу
+// при создании, для NDJSON не используются
+type Writer struct {
+	format Format
+	csv    *csv.Writer
+	xlsx   *xlsx.Writer
+	json   *json.Encoder
+}
+
+func NewWriter(w io.Writer, format Format, name string, header []string) (*Writer, error) {
+	ew := &Writer{format: format}
+
+	switch format {
+	case FormatCSV:
+		// BOM нужен Excel, чтобы открыть файл в UTF-8, а не в кодировке системы
+		if _, err := io.WriteString(w, "\xef\xbb\xbf"); err != nil {
+			return nil, err
+		}
+		ew.csv = csv.NewWriter(w)
+		if err := ew.csv.Write(header); err != nil {
+			return nil, err
+		}
+	case FormatXLSX:
+		xw, err := xlsx.NewWriter(w, name)
+		if err != nil {
+			return nil, err
+		}
+		cells := make([]interface{}, len(header))
+		for i, h := range header {
+			cells[i] = h
+		}
+		if err = xw.WriteRow(cells); err != nil {
+			return nil, err
+		}
+		ew.xlsx = xw
+	case FormatNDJSON:
+		ew.json = json.NewEncoder(w)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	return ew, nil
+}
+
+func (w *Writer) Write(r Record) error {
+	switch w.format {
+	case FormatCSV:
+		cells := r.Cells()
+		rec := make([]string, len(cells))
+		for i, c := range cells {
+			rec[i] = formatCell(c)
+		}
+		return w.csv.Write(rec)
+	case FormatXLSX:
+		return w.xlsx.WriteRow(r.Cells())
+	default:
+		return w.json.Encode(r)
+	}
+}
+
+// Close дописывает буферизованные строки и завершает файл
+func (w *Writer) Close() error {
+	switch w.format {
+	case FormatCSV:
+		w.csv.Flush()
+		return w.csv.Error()
+	case FormatXLSX:
+		return w.xlsx.Close()
+	}
+	return nil
+}
+
+func formatCell(v interface{}) string {
+	switch c := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return c
+	case int:
+		return strconv.Itoa(c)
+	case float64:
+		return strconv.FormatFloat(c, 'f', -1, 64)
+	case time.Time:
+		return c.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(c)
+	}
+}
@@ -0,0 +1,273 @@
+// Package itemimport разбирает CSV и XLSX файлы импорта товаров в строки ImportRow
+// и проверяет значения по тем же правилам, что и API товаров
+package itemimport
+
+import (
+	"WarehouseControl/internal/lib/barcode"
+	"WarehouseControl/internal/lib/xlsx"
+	"WarehouseControl/internal/models"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+const (
+	CSVContentType  = "text/csv"
+	XLSXContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// Fields - поля товара, которые можно импортировать
+var Fields = []string{"sku", "name", "quantity", "barcodes"}
+
+// Mapping - заголовок колонки файла для поля товара. Поле без сопоставления
+// ищется в колонке с заголовком, равным имени поля, без учета регистра
+type Mapping map[string]string
+
+type Options struct {
+	Format  Format
+	Mapping Mapping
+	MatchBy models.ImportMatch
+}
+
+var ErrInvalidFile = errors.New("invalid import file")
+
+// barcodeSeparator - штрихкоды в ячейке разделяются запятой, точкой с запятой или пробелами
+var barcodeSeparator = regexp.MustCompile(`[\s,;]+`)
+
+// Parse читает таблицу: первая непустая строка - заголовки, остальные - товары.
+// Ошибки отдельных строк записываются в ImportRow.Errors, ошибка возвращается,
+// только если файл нельзя разобрать целиком
+func Parse(data []byte, opts Options) ([]models.ImportRow, error) {
+	var records [][]string
+	var err error
+	switch opts.Format {
+	case FormatCSV:
+		records, err = readCSV(data)
+	case FormatXLSX:
+		records, err = xlsx.ReadRows(data)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidFile, opts.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+
+	header := -1
+	for i, rec := range records {
+		if !isEmpty(rec) {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidFile)
+	}
+
+	columns, err := resolveColumns(records[header], opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := columns[string(opts.MatchBy)]; !ok {
+		return nil, fmt.Errorf("%w: column for %s is required to match items", ErrInvalidFile, opts.MatchBy)
+	}
+
+	var rows []models.ImportRow
+	seen := make(map[string]int)
+	for i := header + 1; i < len(records); i++ {
+		if isEmpty(records[i]) {
+			continue
+		}
+
+		row := parseRow(records[i], columns)
+		row.Line = i + 1
+
+		key := row.SKU
+		if opts.MatchBy == models.ImportMatchName {
+			key = strings.ToLower(row.Name)
+		}
+		if key == "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("%s is required", opts.MatchBy))
+		} else if line, ok := seen[key]; ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate %s, first seen on line %d", opts.MatchBy, line))
+		} else {
+			seen[key] = row.Line
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// resolveColumns возвращает индекс колонки для каждого найденного поля
+func resolveColumns(header []string, mapping Mapping) (map[string]int, error) {
+	for field := range mapping {
+		if !isField(field) {
+			return nil, fmt.Errorf("%w: unknown field %q in column mapping", ErrInvalidFile, field)
+		}
+	}
+
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	columns := make(map[string]int)
+	for _, field := range Fields {
+		name, mapped := mapping[field]
+		if !mapped {
+			name = field
+		}
+		i, ok := index[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			if mapped {
+				return nil, fmt.Errorf("%w: column %q for %s not found", ErrInvalidFile, name, field)
+			}
+			continue
+		}
+		columns[field] = i
+	}
+
+	return columns, nil
+}
+
+func parseRow(rec []string, columns map[string]int) models.ImportRow {
+	var row models.ImportRow
+	value := func(field string) (string, bool) {
+		i, ok := columns[field]
+		if !ok || i >= len(rec) {
+			return "", false
+		}
+		v := strings.TrimSpace(rec[i])
+		return v, v != ""
+	}
+
+	if v, ok := value("sku"); ok {
+		row.SKU = v
+		if len(v) > 64 {
+			row.Errors = append(row.Errors, "sku is longer than 64 characters")
+		} else if !models.ValidSKU(v) {
+			row.Errors = append(row.Errors, "sku may contain only letters, digits, '.', '_' and '-'")
+		}
+	}
+
+	if v, ok := value("name"); ok {
+		row.Name = v
+		if utf8.RuneCountInString(v) > 100 {
+			row.Errors = append(row.Errors, "name is longer than 100 characters")
+		}
+	}
+
+	if v, ok := value("quantity"); ok {
+		quantity, err := parseQuantity(v)
+		if err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		} else {
+			row.Quantity = &quantity
+		}
+	}
+
+	if v, ok := value("barcodes"); ok {
+		barcodes := barcodeSeparator.Split(v, -1)
+		seen := make(map[string]bool, len(barcodes))
+		for _, code := range barcodes {
+			if !barcode.Valid(code) {
+				row.Errors = append(row.Errors, fmt.Sprintf("barcode %s is not a valid EAN/UPC barcode", code))
+			}
+			if seen[code] {
+				row.Errors = append(row.Errors, fmt.Sprintf("barcode %s is repeated", code))
+			}
+			seen[code] = true
+		}
+		if len(barcodes) > 20 {
+			row.Errors = append(row.Errors, "more than 20 barcodes")
+		}
+		row.Barcodes = &barcodes
+	}
+
+	return row
+}
+
+// parseQuantity принимает неотрицательное число до тысячных, в том числе с запятой
+// и в записи Excel вроде 12.0 или 1.2E+3
+func parseQuantity(v string) (models.Quantity, error) {
+	v = strings.ReplaceAll(v, ",", ".")
+	quantity, err := models.ParseQuantity(v)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(v, 64)
+		if ferr != nil || math.Abs(f*1000-math.Round(f*1000)) > 1e-6 || math.Abs(f) >= 1e15 {
+			return 0, fmt.Errorf("quantity %q is not a number with at most %d decimal places", v, models.QuantityScale)
+		}
+		quantity = models.QuantityFromFloat(f)
+	}
+	if quantity < 0 {
+		return 0, errors.New("quantity must not be negative")
+	}
+	return quantity, nil
+}
+
+// readCSV определяет разделитель по строке заголовков: Excel с русской локалью
+// сохраняет CSV через точку с запятой. Запись кладется по индексу строки файла,
+// на которой начинается, чтобы номера строк в отчете совпадали с файлом
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	firstLine := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		firstLine = data[:i]
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		r.Comma = ';'
+	}
+
+	var records [][]string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		for len(records) < line-1 {
+			records = append(records, nil)
+		}
+		records = append(records, rec)
+	}
+}
+
+func isEmpty(rec []string) bool {
+	for _, v := range rec {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func isField(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,405 @@
+// Package jsonpatch применяет к JSON документам RFC 7396 JSON Merge Patch
+// и RFC 6902 JSON Patch
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	ErrInvalidPatch = errors.New("invalid patch")
+	ErrTestFailed   = errors.New("patch test operation failed")
+)
+
+// MergePatch применяет merge patch: поля патча заменяют поля документа,
+// null удаляет поле, вложенные объекты объединяются рекурсивно
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = merge(t[k], v)
+	}
+	return t
+}
+
+// operation - Value не указатель: для "value": null указатель остался бы nil,
+// и null нельзя было бы отличить от отсутствующего value
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply применяет JSON Patch - список операций add, remove, replace, move, copy, test.
+// Операции выполняются по порядку, ошибка любой из них отменяет весь патч
+func Apply(doc, patch []byte) ([]byte, error) {
+	root, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+
+	var ops []operation
+	if err = json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+	}
+
+	for i, op := range ops {
+		if root, err = applyOperation(root, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i, op.Op, err)
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+func applyOperation(root interface{}, op operation) (interface{}, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: path is required", ErrInvalidPatch)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("%w: value is required", ErrInvalidPatch)
+		}
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+		}
+		switch op.Op {
+		case "add":
+			return add(root, path, value)
+		case "replace":
+			return replace(root, path, value)
+		}
+		current, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, fmt.Errorf("%w: value at %q differs", ErrTestFailed, *op.Path)
+		}
+		return root, nil
+
+	case "remove":
+		return remove(root, path)
+
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: from is required", ErrInvalidPatch)
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if isPrefix(from, path) && len(from) < len(path) {
+				return nil, fmt.Errorf("%w: cannot move a value into itself", ErrInvalidPatch)
+			}
+			if root, err = remove(root, from); err != nil {
+				return nil, err
+			}
+		} else if value, err = deepCopy(value); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	}
+
+	return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+}
+
+func add(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(root, path, func(node interface{}, key string) (interface{}, error) {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			n[key] = value
+			return n, nil
+		case []interface{}:
+			if key == "-" {
+				return append(n, value), nil
+			}
+			i, err := index(key, len(n)+1)
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = value
+			return n, nil
+		}
+		return nil, fmt.Errorf("%w: cannot add to a scalar", ErrInvalidPatch)
+	})
+}
+
+func remove(root interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the document root", ErrInvalidPatch)
+	}
+	return update(root, path, func(node interface{}, key string) (interface{}, error) {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if _, ok := n[key]; !ok {
+				return nil, fmt.Errorf("%w: member %q not found", ErrInvalidPatch, key)
+			}
+			delete(n, key)
+			return n, nil
+		case []interface{}:
+			i, err := index(key, len(n))
+			if err != nil {
+				return nil, err
+			}
+			return append(n[:i], n[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("%w: cannot remove from a scalar", ErrInvalidPatch)
+	})
+}
+
+func replace(root interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(root, path, func(node interface{}, key string) (interface{}, error) {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if _, ok := n[key]; !ok {
+				return nil, fmt.Errorf("%w: member %q not found", ErrInvalidPatch, key)
+			}
+			n[key] = value
+			return n, nil
+		case []interface{}:
+			i, err := index(key, len(n))
+			if err != nil {
+				return nil, err
+			}
+			n[i] = value
+			return n, nil
+		}
+		return nil, fmt.Errorf("%w: cannot replace in a scalar", ErrInvalidPatch)
+	})
+}
+
+// update спускается по пути до родителя последнего элемента, применяет к нему fn
+// и собирает измененные узлы обратно: вставка в массив меняет сам срез
+func update(node interface{}, path []string, fn func(node interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	child, err := childOf(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+	child, err = update(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		n[path[0]] = child
+	case []interface{}:
+		i, _ := index(path[0], len(n))
+		n[i] = child
+	}
+	return node, nil
+}
+
+func get(node interface{}, path []string) (interface{}, error) {
+	for _, key := range path {
+		var err error
+		if node, err = childOf(node, key); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func childOf(node interface{}, key string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: member %q not found", ErrInvalidPatch, key)
+		}
+		return child, nil
+	case []interface{}:
+		i, err := index(key, len(n))
+		if err != nil {
+			return nil, err
+		}
+		return n[i], nil
+	}
+	return nil, fmt.Errorf("%w: path goes through a scalar at %q", ErrInvalidPatch, key)
+}
+
+// index разбирает индекс массива: без знака и ведущих нулей, меньше size
+func index(key string, size int) (int, error) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || (len(key) > 1 && key[0] == '0') || strings.HasPrefix(key, "+") {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrInvalidPatch, key)
+	}
+	if i >= size {
+		return 0, fmt.Errorf("%w: array index %d out of range", ErrInvalidPatch, i)
+	}
+	return i, nil
+}
+
+// parsePointer разбирает JSON Pointer (RFC 6901): "" - весь документ, "/a/0" - элемент
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equal сравнивает JSON значения по RFC 6902, 4.6: числа по значению (72 и 72.0 равны),
+// объекты без учета порядка членов, массивы поэлементно
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		return ok && normalizeNumber(x) == normalizeNumber(y)
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// normalizeNumber приводит JSON число к виду "цифры e порядок" без ведущих и хвостовых
+// нулей: 72, 72.0, 7.2e1 и 720e-1 дают "72e0". Сравнение точное, без перевода в float
+func normalizeNumber(n json.Number) string {
+	s := string(n)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	exp := 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return string(n)
+		}
+		s, exp = s[:i], e
+	}
+	intPart, frac, _ := strings.Cut(s, ".")
+	exp -= len(frac)
+
+	digits := strings.TrimLeft(intPart+frac, "0")
+	if digits == "" {
+		return "0"
+	}
+	trimmed := strings.TrimRight(digits, "0")
+	exp += len(digits) - len(trimmed)
+
+	return sign + trimmed + "e" + strconv.Itoa(exp)
+}
+
+// decode сохраняет числа как json.Number, чтобы не терять точность
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+func deepCopy(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
@@ -0,0 +1,190 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// sameJSON сравнивает документы без учета форматирования и порядка членов
+func sameJSON(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result is not JSON: %v: %s", err, got)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("want is not JSON: %v: %s", err, want)
+	}
+	return reflect.DeepEqual(g, w)
+}
+
+// Примеры из RFC 7396, приложение A
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{`{"quantity":40}`, `{"quantity":12345678901234567890}`, `{"quantity":12345678901234567890}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("MergePatch: %v", err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Fatalf("MergePatch(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	if _, err := MergePatch([]byte(`{}`), []byte(`{"a":`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Fatalf("err = %v, want ErrInvalidPatch", err)
+	}
+	if _, err := MergePatch([]byte(`{}`), []byte(`{} {}`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Fatalf("err = %v, want ErrInvalidPatch for trailing data", err)
+	}
+}
+
+// Примеры из RFC 6902, приложение A, и сравнение значений операцией test
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc, patch string
+		want       string
+		err        error
+	}{
+		{"add object member", `{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{"add array element", `{"foo":["bar","baz"]}`,
+			`[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{"add array end", `{"foo":["bar"]}`,
+			`[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, nil},
+		{"remove object member", `{"baz":"qux","foo":"bar"}`,
+			`[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{"remove array element", `{"foo":["bar","qux","baz"]}`,
+			`[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{"replace value", `{"baz":"qux","foo":"bar"}`,
+			`[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{"move value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil},
+		{"move array element", `{"foo":["all","grass","cows","eat"]}`,
+			`[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, nil},
+		{"copy value", `{"foo":{"bar":1}}`,
+			`[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`,
+			`{"foo":{"bar":1},"baz":{"bar":2}}`, nil},
+		{"test success", `{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`, nil},
+		{"test failure", `{"baz":"qux"}`,
+			`[{"op":"test","path":"/baz","value":"bar"}]`, "", ErrTestFailed},
+		{"add nested member", `{"foo":"bar"}`,
+			`[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`, nil},
+		{"ignore unrecognized elements", `{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`, nil},
+		{"add to nonexistent target", `{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz/bat","value":"qux"}]`, "", ErrInvalidPatch},
+		{"escape ordering", `{"/":9,"~1":10}`,
+			`[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`, nil},
+		{"string is not number", `{"/":9,"~1":10}`,
+			`[{"op":"test","path":"/~01","value":"10"}]`, "", ErrTestFailed},
+		{"add array value", `{"foo":["bar"]}`,
+			`[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, nil},
+		{"replace root", `{"foo":"bar"}`,
+			`[{"op":"replace","path":"","value":[1]}]`, `[1]`, nil},
+		{"patch is atomic", `{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":1},{"op":"remove","path":"/missing"}]`, "", ErrInvalidPatch},
+
+		{"test number by value", `{"quantity":72}`,
+			`[{"op":"test","path":"/quantity","value":72.0}]`, `{"quantity":72}`, nil},
+		{"test number with exponent", `{"quantity":72.50}`,
+			`[{"op":"test","path":"/quantity","value":7.25e1}]`, `{"quantity":72.5}`, nil},
+		{"test negative zero", `{"delta":0}`,
+			`[{"op":"test","path":"/delta","value":-0.0}]`, `{"delta":0}`, nil},
+		{"test different numbers", `{"quantity":72}`,
+			`[{"op":"test","path":"/quantity","value":72.001}]`, "", ErrTestFailed},
+		{"test large numbers exactly", `{"id":12345678901234567890}`,
+			`[{"op":"test","path":"/id","value":12345678901234567891}]`, "", ErrTestFailed},
+		{"test object ignores member order", `{"a":{"x":1,"y":[1,2]}}`,
+			`[{"op":"test","path":"/a","value":{"y":[1.0,2],"x":1}}]`, `{"a":{"x":1,"y":[1,2]}}`, nil},
+		{"test array order matters", `{"a":[1,2]}`,
+			`[{"op":"test","path":"/a","value":[2,1]}]`, "", ErrTestFailed},
+		{"test null", `{"a":null}`,
+			`[{"op":"test","path":"/a","value":null}]`, `{"a":null}`, nil},
+		{"replace with null", `{"category_id":3}`,
+			`[{"op":"replace","path":"/category_id","value":null}]`, `{"category_id":null}`, nil},
+
+		{"missing path", `{}`, `[{"op":"remove"}]`, "", ErrInvalidPatch},
+		{"missing value", `{}`, `[{"op":"add","path":"/a"}]`, "", ErrInvalidPatch},
+		{"unknown operation", `{}`, `[{"op":"merge","path":"/a","value":1}]`, "", ErrInvalidPatch},
+		{"move into itself", `{"a":{"b":{}}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, "", ErrInvalidPatch},
+		{"index with leading zero", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/01"}]`, "", ErrInvalidPatch},
+		{"index out of range", `{"a":[1,2]}`, `[{"op":"replace","path":"/a/2","value":3}]`, "", ErrInvalidPatch},
+		{"path without slash", `{"a":1}`, `[{"op":"remove","path":"a"}]`, "", ErrInvalidPatch},
+		{"remove root", `{"a":1}`, `[{"op":"remove","path":""}]`, "", ErrInvalidPatch},
+		{"patch is not array", `{}`, `{"op":"add","path":"/a","value":1}`, "", ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Fatalf("Apply = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"0", "0"},
+		{"-0", "0"},
+		{"0.000", "0"},
+		{"72", "72e0"},
+		{"72.0", "72e0"},
+		{"7.2e1", "72e0"},
+		{"720E-1", "72e0"},
+		{"7200", "72e2"},
+		{"0.125", "125e-3"},
+		{"-1.50", "-15e-1"},
+		{"1e+2", "1e2"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeNumber(json.Number(tt.in)); got != tt.want {
+			t.Errorf("normalizeNumber(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package jwtkeys
+
+import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/models"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minSecretLength - минимальная длина HS256 секрета (RFC 7518, 3.2)
+const minSecretLength = 32
+
+var (
+	ErrUnknownKey    = errors.New("unknown signing key")
+	ErrUnexpectedAlg = errors.New("unexpected signing algorithm")
+	ErrNoPrivateKey  = errors.New("active key has no private part")
+)
+
+type key struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeySet подписывает токены активным ключом и проверяет их любым
+// из сконфигурированных ключей по заголовку kid
+type KeySet struct {
+	issuer   string
+	audience string
+	active   *key
+	keys     map[string]*key
+	algs     []string
+}
+
+func Load(cfg config.Signing) (*KeySet, error) {
+	const op = "jwtkeys.Load"
+
+	ks := &KeySet{
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		keys:     make(map[string]*key, len(cfg.Keys)),
+	}
+
+	seenAlgs := make(map[string]bool)
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return nil, fmt.Errorf("%s: key id is empty", op)
+		}
+		if _, ok := ks.keys[kc.ID]; ok {
+			return nil, fmt.Errorf("%s: duplicate key id %q", op, kc.ID)
+		}
+
+		k, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", op, kc.ID, err)
+		}
+		ks.keys[k.id] = k
+
+		if !seenAlgs[k.method.Alg()] {
+			seenAlgs[k.method.Alg()] = true
+			ks.algs = append(ks.algs, k.method.Alg())
+		}
+	}
+
+	active, ok := ks.keys[cfg.ActiveKey]
+	if !ok {
+		return nil, fmt.Errorf("%s: active key %q: %w", op, cfg.ActiveKey, ErrUnknownKey)
+	}
+	if active.signKey == nil {
+		return nil, fmt.Errorf("%s: active key %q: %w", op, cfg.ActiveKey, ErrNoPrivateKey)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+func (ks *KeySet) Issuer() string {
+	return ks.issuer
+}
+
+func (ks *KeySet) Audience() string {
+	return ks.audience
+}
+
+// Sign проставляет iss и aud и подписывает claims активным ключом
+func (ks *KeySet) Sign(claims *models.JWTClaims) (string, error) {
+	claims.Issuer = ks.issuer
+	claims.Audience = jwt.ClaimStrings{ks.audience}
+
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.id
+
+	return token.SignedString(ks.active.signKey)
+}
+
+// Parse проверяет подпись ключом из заголовка kid, алгоритм этого ключа,
+// а также issuer, audience и срок действия токена
+func (ks *KeySet) Parse(tokenString string, claims *models.JWTClaims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, ks.keyfunc,
+		jwt.WithValidMethods(ks.algs),
+		jwt.WithIssuer(ks.issuer),
+		jwt.WithAudience(ks.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+}
+
+func (ks *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+
+	// Алгоритм определяется ключом, а не заголовком токена
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("%w: %s for key %q", ErrUnexpectedAlg, token.Method.Alg(), kid)
+	}
+
+	return k.verifyKey, nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает публичные ключи для проверки токенов другими сервисами.
+// Симметричные HS256 ключи не публикуются
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+
+	for _, k := range ks.keys {
+		switch pub := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: k.id,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: k.id,
+				Use: "sig",
+				Alg: k.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return set
+}
+
+func loadKey(kc config.SigningKey) (*key, error) {
+	k := &key{id: kc.ID}
+
+	switch kc.Algorithm {
+	case AlgHS256:
+		secret, err := loadSecret(kc)
+		if err != nil {
+			return nil, err
+		}
+		k.method = jwt.SigningMethodHS256
+		k.signKey = secret
+		k.verifyKey = secret
+
+	case AlgRS256:
+		k.method = jwt.SigningMethodRS256
+		if kc.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read private key: %w", err)
+			}
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse private key: %w", err)
+			}
+			k.signKey = priv
+			k.verifyKey = &priv.PublicKey
+		}
+		if kc.PublicKeyFile != "" {
+			pem, err := os.ReadFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read public key: %w", err)
+			}
+			pub, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse public key: %w", err)
+			}
+			k.verifyKey = pub
+		}
+
+	case AlgEdDSA:
+		k.method = jwt.SigningMethodEdDSA
+		if kc.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read private key: %w", err)
+			}
+			priv, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse private key: %w", err)
+			}
+			k.signKey = priv
+			k.verifyKey = priv.(crypto.Signer).Public()
+		}
+		if kc.PublicKeyFile != "" {
+			pem, err := os.ReadFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read public key: %w", err)
+			}
+			pub, err := jwt.ParseEdPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse public key: %w", err)
+			}
+			k.verifyKey = pub
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnexpectedAlg, kc.Algorithm)
+	}
+
+	if k.verifyKey == nil {
+		return nil, errors.New("neither private nor public key file is set")
+	}
+
+	return k, nil
+}
+
+func loadSecret(kc config.SigningKey) ([]byte, error) {
+	var secret string
+
+	switch {
+	case kc.SecretFile != "":
+		b, err := os.ReadFile(kc.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret file: %w", err)
+		}
+		secret = strings.TrimSpace(string(b))
+	case kc.SecretEnv != "":
+		secret = os.Getenv(kc.SecretEnv)
+	default:
+		return nil, errors.New("secret_file or secret_env must be set for HS256")
+	}
+
+	if len(secret) < minSecretLength {
+		return nil, fmt.Errorf("secret must be at least %d bytes", minSecretLength)
+	}
+
+	return []byte(secret), nil
+}
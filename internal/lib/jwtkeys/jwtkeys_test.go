@@ -0,0 +1,303 @@
+package jwtkeys
+
+import (
+	"WarehouseControl/internal/config"
+	"WarehouseControl/internal/models"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "warehouse-control"
+	testAudience = "warehouse-api"
+	testSecret   = "0123456789abcdef0123456789abcdef"
+)
+
+type testKeyFiles struct {
+	rsaKey     *rsa.PrivateKey
+	rsaPrivate string
+	rsaPublic  string
+}
+
+func writeRSAKeys(t *testing.T) testKeyFiles {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	priv, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := testKeyFiles{
+		rsaKey:     key,
+		rsaPrivate: filepath.Join(dir, "rsa.pem"),
+		rsaPublic:  filepath.Join(dir, "rsa.pub.pem"),
+	}
+	if err = os.WriteFile(files.rsaPrivate, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priv}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(files.rsaPublic, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// loadKeys - набор с HS256 ключом hs-1 и RS256 ключом rs-1, active - ключ подписи
+func loadKeys(t *testing.T, files testKeyFiles, active string) *KeySet {
+	t.Helper()
+	t.Setenv("JWTKEYS_TEST_SECRET", testSecret)
+
+	ks, err := Load(config.Signing{
+		Issuer:    testIssuer,
+		Audience:  testAudience,
+		ActiveKey: active,
+		Keys: []config.SigningKey{
+			{ID: "hs-1", Algorithm: AlgHS256, SecretEnv: "JWTKEYS_TEST_SECRET"},
+			{ID: "rs-1", Algorithm: AlgRS256, PrivateKeyFile: files.rsaPrivate},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return ks
+}
+
+func validClaims() *models.JWTClaims {
+	now := time.Now()
+	return &models.JWTClaims{
+		Username: "alice",
+		Role:     models.RoleManager,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "jti-1",
+			Issuer:    testIssuer,
+			Audience:  jwt.ClaimStrings{testAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+}
+
+// sign подписывает claims в обход KeySet, чтобы собрать заведомо неверный токен
+func sign(t *testing.T, method jwt.SigningMethod, kid string, claims *models.JWTClaims, key interface{}) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSignAndParse(t *testing.T) {
+	files := writeRSAKeys(t)
+
+	for _, active := range []string{"hs-1", "rs-1"} {
+		t.Run(active, func(t *testing.T) {
+			ks := loadKeys(t, files, active)
+
+			claims := validClaims()
+			claims.Issuer, claims.Audience = "", nil
+			s, err := ks.Sign(claims)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+
+			parsed := &models.JWTClaims{}
+			token, err := ks.Parse(s, parsed)
+			if err != nil || !token.Valid {
+				t.Fatalf("Parse: %v", err)
+			}
+			if token.Header["kid"] != active {
+				t.Fatalf("kid = %v, want %s", token.Header["kid"], active)
+			}
+			if parsed.Username != "alice" || parsed.Issuer != testIssuer || len(parsed.Audience) != 1 || parsed.Audience[0] != testAudience {
+				t.Fatalf("claims = %+v", parsed)
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	files := writeRSAKeys(t)
+	ks := loadKeys(t, files, "rs-1")
+	pubPEM, err := os.ReadFile(files.rsaPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withClaims := func(change func(c *models.JWTClaims)) *models.JWTClaims {
+		c := validClaims()
+		change(c)
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"unknown kid",
+			sign(t, jwt.SigningMethodRS256, "rs-2", validClaims(), files.rsaKey), ErrUnknownKey},
+		{"missing kid",
+			sign(t, jwt.SigningMethodRS256, "", validClaims(), files.rsaKey), ErrUnknownKey},
+		// Подмена алгоритма: HS256 с публичным RSA ключом в качестве секрета
+		{"hs256 with rsa kid",
+			sign(t, jwt.SigningMethodHS256, "rs-1", validClaims(), pubPEM), ErrUnexpectedAlg},
+		{"rs256 with hs kid",
+			sign(t, jwt.SigningMethodRS256, "hs-1", validClaims(), files.rsaKey), ErrUnexpectedAlg},
+		{"alg none",
+			sign(t, jwt.SigningMethodNone, "rs-1", validClaims(), jwt.UnsafeAllowNoneSignatureType), jwt.ErrTokenSignatureInvalid},
+		{"alg not configured",
+			sign(t, jwt.SigningMethodHS512, "hs-1", validClaims(), []byte(testSecret)), jwt.ErrTokenSignatureInvalid},
+		{"wrong hs secret",
+			sign(t, jwt.SigningMethodHS256, "hs-1", validClaims(), []byte("fedcba9876543210fedcba9876543210")), jwt.ErrTokenSignatureInvalid},
+		{"wrong issuer",
+			sign(t, jwt.SigningMethodRS256, "rs-1", withClaims(func(c *models.JWTClaims) { c.Issuer = "other" }), files.rsaKey),
+			jwt.ErrTokenInvalidIssuer},
+		{"missing issuer",
+			sign(t, jwt.SigningMethodRS256, "rs-1", withClaims(func(c *models.JWTClaims) { c.Issuer = "" }), files.rsaKey),
+			jwt.ErrTokenRequiredClaimMissing},
+		{"wrong audience",
+			sign(t, jwt.SigningMethodRS256, "rs-1", withClaims(func(c *models.JWTClaims) { c.Audience = jwt.ClaimStrings{"other"} }), files.rsaKey),
+			jwt.ErrTokenInvalidAudience},
+		{"missing audience",
+			sign(t, jwt.SigningMethodRS256, "rs-1", withClaims(func(c *models.JWTClaims) { c.Audience = nil }), files.rsaKey),
+			jwt.ErrTokenRequiredClaimMissing},
+		{"expired",
+			sign(t, jwt.SigningMethodRS256, "rs-1", withClaims(func(c *models.JWTClaims) {
+				c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+			}), files.rsaKey),
+			jwt.ErrTokenExpired},
+		{"missing exp",
+			sign(t, jwt.SigningMethodRS256, "rs-1", withClaims(func(c *models.JWTClaims) { c.ExpiresAt = nil }), files.rsaKey),
+			jwt.ErrTokenRequiredClaimMissing},
+		{"issued in the future",
+			sign(t, jwt.SigningMethodRS256, "rs-1", withClaims(func(c *models.JWTClaims) {
+				c.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Hour))
+			}), files.rsaKey),
+			jwt.ErrTokenUsedBeforeIssued},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := ks.Parse(tt.token, &models.JWTClaims{})
+			if err == nil || token.Valid {
+				t.Fatal("token accepted")
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// После ротации токены старого ключа проверяются по его публичной части
+func TestRotation(t *testing.T) {
+	files := writeRSAKeys(t)
+	old := loadKeys(t, files, "rs-1")
+
+	s, err := old.Sign(validClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := Load(config.Signing{
+		Issuer:    testIssuer,
+		Audience:  testAudience,
+		ActiveKey: "hs-2",
+		Keys: []config.SigningKey{
+			{ID: "hs-2", Algorithm: AlgHS256, SecretEnv: "JWTKEYS_TEST_SECRET"},
+			{ID: "rs-1", Algorithm: AlgRS256, PublicKeyFile: files.rsaPublic},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err = rotated.Parse(s, &models.JWTClaims{}); err != nil {
+		t.Fatalf("token of the previous key rejected: %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	files := writeRSAKeys(t)
+	t.Setenv("JWTKEYS_TEST_SECRET", testSecret)
+	t.Setenv("JWTKEYS_SHORT_SECRET", "short")
+
+	hs := config.SigningKey{ID: "hs-1", Algorithm: AlgHS256, SecretEnv: "JWTKEYS_TEST_SECRET"}
+
+	tests := []struct {
+		name string
+		cfg  config.Signing
+		err  error
+	}{
+		{"unknown active key", config.Signing{ActiveKey: "hs-2", Keys: []config.SigningKey{hs}}, ErrUnknownKey},
+		{"active key without private part", config.Signing{ActiveKey: "rs-1", Keys: []config.SigningKey{
+			{ID: "rs-1", Algorithm: AlgRS256, PublicKeyFile: files.rsaPublic}}}, ErrNoPrivateKey},
+		{"unknown algorithm", config.Signing{ActiveKey: "k", Keys: []config.SigningKey{
+			{ID: "k", Algorithm: "HS384", SecretEnv: "JWTKEYS_TEST_SECRET"}}}, ErrUnexpectedAlg},
+		{"duplicate key id", config.Signing{ActiveKey: "hs-1", Keys: []config.SigningKey{hs, hs}}, nil},
+		{"empty key id", config.Signing{ActiveKey: "", Keys: []config.SigningKey{{Algorithm: AlgHS256, SecretEnv: "JWTKEYS_TEST_SECRET"}}}, nil},
+		{"short secret", config.Signing{ActiveKey: "hs-1", Keys: []config.SigningKey{
+			{ID: "hs-1", Algorithm: AlgHS256, SecretEnv: "JWTKEYS_SHORT_SECRET"}}}, nil},
+		{"no key files", config.Signing{ActiveKey: "rs-1", Keys: []config.SigningKey{{ID: "rs-1", Algorithm: AlgRS256}}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.cfg)
+			if err == nil {
+				t.Fatal("config accepted")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// JWKS публикует только асимметричные ключи
+func TestJWKS(t *testing.T) {
+	files := writeRSAKeys(t)
+	set := loadKeys(t, files, "hs-1").JWKS()
+
+	if len(set.Keys) != 1 {
+		t.Fatalf("keys = %+v, want only rs-1", set.Keys)
+	}
+	k := set.Keys[0]
+	if k.Kid != "rs-1" || k.Kty != "RSA" || k.Alg != AlgRS256 || k.Use != "sig" {
+		t.Fatalf("key = %+v", k)
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || new(big.Int).SetBytes(n).Cmp(files.rsaKey.N) != 0 {
+		t.Fatalf("n does not match the public key")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || new(big.Int).SetBytes(e).Int64() != int64(files.rsaKey.E) {
+		t.Fatalf("e does not match the public key")
+	}
+}
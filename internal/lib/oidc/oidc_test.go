@@ -0,0 +1,161 @@
+package oidc
+
+import (
+	"WarehouseControl/internal/lib/oidc/oidctest"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID    = "warehouse-control"
+	testRedirectURL = "http://app.test/auth/oidc/callback"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Server) {
+	t.Helper()
+
+	srv := oidctest.NewServer(t, testClientID)
+	return NewProvider(srv.URL, testClientID, "", testRedirectURL, []string{"openid", "profile"}), srv
+}
+
+// RFC 7636, приложение B
+func TestCodeChallenge(t *testing.T) {
+	if got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Fatalf("CodeChallenge = %s", got)
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	p, srv := newTestProvider(t)
+	srv.Claims = map[string]interface{}{"preferred_username": "alice"}
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	for k, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid profile",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        CodeChallenge("verifier-1"),
+		"code_challenge_method": "S256",
+	} {
+		if got := q.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+
+	code, state := srv.Authorize(t, authURL)
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+
+	if _, err = p.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Fatal("exchange with wrong code_verifier must fail")
+	}
+
+	// Неудачный обмен погасил code, поэтому вход проходится заново
+	code, _ = srv.Authorize(t, authURL)
+	tokens, err := p.Exchange(ctx, code, "verifier-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if _, err = p.Exchange(ctx, code, "verifier-1"); err == nil {
+		t.Fatal("code must be accepted only once")
+	}
+
+	claims, err := p.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims["sub"] != "user-1" || claims["preferred_username"] != "alice" {
+		t.Fatalf("claims = %v", claims)
+	}
+
+	if _, err = p.VerifyIDToken(ctx, tokens.IDToken, "nonce-2"); !errors.Is(err, ErrNonceInvalid) {
+		t.Fatalf("err = %v, want ErrNonceInvalid", err)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	p, srv := newTestProvider(t)
+	ctx := context.Background()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(method jwt.SigningMethod, key interface{}, kid string) string {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{
+			"iss":   srv.URL,
+			"aud":   testClientID,
+			"sub":   "user-1",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": "nonce-1",
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"nonce mismatch", srv.IDToken(t, jwt.MapClaims{"nonce": "nonce-2"}), ErrNonceInvalid},
+		{"missing nonce", srv.IDToken(t, nil), ErrNonceInvalid},
+		{"wrong audience", srv.IDToken(t, jwt.MapClaims{"nonce": "nonce-1", "aud": "other-client"}), jwt.ErrTokenInvalidAudience},
+		{"audience list without client", srv.IDToken(t, jwt.MapClaims{"nonce": "nonce-1", "aud": []string{"a", "b"}}), jwt.ErrTokenInvalidAudience},
+		{"missing audience", srv.IDToken(t, jwt.MapClaims{"nonce": "nonce-1", "aud": nil}), jwt.ErrTokenRequiredClaimMissing},
+		{"wrong issuer", srv.IDToken(t, jwt.MapClaims{"nonce": "nonce-1", "iss": "https://evil.test"}), jwt.ErrTokenInvalidIssuer},
+		{"expired", srv.IDToken(t, jwt.MapClaims{"nonce": "nonce-1", "exp": time.Now().Add(-time.Minute).Unix()}), jwt.ErrTokenExpired},
+		{"missing exp", srv.IDToken(t, jwt.MapClaims{"nonce": "nonce-1", "exp": nil}), jwt.ErrTokenRequiredClaimMissing},
+		{"unknown kid", sign(jwt.SigningMethodRS256, otherKey, "other-key"), ErrUnknownKey},
+		{"foreign key with known kid", sign(jwt.SigningMethodRS256, otherKey, oidctest.KeyID), jwt.ErrTokenSignatureInvalid},
+		{"hmac", sign(jwt.SigningMethodHS256, []byte("client-secret-known-to-everyone!"), oidctest.KeyID), jwt.ErrTokenSignatureInvalid},
+		{"alg none", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, oidctest.KeyID), jwt.ErrTokenSignatureInvalid},
+		{"garbage", "not.a.jwt", jwt.ErrTokenMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.VerifyIDToken(ctx, tt.token, "nonce-1"); !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	srv := oidctest.NewServer(t, testClientID)
+	// Тот же сервер по другому имени: discovery доступен, но issuer в нем другой
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProvider("http://localhost:"+u.Port(), testClientID, "", testRedirectURL, nil)
+
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", "v"); err == nil {
+		t.Fatal("discovery of another issuer must fail")
+	}
+}
@@ -0,0 +1,230 @@
+// Package passwords хеширует пароли (argon2id или bcrypt) и проверяет политику паролей.
+// Хеши хранятся в самоописывающем формате, поэтому проверка работает для любого
+// поддерживаемого алгоритма, а хеши с устаревшими параметрами можно пересчитать при входе
+package passwords
+
+import (
+	"WarehouseControl/internal/config"
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+	// bcrypt учитывает только первые 72 байта пароля
+	bcryptMaxBytes = 72
+)
+
+var (
+	ErrMismatch    = errors.New("password does not match")
+	ErrUnknownHash = errors.New("unknown password hash format")
+	// ErrPolicy оборачивает все нарушения политики, текст ошибки можно показать пользователю
+	ErrPolicy = errors.New("password does not meet policy")
+)
+
+var b64 = base64.RawStdEncoding
+
+type Hasher struct {
+	cfg      config.Password
+	denyList map[string]struct{}
+}
+
+func New(cfg config.Password) (*Hasher, error) {
+	switch cfg.Algorithm {
+	case AlgorithmArgon2id:
+		if cfg.Argon2.Memory <= 0 || cfg.Argon2.Iterations <= 0 || cfg.Argon2.Parallelism <= 0 || cfg.Argon2.Parallelism > 255 {
+			return nil, errors.New("invalid argon2 parameters")
+		}
+	case AlgorithmBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password algorithm %q", cfg.Algorithm)
+	}
+
+	if cfg.MinLength < 1 || cfg.MaxLength < cfg.MinLength {
+		return nil, errors.New("invalid password length limits")
+	}
+
+	h := &Hasher{cfg: cfg, denyList: make(map[string]struct{})}
+	if cfg.DenyListFile != "" {
+		if err := h.loadDenyList(cfg.DenyListFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// loadDenyList читает список утекших паролей: один пароль в строке, регистр не учитывается
+func (h *Hasher) loadDenyList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open password deny list: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		h.denyList[strings.ToLower(line)] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read password deny list: %w", err)
+	}
+
+	return nil
+}
+
+// Hash хеширует пароль настроенным алгоритмом
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.cfg.Algorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cfg.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := h.argon2Params()
+	key := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.iterations, p.parallelism, b64.EncodeToString(salt), b64.EncodeToString(key),
+	), nil
+}
+
+// Verify сравнивает пароль с хешем. needsRehash сообщает, что хеш создан другим
+// алгоритмом или с другими параметрами и его стоит пересчитать
+func (h *Hasher) Verify(hash, password string) (needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		stored, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		actual := argon2.IDKey([]byte(password), salt, stored.iterations, stored.memory, stored.parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return false, ErrMismatch
+		}
+		return h.cfg.Algorithm != AlgorithmArgon2id || stored != h.argon2Params(), nil
+
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, ErrMismatch
+			}
+			return false, err
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, err
+		}
+		return h.cfg.Algorithm != AlgorithmBcrypt || cost != h.cfg.BcryptCost, nil
+	}
+
+	return false, ErrUnknownHash
+}
+
+// Check проверяет новый пароль по политике: длина, совпадение с именем
+// пользователя и список утекших паролей
+func (h *Hasher) Check(username, password string) error {
+	n := utf8.RuneCountInString(password)
+	if n < h.cfg.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrPolicy, h.cfg.MinLength)
+	}
+	if n > h.cfg.MaxLength || (h.cfg.Algorithm == AlgorithmBcrypt && len(password) > bcryptMaxBytes) {
+		return fmt.Errorf("%w: must be at most %d characters", ErrPolicy, h.cfg.MaxLength)
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		return fmt.Errorf("%w: must not match the username", ErrPolicy)
+	}
+	if _, ok := h.denyList[strings.ToLower(password)]; ok {
+		return fmt.Errorf("%w: password is known to be breached", ErrPolicy)
+	}
+	return nil
+}
+
+// CheckReuse запрещает пароль, совпадающий с текущим или одним из прежних хешей
+func (h *Hasher) CheckReuse(password string, hashes []string) error {
+	for _, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		if _, err := h.Verify(hash, password); err == nil {
+			return fmt.Errorf("%w: must not match one of the recent passwords", ErrPolicy)
+		}
+	}
+	return nil
+}
+
+// HistorySize - сколько прежних паролей хранится для проверки повторов
+func (h *Hasher) HistorySize() int {
+	return h.cfg.HistorySize
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func (h *Hasher) argon2Params() argon2Params {
+	return argon2Params{
+		memory:      uint32(h.cfg.Argon2.Memory),
+		iterations:  uint32(h.cfg.Argon2.Iterations),
+		parallelism: uint8(h.cfg.Argon2.Parallelism),
+	}
+}
+
+// decodeArgon2 разбирает хеш формата $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func decodeArgon2(hash string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,235 @@
+package passwords
+
+import (
+	"WarehouseControl/internal/config"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// referenceHash - вектор argon2id из эталонной реализации (phc-winner-argon2, test.c):
+// пароль "password", соль "somesalt", m=65536, t=2, p=1
+const referenceHash = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+
+func argon2Config(memory, iterations, parallelism int) config.Password {
+	return config.Password{
+		Algorithm: AlgorithmArgon2id,
+		Argon2:    config.Argon2{Memory: memory, Iterations: iterations, Parallelism: parallelism},
+		MinLength: 8,
+		MaxLength: 128,
+	}
+}
+
+func bcryptConfig(cost int) config.Password {
+	return config.Password{Algorithm: AlgorithmBcrypt, BcryptCost: cost, MinLength: 8, MaxLength: 128}
+}
+
+func newHasher(t *testing.T, cfg config.Password) *Hasher {
+	t.Helper()
+
+	h, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return h
+}
+
+func TestVerifyReferenceHash(t *testing.T) {
+	h := newHasher(t, argon2Config(65536, 2, 1))
+
+	rehash, err := h.Verify(referenceHash, "password")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if rehash {
+		t.Fatal("hash with configured parameters must not need rehash")
+	}
+
+	if _, err = h.Verify(referenceHash, "Password"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("err = %v, want ErrMismatch", err)
+	}
+}
+
+func TestHashVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.Password
+		prefix string
+	}{
+		{"argon2id", argon2Config(64, 1, 1), "$argon2id$v=19$m=64,t=1,p=1$"},
+		{"bcrypt", bcryptConfig(bcrypt.MinCost), "$2a$04$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHasher(t, tt.cfg)
+
+			hash, err := h.Hash("correct horse battery")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if !strings.HasPrefix(hash, tt.prefix) {
+				t.Fatalf("hash %q must start with %q", hash, tt.prefix)
+			}
+
+			other, err := h.Hash("correct horse battery")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			if hash == other {
+				t.Fatal("hashes of the same password must differ by salt")
+			}
+
+			rehash, err := h.Verify(hash, "correct horse battery")
+			if err != nil || rehash {
+				t.Fatalf("Verify = (%v, %v), want (false, nil)", rehash, err)
+			}
+			if _, err = h.Verify(hash, "correct horse batter"); !errors.Is(err, ErrMismatch) {
+				t.Fatalf("err = %v, want ErrMismatch", err)
+			}
+		})
+	}
+}
+
+// Хеш пересчитывается, если алгоритм или его параметры отличаются от настроенных
+func TestVerifyNeedsRehash(t *testing.T) {
+	const password = "correct horse battery"
+
+	hashWith := func(cfg config.Password) string {
+		hash, err := newHasher(t, cfg).Hash(password)
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+		return hash
+	}
+
+	tests := []struct {
+		name   string
+		stored config.Password
+		now    config.Password
+		rehash bool
+	}{
+		{"same argon2 parameters", argon2Config(64, 1, 1), argon2Config(64, 1, 1), false},
+		{"argon2 memory changed", argon2Config(64, 1, 1), argon2Config(128, 1, 1), true},
+		{"argon2 iterations changed", argon2Config(64, 1, 1), argon2Config(64, 2, 1), true},
+		{"argon2 parallelism changed", argon2Config(64, 1, 1), argon2Config(64, 1, 2), true},
+		{"bcrypt to argon2id", bcryptConfig(bcrypt.MinCost), argon2Config(64, 1, 1), true},
+		{"argon2id to bcrypt", argon2Config(64, 1, 1), bcryptConfig(bcrypt.MinCost), true},
+		{"same bcrypt cost", bcryptConfig(bcrypt.MinCost), bcryptConfig(bcrypt.MinCost), false},
+		{"bcrypt cost changed", bcryptConfig(bcrypt.MinCost), bcryptConfig(bcrypt.MinCost + 1), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rehash, err := newHasher(t, tt.now).Verify(hashWith(tt.stored), password)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if rehash != tt.rehash {
+				t.Fatalf("needsRehash = %v, want %v", rehash, tt.rehash)
+			}
+		})
+	}
+}
+
+func TestVerifyUnknownHash(t *testing.T) {
+	h := newHasher(t, argon2Config(64, 1, 1))
+
+	tests := []string{
+		"",
+		"password",
+		"$argon2i$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=16$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=2$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ",
+		"$argon2id$v=19$m=65536,t=2,p=1$!!!$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc",
+		"$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$",
+	}
+
+	for _, hash := range tests {
+		if _, err := h.Verify(hash, "password"); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("Verify(%q): err = %v, want ErrUnknownHash", hash, err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Password
+		ok   bool
+	}{
+		{"argon2id", argon2Config(64, 1, 1), true},
+		{"bcrypt", bcryptConfig(10), true},
+		{"zero memory", argon2Config(0, 1, 1), false},
+		{"zero iterations", argon2Config(64, 0, 1), false},
+		{"parallelism overflow", argon2Config(64, 1, 256), false},
+		{"bcrypt cost too low", bcryptConfig(bcrypt.MinCost - 1), false},
+		{"unknown algorithm", config.Password{Algorithm: "md5", MinLength: 8, MaxLength: 128}, false},
+		{"max below min", config.Password{Algorithm: AlgorithmBcrypt, BcryptCost: 10, MinLength: 8, MaxLength: 7}, false},
+	}
+
+	for _, tt := range tests {
+		if _, err := New(tt.cfg); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok = %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	denyList := filepath.Join(t.TempDir(), "deny.txt")
+	if err := os.WriteFile(denyList, []byte("# утекшие\nQwerty123\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := argon2Config(64, 1, 1)
+	cfg.MaxLength = 16
+	cfg.DenyListFile = denyList
+	h := newHasher(t, cfg)
+
+	tests := []struct {
+		username, password string
+		ok                 bool
+	}{
+		{"alice", "correct horse", true},
+		{"alice", "short", false},
+		{"alice", "пароль12", true},
+		{"alice", strings.Repeat("a", 17), false},
+		{"alice1234", "ALICE1234", false},
+		{"alice", "qwerty123", false},
+	}
+
+	for _, tt := range tests {
+		err := h.Check(tt.username, tt.password)
+		if tt.ok && err != nil {
+			t.Errorf("Check(%q, %q) = %v, want nil", tt.username, tt.password, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrPolicy) {
+			t.Errorf("Check(%q, %q) = %v, want ErrPolicy", tt.username, tt.password, err)
+		}
+	}
+}
+
+func TestCheckReuse(t *testing.T) {
+	h := newHasher(t, argon2Config(64, 1, 1))
+
+	old, err := h.Hash("old password 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := newHasher(t, bcryptConfig(bcrypt.MinCost)).Hash("old password 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := []string{"", old, legacy}
+
+	for password, reused := range map[string]bool{"old password 1": true, "old password 2": true, "new password": false} {
+		err := h.CheckReuse(password, hashes)
+		if reused != errors.Is(err, ErrPolicy) {
+			t.Errorf("CheckReuse(%q) = %v, want reused = %v", password, err, reused)
+		}
+	}
+}
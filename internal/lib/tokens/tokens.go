@@ -0,0 +1,31 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// Generate возвращает случайный непрозрачный токен (256 бит) в base64url
+func Generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ID возвращает случайный идентификатор (128 бит) в hex, например для jti
+func ID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Hash - sha256 от токена. Токены высокоэнтропийные, поэтому соль
+// и медленное хеширование не нужны, а поиск по хешу остается индексным
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,140 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret - ключ "12345678901234567890" из тестовых векторов RFC 4226 и RFC 6238 в base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 4226, приложение D
+func TestHOTP(t *testing.T) {
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, code := range want {
+		if got := hotp([]byte("12345678901234567890"), int64(counter)); got != code {
+			t.Errorf("hotp(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+// RFC 6238, приложение B (SHA1): коды из 8 цифр, шесть младших из них - наш код
+func TestValidateRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		code := tt.code[len(tt.code)-digits:]
+		step, ok := Validate(rfcSecret, code, time.Unix(tt.unix, 0))
+		if !ok {
+			t.Errorf("Validate(%s) at %d rejected", code, tt.unix)
+			continue
+		}
+		if want := tt.unix / 30; step != want {
+			t.Errorf("Validate(%s) at %d: step = %d, want %d", code, tt.unix, step, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / 30
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		ok     bool
+		step   int64
+	}{
+		{"current step", rfcSecret, hotp(key, current), true, current},
+		{"previous step", rfcSecret, hotp(key, current-1), true, current - 1},
+		{"next step", rfcSecret, hotp(key, current+1), true, current + 1},
+		{"two steps ago", rfcSecret, hotp(key, current-2), false, 0},
+		{"two steps ahead", rfcSecret, hotp(key, current+2), false, 0},
+		{"spaces around code", rfcSecret, " " + hotp(key, current) + "\n", true, current},
+		{"lowercase secret", strings.ToLower(rfcSecret), hotp(key, current), true, current},
+		{"wrong code", rfcSecret, "000000", false, 0},
+		{"short code", rfcSecret, hotp(key, current)[:5], false, 0},
+		{"eight digit code", rfcSecret, "14050471", false, 0},
+		{"invalid secret", "not base32!", hotp(key, current), false, 0},
+		{"empty code", rfcSecret, "", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, now)
+			if ok != tt.ok || step != tt.step {
+				t.Fatalf("Validate = (%d, %v), want (%d, %v)", step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := b32.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Fatalf("secret has %d bytes, want 20", len(key))
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI("Warehouse Control", "alice", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Warehouse Control:alice" {
+		t.Fatalf("uri = %s", uri)
+	}
+
+	q := uri.Query()
+	for k, want := range map[string]string{"secret": rfcSecret, "issuer": "Warehouse Control",
+		"algorithm": "SHA1", "digits": "6", "period": "30"} {
+		if got := q.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q must look like xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+		if got := NormalizeRecoveryCode(typed); got != code {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", typed, got, code)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package xlsx читает и пишет простые таблицы в формате Office Open XML:
+// один лист, текстовые и числовые ячейки без стилей и формул
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidFile = errors.New("invalid xlsx file")
+
+// ReadRows возвращает ячейки первого листа книги. Индекс строки в результате
+// на единицу меньше номера строки в Excel, пропущенные строки и ячейки пусты
+func ReadRows(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if shared, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, ok := files[sheetPath]
+	if !ok {
+		return nil, fmt.Errorf("%w: worksheet %s not found", ErrInvalidFile, sheetPath)
+	}
+	return readSheet(sheet, shared)
+}
+
+type workbook struct {
+	Sheets []struct {
+		ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// firstSheetPath находит файл первого листа по workbook.xml и его связям
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var wb workbook
+	if err := decodeFile(files["xl/workbook.xml"], &wb); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", fmt.Errorf("%w: workbook has no sheets", ErrInvalidFile)
+	}
+
+	var rels relationships
+	if err := decodeFile(files["xl/_rels/workbook.xml.rels"], &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != wb.Sheets[0].ID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+
+	return "", fmt.Errorf("%w: first sheet relationship not found", ErrInvalidFile)
+}
+
+// richText - текст ячейки или общей строки: простой <t> или набор фрагментов <r><t>
+type richText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t richText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+func readSharedStrings(f *zip.File) ([]string, error) {
+	var sst struct {
+		Items []richText `xml:"si"`
+	}
+	if err := decodeFile(f, &sst); err != nil {
+		return nil, err
+	}
+
+	shared := make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		shared[i] = si.String()
+	}
+	return shared, nil
+}
+
+type cell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline richText `xml:"is"`
+}
+
+func readSheet(f *zip.File, shared []string) ([][]string, error) {
+	var ws struct {
+		Rows []struct {
+			Num   int    `xml:"r,attr"`
+			Cells []cell `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodeFile(f, &ws); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, r := range ws.Rows {
+		num := r.Num
+		if num == 0 {
+			num = len(rows) + 1
+		}
+		if num < len(rows)+1 {
+			return nil, fmt.Errorf("%w: rows are out of order", ErrInvalidFile)
+		}
+		for len(rows) < num {
+			rows = append(rows, nil)
+		}
+
+		var row []string
+		for _, c := range r.Cells {
+			col := len(row)
+			if c.Ref != "" {
+				var err error
+				if col, err = columnIndex(c.Ref); err != nil {
+					return nil, err
+				}
+			}
+			value, err := cellValue(c, shared)
+			if err != nil {
+				return nil, err
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+			row[col] = value
+		}
+		rows[num-1] = row
+	}
+
+	return rows, nil
+}
+
+func cellValue(c cell, shared []string) (string, error) {
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(c.Value)
+		if err != nil || i < 0 || i >= len(shared) {
+			return "", fmt.Errorf("%w: bad shared string index in %s", ErrInvalidFile, c.Ref)
+		}
+		return shared[i], nil
+	case "inlineStr":
+		return c.Inline.String(), nil
+	case "b":
+		if c.Value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	}
+	return c.Value, nil
+}
+
+// columnIndex переводит ссылку на ячейку (AB12) в индекс колонки с нуля
+func columnIndex(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return 0, fmt.Errorf("%w: bad cell reference %q", ErrInvalidFile, ref)
+	}
+	return col - 1, nil
+}
+
+func decodeFile(f *zip.File, v interface{}) error {
+	if f == nil {
+		return fmt.Errorf("%w: required part is missing", ErrInvalidFile)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidFile, err)
+	}
+	defer rc.Close()
+
+	if err = xml.NewDecoder(io.LimitReader(rc, maxPartSize)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidFile, f.Name, err)
+	}
+	return nil
+}
+
+// maxPartSize ограничивает распакованный размер части книги: защита от zip-бомб
+const maxPartSize = 64 << 20
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKeyUsernamePrefix отличает ключи от пользователей в item_history.changed_by,
+// журнале движений и событиях входа. Имена пользователей с ним запрещены
+const APIKeyUsernamePrefix = "apikey:"
+
+type APIKey struct {
+	ID         int        `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"key_prefix"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Role       UserRole   `json:"role" db:"role"`
+	CreatedBy  string     `json:"created_by" db:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// Username - имя, от которого действует ключ
+func (k *APIKey) Username() string {
+	return APIKeyUsernamePrefix + k.Name
+}
+
+// IsAPIKeyUsername сообщает, что имя принадлежит API ключу, а не пользователю
+func IsAPIKeyUsername(username string) bool {
+	return strings.HasPrefix(username, APIKeyUsernamePrefix)
+}
+
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
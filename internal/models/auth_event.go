@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+type AuthEventType string
+
+const (
+	AuthEventLogin         AuthEventType = "login"
+	AuthEventLockout       AuthEventType = "lockout"
+	AuthEventMFAVerify     AuthEventType = "mfa_verify"
+	AuthEventOIDCLogin     AuthEventType = "oidc_login"
+	AuthEventRefresh       AuthEventType = "refresh"
+	AuthEventLogout        AuthEventType = "logout"
+	AuthEventTokenRejected AuthEventType = "token_rejected"
+)
+
+func (t AuthEventType) IsValid() bool {
+	switch t {
+	case AuthEventLogin, AuthEventLockout, AuthEventMFAVerify, AuthEventOIDCLogin,
+		AuthEventRefresh, AuthEventLogout, AuthEventTokenRejected:
+		return true
+	}
+	return false
+}
+
+type AuthOutcome string
+
+const (
+	AuthOutcomeSuccess AuthOutcome = "success"
+	AuthOutcomeFailure AuthOutcome = "failure"
+	// AuthOutcomeBlocked - попытка отклонена без проверки из-за блокировки
+	AuthOutcomeBlocked AuthOutcome = "blocked"
+	// AuthOutcomeChallenge - пароль верен, запрошен второй фактор
+	AuthOutcomeChallenge AuthOutcome = "challenge"
+)
+
+func (o AuthOutcome) IsValid() bool {
+	switch o {
+	case AuthOutcomeSuccess, AuthOutcomeFailure, AuthOutcomeBlocked, AuthOutcomeChallenge:
+		return true
+	}
+	return false
+}
+
+type AuthEvent struct {
+	ID        int64         `json:"id" db:"id"`
+	Type      AuthEventType `json:"type" db:"event_type"`
+	Outcome   AuthOutcome   `json:"outcome" db:"outcome"`
+	Username  string        `json:"username" db:"username"`
+	UserID    *int          `json:"user_id,omitempty" db:"user_id"`
+	IP        string        `json:"ip" db:"ip"`
+	UserAgent string        `json:"user_agent" db:"user_agent"`
+	RequestID string        `json:"request_id" db:"request_id"`
+	Reason    string        `json:"reason,omitempty" db:"reason"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
+
+// AuthEventFilter - фильтры выборки журнала, пустые поля не применяются
+type AuthEventFilter struct {
+	Username string
+	Type     AuthEventType
+	Outcome  AuthOutcome
+	IP       string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Offset   int
+}
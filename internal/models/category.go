@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Category - узел дерева категорий. Path - названия от корня через " / ",
+// Children заполняется только в дереве GET /categories
+type Category struct {
+	ID        int         `json:"id" db:"id"`
+	ParentID  *int        `json:"parent_id,omitempty" db:"parent_id"`
+	Name      string      `json:"name" db:"name"`
+	Path      string      `json:"path" db:"-"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+	Children  []*Category `json:"children,omitempty" db:"-"`
+}
+
+// CategoryTree собирает дерево из списка, в котором родитель идет раньше потомков
+func CategoryTree(categories []*Category) []*Category {
+	byID := make(map[int]*Category, len(categories))
+	roots := []*Category{}
+	for _, c := range categories {
+		byID[c.ID] = c
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else if parent, ok := byID[*c.ParentID]; ok {
+			parent.Children = append(parent.Children, c)
+		}
+	}
+	return roots
+}
+
+// Tag - тег и число действующих товаров с ним
+type Tag struct {
+	Name      string `json:"name"`
+	ItemCount int    `json:"item_count"`
+}
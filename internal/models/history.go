@@ -11,7 +11,12 @@ type HistoryAction string
 const (
 	ActionCreate HistoryAction = "create"
 	ActionUpdate HistoryAction = "update"
-	ActionDelete HistoryAction = "delete"
+	ActionDelete HistoryAction = "delete" // перенос в корзину
+	// ActionRestore - возврат из корзины, ActionPurge - окончательное удаление
+	ActionRestore HistoryAction = "restore"
+	ActionPurge   HistoryAction = "purge"
+	// ActionStock - изменение остатка в месте хранения, снимки содержат строку item_stock
+	ActionStock HistoryAction = "stock"
 )
 
 type JSONB map[string]interface{}
@@ -28,11 +33,29 @@ func (j *JSONB) Scan(value interface{}) error {
 }
 
 type ItemHistory struct {
-	ID        int           `json:"id" db:"id"`
-	ItemID    int           `json:"item_id" db:"item_id"`
-	Action    HistoryAction `json:"action" db:"action"`
-	ChangedBy string        `json:"changed_by" db:"changed_by"`
-	OldValues JSONB         `json:"old_values" db:"old_values"`
-	NewValues JSONB         `json:"new_values" db:"new_values"`
-	ChangedAt time.Time     `json:"changed_at" db:"changed_at"`
+	ID         int           `json:"id" db:"id"`
+	ItemID     int           `json:"item_id" db:"item_id"`
+	Action     HistoryAction `json:"action" db:"action"`
+	ChangedBy  string        `json:"changed_by" db:"changed_by"`
+	OldValues  JSONB         `json:"old_values" db:"old_values"`
+	NewValues  JSONB         `json:"new_values" db:"new_values"`
+	LocationID *int          `json:"location_id,omitempty" db:"location_id"`
+	ChangedAt  time.Time     `json:"changed_at" db:"changed_at"`
+}
+
+// HistoryFilter - фильтры журнала изменений, пустые поля не применяются. To не включается
+type HistoryFilter struct {
+	ItemID    *int
+	Action    HistoryAction
+	ChangedBy string
+	From      *time.Time
+	To        *time.Time
+}
+
+func (a HistoryAction) IsValid() bool {
+	switch a {
+	case ActionCreate, ActionUpdate, ActionDelete, ActionRestore, ActionPurge, ActionStock:
+		return true
+	}
+	return false
 }
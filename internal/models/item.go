@@ -1,11 +1,100 @@
 package models
 
-import "time"
+import (
+	"regexp"
+	"time"
+)
 
+// skuPattern - артикул печатается на этикетках, поэтому без пробелов и спецсимволов
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidSKU проверяет символы артикула, длину проверяет вызывающий
+func ValidSKU(sku string) bool {
+	return skuPattern.MatchString(sku)
+}
+
+// Item.Quantity - общий остаток в базовой единице Unit, сумма Stock по всем местам
+// хранения. UnitPrecision - допустимое число знаков после запятой в базовой единице
+// (0 для штучных товаров), Units - дополнительные единицы с коэффициентами пересчета.
+// CategoryID nil - товар без категории, Tags хранятся в нижнем регистре и по алфавиту
 type Item struct {
-	ID        int       `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name" validate:"required"`
-	Quantity  int       `json:"quantity" db:"quantity"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID            int         `json:"id" db:"id"`
+	SKU           string      `json:"sku" db:"sku"`
+	Name          string      `json:"name" db:"name" validate:"required"`
+	Quantity      Quantity    `json:"quantity" db:"quantity"`
+	Unit          string      `json:"unit" db:"unit"`
+	UnitPrecision int         `json:"unit_precision" db:"unit_precision"`
+	Units         ItemUnits   `json:"units" db:"units"`
+	Barcodes      []string    `json:"barcodes" db:"barcodes"`
+	CategoryID    *int        `json:"category_id" db:"category_id"`
+	Tags          []string    `json:"tags" db:"tags"`
+	Stock         []ItemStock `json:"stock" db:"-"`
+	Version       int         `json:"version" db:"version"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at" db:"updated_at"`
+	// DeletedAt заполнен у товаров в корзине
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// ItemPatch - изменяемые поля товара для частичного обновления, nil - поле не меняется.
+// CategoryID, указывающий на 0, убирает категорию
+type ItemPatch struct {
+	SKU           *string
+	Name          *string
+	Quantity      *Quantity
+	Unit          *string
+	UnitPrecision *int
+	Units         *ItemUnits
+	Barcodes      *[]string
+	CategoryID    *int
+	Tags          *[]string
+}
+
+func (p ItemPatch) IsEmpty() bool {
+	return p.SKU == nil && p.Name == nil && p.Quantity == nil && p.Unit == nil && p.UnitPrecision == nil &&
+		p.Units == nil && p.Barcodes == nil && p.CategoryID == nil && p.Tags == nil
+}
+
+// ItemSortFields - поля, по которым можно сортировать список товаров
+var ItemSortFields = []string{"id", "sku", "name", "quantity", "created_at", "updated_at"}
+
+func IsItemSortField(field string) bool {
+	for _, f := range ItemSortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ItemQuery - фильтры, сортировка и страница списка товаров. Пустые поля не фильтруют
+type ItemQuery struct {
+	Name        string
+	MinQuantity *Quantity
+	MaxQuantity *Quantity
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	CategoryID  *int     // категория вместе со всеми подкатегориями
+	Tags        []string // товар должен иметь все теги
+	Sort        string   // одно из ItemSortFields, по умолчанию id
+	Desc        bool
+	Limit       int    // 0 - все товары одной страницей
+	Cursor      string // NextCursor предыдущей страницы
+	Deleted     bool   // товары из корзины вместо действующих
+}
+
+// ItemPage - страница товаров. Total - число товаров под фильтрами без учета страниц,
+// NextCursor пуст на последней странице
+type ItemPage struct {
+	Items      []*Item
+	Total      int
+	NextCursor string
+}
+
+// ItemSearchResult - найденный товар. Highlight - название с совпадениями в <mark>,
+// текст названия не экранируется
+type ItemSearchResult struct {
+	*Item
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
 }
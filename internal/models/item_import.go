@@ -0,0 +1,67 @@
+package models
+
+// ImportMatch - поле, по которому строка импорта сопоставляется с существующим товаром
+type ImportMatch string
+
+const (
+	ImportMatchSKU  ImportMatch = "sku"
+	ImportMatchName ImportMatch = "name"
+)
+
+func (m ImportMatch) IsValid() bool {
+	return m == ImportMatchSKU || m == ImportMatchName
+}
+
+// ImportRow - строка файла импорта. Пустые SKU и Name и nil Quantity и Barcodes
+// означают, что значение не задано и у существующего товара не меняется.
+// Errors - ошибки разбора и проверки, такая строка не импортируется
+type ImportRow struct {
+	Line     int
+	SKU      string
+	Name     string
+	Quantity *Quantity
+	Barcodes *[]string
+	Errors   []string
+}
+
+type ImportAction string
+
+const (
+	ImportCreate    ImportAction = "create"
+	ImportUpdate    ImportAction = "update"
+	ImportUnchanged ImportAction = "unchanged"
+	ImportError     ImportAction = "error"
+)
+
+type ImportOptions struct {
+	MatchBy ImportMatch
+	DryRun  bool
+}
+
+// FieldChange - значение поля до и после импорта, Old пуст у новых товаров
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+type ImportRowResult struct {
+	Line    int                    `json:"line"`
+	Action  ImportAction           `json:"action"`
+	ItemID  int                    `json:"item_id,omitempty"`
+	SKU     string                 `json:"sku,omitempty"`
+	Name    string                 `json:"name,omitempty"`
+	Changes map[string]FieldChange `json:"changes,omitempty"`
+	Errors  []string               `json:"errors,omitempty"`
+}
+
+// ImportReport - результат импорта по строкам. Committed ложно для пробного
+// запуска и для импорта с ошибками: тогда ни одна строка не сохранена
+type ImportReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Committed bool              `json:"committed"`
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Failed    int               `json:"failed"`
+	Rows      []ImportRowResult `json:"rows"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type AttemptScope string
+
+const (
+	AttemptScopeUsername AttemptScope = "username"
+	AttemptScopeIP       AttemptScope = "ip"
+)
+
+type LoginAttempt struct {
+	Scope         AttemptScope `json:"scope" db:"scope"`
+	Key           string       `json:"key" db:"key"`
+	Failures      int          `json:"failures" db:"failures"`
+	LastFailureAt time.Time    `json:"last_failure_at" db:"last_failure_at"`
+	BlockedUntil  *time.Time   `json:"blocked_until,omitempty" db:"blocked_until"`
+}
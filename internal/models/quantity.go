@@ -0,0 +1,143 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Quantity - количество с точностью до тысячных. Хранится целым числом тысячных,
+// поэтому сложение и сравнение точные. В JSON - число, в базе - NUMERIC(18,3)
+type Quantity int64
+
+// QuantityScale - число знаков после запятой у Quantity
+const QuantityScale = 3
+
+const quantityOne Quantity = 1000
+
+// maxQuantity - предел NUMERIC(18,3): 15 знаков до запятой
+const maxQuantity Quantity = 1e18 - 1
+
+// NewQuantity возвращает целое количество n
+func NewQuantity(n int) Quantity {
+	return Quantity(n) * quantityOne
+}
+
+// QuantityFromFloat округляет f до тысячных. Нужна для чисел из JSON снимков истории
+// и ячеек Excel, точные значения читаются через ParseQuantity
+func QuantityFromFloat(f float64) Quantity {
+	return Quantity(math.Round(f * float64(quantityOne)))
+}
+
+// ParseQuantity разбирает десятичное число вида 72, -3 или 1.125: не больше
+// трех значащих знаков после точки, без экспоненты
+func ParseQuantity(s string) (Quantity, error) {
+	invalid := fmt.Errorf("quantity %q must be a number with at most %d decimal places", s, QuantityScale)
+
+	digits := strings.TrimPrefix(s, "-")
+	negative := len(digits) < len(s)
+
+	intPart, fracPart, hasPoint := strings.Cut(digits, ".")
+	if intPart == "" || hasPoint && fracPart == "" {
+		return 0, invalid
+	}
+	if fracPart = strings.TrimRight(fracPart, "0"); len(fracPart) > QuantityScale || len(intPart) > 15 {
+		return 0, invalid
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return 0, invalid
+		}
+	}
+
+	n, _ := strconv.ParseInt(intPart+fracPart+strings.Repeat("0", QuantityScale-len(fracPart)), 10, 64)
+	if negative {
+		n = -n
+	}
+	return Quantity(n), nil
+}
+
+func (q Quantity) String() string {
+	sign := ""
+	n := int64(q)
+	if n < 0 {
+		sign, n = "-", -n
+	}
+
+	s := sign + strconv.FormatInt(n/int64(quantityOne), 10)
+	if frac := n % int64(quantityOne); frac != 0 {
+		s += "." + strings.TrimRight(fmt.Sprintf("%03d", frac), "0")
+	}
+	return s
+}
+
+// Float64 - приближенное значение для выгрузок
+func (q Quantity) Float64() float64 {
+	return float64(q) / float64(quantityOne)
+}
+
+// FitsPrecision проверяет, что у количества не больше decimals знаков после запятой
+func (q Quantity) FitsPrecision(decimals int) bool {
+	step := Quantity(1)
+	for i := decimals; i < QuantityScale; i++ {
+		step *= 10
+	}
+	return q%step == 0
+}
+
+// Mul умножает количество на коэффициент пересчета. ok ложно, если произведение
+// не представимо точно до тысячных или слишком велико
+func (q Quantity) Mul(factor Quantity) (Quantity, bool) {
+	product := new(big.Int).Mul(big.NewInt(int64(q)), big.NewInt(int64(factor)))
+	result, rem := product.QuoRem(product, big.NewInt(int64(quantityOne)), new(big.Int))
+	if rem.Sign() != 0 || !result.IsInt64() {
+		return 0, false
+	}
+
+	n := Quantity(result.Int64())
+	if n > maxQuantity || n < -maxQuantity {
+		return 0, false
+	}
+	return n, true
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON принимает только число: строка или экспонента - ошибка
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	n, err := ParseQuantity(string(data))
+	if err != nil {
+		return err
+	}
+	*q = n
+	return nil
+}
+
+func (q Quantity) Value() (driver.Value, error) {
+	return q.String(), nil
+}
+
+func (q *Quantity) Scan(value interface{}) error {
+	var err error
+	switch v := value.(type) {
+	case nil:
+		*q = 0
+	case []byte:
+		*q, err = ParseQuantity(string(v))
+	case string:
+		*q, err = ParseQuantity(v)
+	case int64:
+		*q = NewQuantity(int(v))
+	default:
+		err = fmt.Errorf("cannot scan %T into Quantity", value)
+	}
+	return err
+}
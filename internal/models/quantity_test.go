@@ -0,0 +1,216 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Quantity
+		ok   bool
+	}{
+		{"72", 72000, true},
+		{"-3", -3000, true},
+		{"0", 0, true},
+		{"-0", 0, true},
+		{"1.125", 1125, true},
+		{"0.001", 1, true},
+		{"-0.5", -500, true},
+		{"1.5000", 1500, true},
+		{"2.000000", 2000, true},
+		{"007", 7000, true},
+		{"999999999999999.999", maxQuantity, true},
+		{"-999999999999999.999", -maxQuantity, true},
+
+		{"", 0, false},
+		{"-", 0, false},
+		{".5", 0, false},
+		{"1.", 0, false},
+		{"1.2345", 0, false},
+		{"0.0001", 0, false},
+		{"1e3", 0, false},
+		{"1E3", 0, false},
+		{"+1", 0, false},
+		{"--1", 0, false},
+		{"1.2.3", 0, false},
+		{" 1", 0, false},
+		{"1,5", 0, false},
+		{"abc", 0, false},
+		{`"1"`, 0, false},
+		{"1000000000000000", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseQuantity(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseQuantity(%q): err = %v, want ok = %v", tt.in, err, tt.ok)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseQuantity(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuantityString(t *testing.T) {
+	tests := []struct {
+		q    Quantity
+		want string
+	}{
+		{0, "0"},
+		{NewQuantity(72), "72"},
+		{NewQuantity(-3), "-3"},
+		{1125, "1.125"},
+		{1500, "1.5"},
+		{1010, "1.01"},
+		{1, "0.001"},
+		{-1, "-0.001"},
+		{-500, "-0.5"},
+		{maxQuantity, "999999999999999.999"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.q.String(); got != tt.want {
+			t.Errorf("Quantity(%d).String() = %s, want %s", int64(tt.q), got, tt.want)
+		}
+		if back, err := ParseQuantity(tt.want); err != nil || back != tt.q {
+			t.Errorf("ParseQuantity(%s) = (%d, %v), want %d", tt.want, back, err, tt.q)
+		}
+	}
+}
+
+func TestQuantityFromFloat(t *testing.T) {
+	tests := []struct {
+		f    float64
+		want Quantity
+	}{
+		{0, 0},
+		{72, 72000},
+		{0.1 + 0.2, 300},
+		{-2.5, -2500},
+	}
+
+	for _, tt := range tests {
+		if got := QuantityFromFloat(tt.f); got != tt.want {
+			t.Errorf("QuantityFromFloat(%v) = %d, want %d", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestQuantityFitsPrecision(t *testing.T) {
+	tests := []struct {
+		q    Quantity
+		want [QuantityScale + 1]bool // для decimals = 0, 1, 2, 3
+	}{
+		{0, [...]bool{true, true, true, true}},
+		{NewQuantity(5), [...]bool{true, true, true, true}},
+		{NewQuantity(-5), [...]bool{true, true, true, true}},
+		{1500, [...]bool{false, true, true, true}},
+		{-1500, [...]bool{false, true, true, true}},
+		{1250, [...]bool{false, false, true, true}},
+		{1125, [...]bool{false, false, false, true}},
+		{1, [...]bool{false, false, false, true}},
+	}
+
+	for _, tt := range tests {
+		for decimals, want := range tt.want {
+			if got := tt.q.FitsPrecision(decimals); got != want {
+				t.Errorf("%s.FitsPrecision(%d) = %v, want %v", tt.q, decimals, got, want)
+			}
+		}
+	}
+}
+
+func TestQuantityMul(t *testing.T) {
+	tests := []struct {
+		name      string
+		q, factor Quantity
+		want      Quantity
+		ok        bool
+	}{
+		{"integer factor", NewQuantity(3), NewQuantity(12), NewQuantity(36), true},
+		{"fractional factor", NewQuantity(2), 500, NewQuantity(1), true},
+		{"fractional by fractional", 1500, 1500, 2250, true},
+		{"smallest exact", 1, NewQuantity(1), 1, true},
+		{"negative", NewQuantity(-4), 250, NewQuantity(-1), true},
+		{"zero", 0, maxQuantity, 0, true},
+		{"up to limit", maxQuantity, NewQuantity(1), maxQuantity, true},
+		{"down to limit", -maxQuantity, NewQuantity(1), -maxQuantity, true},
+
+		{"below thousandths", 1, 1, 0, false},
+		{"inexact", 1125, 500, 0, false},
+		{"beyond numeric limit", maxQuantity, NewQuantity(2), 0, false},
+		{"beyond negative limit", -maxQuantity, NewQuantity(2), 0, false},
+		{"beyond int64", maxQuantity, maxQuantity, 0, false},
+		{"int64 extremes", math.MaxInt64, math.MaxInt64, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.q.Mul(tt.factor)
+			if ok != tt.ok || got != tt.want {
+				t.Fatalf("%s.Mul(%s) = (%s, %v), want (%s, %v)", tt.q, tt.factor, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestQuantityJSON(t *testing.T) {
+	var v struct {
+		Quantity Quantity `json:"quantity"`
+	}
+
+	for _, in := range []string{`{"quantity":1.125}`, `{"quantity":-3}`, `{"quantity":0.5}`} {
+		if err := json.Unmarshal([]byte(in), &v); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", in, err)
+		}
+		out, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != in {
+			t.Errorf("round trip of %s = %s", in, out)
+		}
+	}
+
+	v.Quantity = 42
+	if err := json.Unmarshal([]byte(`{"quantity":null}`), &v); err != nil || v.Quantity != 42 {
+		t.Errorf("null must leave quantity unchanged: (%s, %v)", v.Quantity, err)
+	}
+
+	for _, in := range []string{`{"quantity":"1"}`, `{"quantity":1e3}`, `{"quantity":0.0001}`} {
+		if err := json.Unmarshal([]byte(in), &v); err == nil {
+			t.Errorf("Unmarshal(%s) must fail", in)
+		}
+	}
+}
+
+func TestQuantityScan(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  Quantity
+		ok    bool
+	}{
+		{nil, 0, true},
+		{[]byte("12.500"), 12500, true},
+		{"-0.250", -250, true},
+		{int64(7), NewQuantity(7), true},
+		{[]byte("1.2345"), 0, false},
+		{1.5, 0, false},
+	}
+
+	for _, tt := range tests {
+		q := Quantity(99)
+		err := q.Scan(tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("Scan(%v): err = %v, want ok = %v", tt.value, err, tt.ok)
+			continue
+		}
+		if tt.ok && q != tt.want {
+			t.Errorf("Scan(%v) = %s, want %s", tt.value, q, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+type MovementType string
+
+const (
+	MovementReceive  MovementType = "receive"
+	MovementIssue    MovementType = "issue"
+	MovementAdjust   MovementType = "adjust"
+	MovementTransfer MovementType = "transfer"
+)
+
+// movementReasons - допустимые коды причин для каждого типа движения
+var movementReasons = map[MovementType][]string{
+	MovementReceive:  {"purchase", "return", "production", "initial", "other"},
+	MovementIssue:    {"sale", "production", "write_off", "damage", "other"},
+	MovementAdjust:   {"count", "correction", "opening_balance", "other"},
+	MovementTransfer: {"relocation"},
+}
+
+// ValidReason проверяет, что код причины допустим для типа движения
+func (t MovementType) ValidReason(reason string) bool {
+	for _, r := range movementReasons[t] {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// StockMovement - строка журнала движений. Delta со знаком в базовой единице товара Unit:
+// приход положительный, расход отрицательный. EnteredQuantity и EnteredUnit - количество
+// в той единице, в которой его передал клиент, со знаком как у Delta
+type StockMovement struct {
+	ID              int64        `json:"id" db:"id"`
+	ItemID          int          `json:"item_id" db:"item_id"`
+	LocationID      int          `json:"location_id" db:"location_id"`
+	Type            MovementType `json:"type" db:"movement_type"`
+	Delta           Quantity     `json:"delta" db:"delta"`
+	Unit            string       `json:"unit" db:"unit"`
+	EnteredQuantity *Quantity    `json:"entered_quantity,omitempty" db:"entered_quantity"`
+	EnteredUnit     *string      `json:"entered_unit,omitempty" db:"entered_unit"`
+	Reason          string       `json:"reason" db:"reason"`
+	Note            string       `json:"note,omitempty" db:"note"`
+	TransferID      *string      `json:"transfer_id,omitempty" db:"transfer_id"`
+	CreatedBy       string       `json:"created_by" db:"created_by"`
+	CreatedAt       time.Time    `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	ID              int        `json:"id" db:"id"`
+	UserID          int        `json:"user_id" db:"user_id"`
+	TokenHash       string     `json:"-" db:"token_hash"`
+	AccessJTI       string     `json:"-" db:"access_jti"`
+	AccessExpiresAt time.Time  `json:"-" db:"access_expires_at"`
+	ExpiresAt       time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+type PasswordResetToken struct {
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	CreatedBy string     `json:"created_by" db:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type UserTOTP struct {
+	UserID       int        `json:"user_id" db:"user_id"`
+	Secret       string     `json:"-" db:"secret"`
+	Enabled      bool       `json:"enabled" db:"enabled"`
+	LastUsedStep *int64     `json:"-" db:"last_used_step"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// MFAChallenge - незавершенный вход: пароль проверен, второй фактор еще нет
+type MFAChallenge struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	Attempts  int       `json:"attempts" db:"attempts"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultUnit - базовая единица товара, если она не задана
+const DefaultUnit = "pcs"
+
+// MaxUnitPrecision - сколько знаков после запятой может допускать базовая единица
+const MaxUnitPrecision = QuantityScale
+
+// ItemUnit - дополнительная единица товара: Factor базовых единиц в одной единице Unit,
+// например коробка из 24 штук - {"unit": "case", "factor": 24}
+type ItemUnit struct {
+	Unit   string   `json:"unit" validate:"required,unit"`
+	Factor Quantity `json:"factor" validate:"gt=0"`
+}
+
+// ItemUnits хранится в items.units как JSONB, поэтому попадает в снимки истории
+type ItemUnits []ItemUnit
+
+func (u ItemUnits) Value() (driver.Value, error) {
+	if u == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(u)
+}
+
+func (u *ItemUnits) Scan(value interface{}) error {
+	if value == nil {
+		*u = ItemUnits{}
+		return nil
+	}
+	return json.Unmarshal(value.([]byte), u)
+}
+
+// ValidUnit - код единицы от 1 до 16 символов: буквы, цифры, точка, дефис и подчеркивание
+func ValidUnit(unit string) bool {
+	if unit == "" || utf8.RuneCountInString(unit) > 16 {
+		return false
+	}
+	for _, r := range unit {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeUnit приводит код единицы к нижнему регистру, "Case" и "case" - одна единица
+func NormalizeUnit(unit string) string {
+	return strings.ToLower(strings.TrimSpace(unit))
+}
+
+// Factor возвращает число базовых единиц в единице unit. Базовая единица - 1
+func (item *Item) Factor(unit string) (Quantity, bool) {
+	unit = NormalizeUnit(unit)
+	if unit == item.Unit {
+		return quantityOne, true
+	}
+	for _, u := range item.Units {
+		if u.Unit == unit {
+			return u.Factor, true
+		}
+	}
+	return 0, false
+}
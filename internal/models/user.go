@@ -10,10 +10,30 @@ const (
 	RoleViewer  UserRole = "viewer"
 )
 
+// AuthProvider - источник учетной записи. Пользователи OIDC не имеют
+// локального пароля и входят только через провайдера
+type AuthProvider string
+
+const (
+	AuthProviderLocal AuthProvider = "local"
+	AuthProviderOIDC  AuthProvider = "oidc"
+)
+
 type User struct {
-	ID           int       `json:"id" db:"id"`
-	Username     string    `json:"username" db:"username"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	Role         UserRole  `json:"role" db:"role"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	ID           int          `json:"id" db:"id"`
+	Username     string       `json:"username" db:"username"`
+	PasswordHash string       `json:"-" db:"password_hash"`
+	Role         UserRole     `json:"role" db:"role"`
+	Disabled     bool         `json:"disabled" db:"disabled"`
+	AuthProvider AuthProvider `json:"auth_provider" db:"auth_provider"`
+	ExternalID   *string      `json:"-" db:"external_id"` // issuer|subject у OIDC провайдера
+	CreatedAt    time.Time    `json:"created_at" db:"created_at"`
+}
+
+func (r UserRole) IsValid() bool {
+	switch r {
+	case RoleAdmin, RoleManager, RoleViewer:
+		return true
+	}
+	return false
 }
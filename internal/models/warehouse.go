@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+type Warehouse struct {
+	ID        int       `json:"id" db:"id"`
+	Code      string    `json:"code" db:"code"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// LocationKind - уровень места хранения в иерархии zone -> aisle -> rack -> bin
+type LocationKind string
+
+const (
+	LocationZone  LocationKind = "zone"
+	LocationAisle LocationKind = "aisle"
+	LocationRack  LocationKind = "rack"
+	LocationBin   LocationKind = "bin"
+)
+
+// Parent возвращает допустимый уровень родителя. У зоны родителя нет
+func (k LocationKind) Parent() (LocationKind, bool) {
+	switch k {
+	case LocationAisle:
+		return LocationZone, true
+	case LocationRack:
+		return LocationAisle, true
+	case LocationBin:
+		return LocationRack, true
+	}
+	return "", false
+}
+
+func (k LocationKind) IsValid() bool {
+	switch k {
+	case LocationZone, LocationAisle, LocationRack, LocationBin:
+		return true
+	}
+	return false
+}
+
+type Location struct {
+	ID          int          `json:"id" db:"id"`
+	WarehouseID int          `json:"warehouse_id" db:"warehouse_id"`
+	ParentID    *int         `json:"parent_id,omitempty" db:"parent_id"`
+	Kind        LocationKind `json:"kind" db:"kind"`
+	Code        string       `json:"code" db:"code"`
+	Name        string       `json:"name" db:"name"`
+	IsDefault   bool         `json:"is_default" db:"is_default"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+}
+
+// ItemStock - остаток товара в одном месте хранения в базовой единице товара Unit
+type ItemStock struct {
+	ItemID        int      `json:"item_id,omitempty" db:"item_id"`
+	LocationID    int      `json:"location_id" db:"location_id"`
+	LocationCode  string   `json:"location_code" db:"location_code"`
+	WarehouseID   int      `json:"warehouse_id" db:"warehouse_id"`
+	WarehouseCode string   `json:"warehouse_code" db:"warehouse_code"`
+	Quantity      Quantity `json:"quantity" db:"quantity"`
+	Unit          string   `json:"unit" db:"unit"`
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+type APIKeyStorageI interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	ListAPIKeys(ctx context.Context) ([]*models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int) error
+	TouchAPIKey(ctx context.Context, id int) error
+}
+
+type APIKeyStorage struct {
+	db *sql.DB
+}
+
+func NewAPIKeyStorage(db *sql.DB) *APIKeyStorage {
+	return &APIKeyStorage{db: db}
+}
+
+func (s *APIKeyStorage) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	query := `INSERT INTO api_keys (name, key_prefix, key_hash, role, created_by, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	err := s.db.QueryRowContext(ctx, query,
+		key.Name, key.Prefix, key.KeyHash, key.Role, key.CreatedBy, key.ExpiresAt,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return storage.ErrAPIKeyExists
+		}
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+func (s *APIKeyStorage) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	query := `SELECT id, name, key_prefix, role, created_by, expires_at, created_at, last_used_at, revoked_at
+	          FROM api_keys ORDER BY id`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		err = rows.Scan(&k.ID, &k.Name, &k.Prefix, &k.Role, &k.CreatedBy, &k.ExpiresAt, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, &k)
+	}
+
+	return keys, nil
+}
+
+func (s *APIKeyStorage) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `SELECT id, name, key_prefix, role, created_by, expires_at, created_at, last_used_at, revoked_at
+	          FROM api_keys WHERE key_hash = $1`
+
+	var k models.APIKey
+	err := s.db.QueryRowContext(ctx, query, keyHash).
+		Scan(&k.ID, &k.Name, &k.Prefix, &k.Role, &k.CreatedBy, &k.ExpiresAt, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return &k, nil
+}
+
+func (s *APIKeyStorage) RevokeAPIKey(ctx context.Context, id int) error {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1`
+	result, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// TouchAPIKey обновляет время последнего использования не чаще раза в минуту,
+// чтобы частые запросы интеграций не превращались в поток UPDATE
+func (s *APIKeyStorage) TouchAPIKey(ctx context.Context, id int) error {
+	query := `UPDATE api_keys SET last_used_at = NOW()
+	          WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+	if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update api key usage: %w", err)
+	}
+	return nil
+}
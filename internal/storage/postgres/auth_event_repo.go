@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type AuthEventStorageI interface {
+	RecordAuthEvent(ctx context.Context, event *models.AuthEvent) error
+	ListAuthEvents(ctx context.Context, filter models.AuthEventFilter) ([]*models.AuthEvent, error)
+}
+
+type AuthEventStorage struct {
+	db *sql.DB
+}
+
+func NewAuthEventStorage(db *sql.DB) *AuthEventStorage {
+	return &AuthEventStorage{db: db}
+}
+
+func (s *AuthEventStorage) RecordAuthEvent(ctx context.Context, event *models.AuthEvent) error {
+	query := `INSERT INTO auth_events (event_type, outcome, username, user_id, ip, user_agent, request_id, reason)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`
+	err := s.db.QueryRowContext(ctx, query,
+		event.Type, event.Outcome, event.Username, event.UserID, event.IP, event.UserAgent, event.RequestID, event.Reason,
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record auth event: %w", err)
+	}
+	return nil
+}
+
+// ListAuthEvents возвращает события по фильтру, новые первыми
+func (s *AuthEventStorage) ListAuthEvents(ctx context.Context, filter models.AuthEventFilter) ([]*models.AuthEvent, error) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Username != "" {
+		add("username = $%d", filter.Username)
+	}
+	if filter.Type != "" {
+		add("event_type = $%d", filter.Type)
+	}
+	if filter.Outcome != "" {
+		add("outcome = $%d", filter.Outcome)
+	}
+	if filter.IP != "" {
+		add("ip = $%d", filter.IP)
+	}
+	if filter.From != nil {
+		add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < $%d", *filter.To)
+	}
+
+	query := `SELECT id, event_type, outcome, username, user_id, ip, user_agent, request_id, reason, created_at FROM auth_events`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.AuthEvent
+	for rows.Next() {
+		var e models.AuthEvent
+		err = rows.Scan(&e.ID, &e.Type, &e.Outcome, &e.Username, &e.UserID, &e.IP, &e.UserAgent, &e.RequestID, &e.Reason, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan auth event: %w", err)
+		}
+		events = append(events, &e)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type CategoryStorageI interface {
+	ListCategories(ctx context.Context) ([]*models.Category, error)
+	GetCategoryByID(ctx context.Context, id int) (*models.Category, error)
+	CreateCategory(ctx context.Context, category *models.Category) error
+	RenameCategory(ctx context.Context, id int, name string) (*models.Category, error)
+	MoveCategory(ctx context.Context, id int, parentID *int) (*models.Category, error)
+	DeleteCategory(ctx context.Context, id int) error
+}
+
+type CategoryStorage struct {
+	db *sql.DB
+}
+
+func NewCategoryStorage(db *sql.DB) *CategoryStorage {
+	return &CategoryStorage{db: db}
+}
+
+// categoryTreeQuery обходит дерево от корней, path - названия от корня через " / "
+const categoryTreeQuery = `WITH RECURSIVE tree AS (
+	    SELECT id, parent_id, name, created_at, updated_at, name::TEXT AS path, ARRAY[lower(name)] AS sort_key
+	    FROM categories WHERE parent_id IS NULL
+	    UNION ALL
+	    SELECT c.id, c.parent_id, c.name, c.created_at, c.updated_at, tree.path || ' / ' || c.name, tree.sort_key || lower(c.name)
+	    FROM categories c JOIN tree ON c.parent_id = tree.id
+	)
+	SELECT id, parent_id, name, path, created_at, updated_at FROM tree`
+
+// categoryPathQuery собирает путь одной категории по цепочке родителей
+const categoryPathQuery = `WITH RECURSIVE up AS (
+	    SELECT id, parent_id, name, 0 AS depth FROM categories WHERE id = $1
+	    UNION ALL
+	    SELECT c.id, c.parent_id, c.name, up.depth + 1 FROM categories c JOIN up ON c.id = up.parent_id
+	)
+	SELECT string_agg(name, ' / ' ORDER BY depth DESC) FROM up`
+
+// ListCategories возвращает все категории в порядке обхода дерева:
+// родитель раньше потомков, соседи по названию
+func (s *CategoryStorage) ListCategories(ctx context.Context) ([]*models.Category, error) {
+	rows, err := s.db.QueryContext(ctx, categoryTreeQuery+` ORDER BY sort_key`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []*models.Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+func (s *CategoryStorage) GetCategoryByID(ctx context.Context, id int) (*models.Category, error) {
+	return getCategory(ctx, s.db, id)
+}
+
+// CreateCategory создает категорию в корне или внутри ParentID
+func (s *CategoryStorage) CreateCategory(ctx context.Context, category *models.Category) error {
+	query := `INSERT INTO categories (parent_id, name) VALUES ($1, $2) RETURNING id`
+	if err := s.db.QueryRowContext(ctx, query, category.ParentID, category.Name).Scan(&category.ID); err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return storage.ErrCategoryExists
+		case isForeignKeyViolation(err):
+			return storage.ErrInvalidCategoryParent
+		}
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+
+	created, err := getCategory(ctx, s.db, category.ID)
+	if err != nil {
+		return err
+	}
+	*category = *created
+	return nil
+}
+
+// RenameCategory меняет название. Товары ссылаются на категорию по id,
+// поэтому их строки и история не меняются
+func (s *CategoryStorage) RenameCategory(ctx context.Context, id int, name string) (*models.Category, error) {
+	result, err := s.db.ExecContext(ctx, `UPDATE categories SET name = $1, updated_at = NOW() WHERE id = $2`, name, id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, storage.ErrCategoryExists
+		}
+		return nil, fmt.Errorf("failed to rename category: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, storage.ErrCategoryNotFound
+	}
+
+	return getCategory(ctx, s.db, id)
+}
+
+// MoveCategory переносит категорию со всеми подкатегориями в parentID, nil - в корень.
+// Переносы выполняются по очереди, чтобы два встречных переноса не замкнули цикл
+func (s *CategoryStorage) MoveCategory(ctx context.Context, id int, parentID *int) (*models.Category, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return nil, fmt.Errorf("failed to lock categories: %w", err)
+	}
+
+	var exists bool
+	if err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+	if !exists {
+		return nil, storage.ErrCategoryNotFound
+	}
+
+	if parentID != nil {
+		var inSubtree bool
+		query := `WITH RECURSIVE subtree AS (
+		              SELECT id FROM categories WHERE id = $1
+		              UNION ALL
+		              SELECT c.id FROM categories c JOIN subtree ON c.parent_id = subtree.id
+		          )
+		          SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $2)`
+		if err = tx.QueryRowContext(ctx, query, id, *parentID).Scan(&inSubtree); err != nil {
+			return nil, fmt.Errorf("failed to check category subtree: %w", err)
+		}
+		if inSubtree {
+			return nil, storage.ErrCategoryCycle
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE categories SET parent_id = $1, updated_at = NOW() WHERE id = $2`, parentID, id)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return nil, storage.ErrCategoryExists
+		case isForeignKeyViolation(err):
+			return nil, storage.ErrInvalidCategoryParent
+		}
+		return nil, fmt.Errorf("failed to move category: %w", err)
+	}
+
+	category, err := getCategory(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return category, tx.Commit()
+}
+
+// DeleteCategory удаляет только пустую категорию: без подкатегорий и товаров,
+// в том числе товаров в корзине
+func (s *CategoryStorage) DeleteCategory(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return storage.ErrCategoryInUse
+		}
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return storage.ErrCategoryNotFound
+	}
+	return nil
+}
+
+func getCategory(ctx context.Context, q queryRower, id int) (*models.Category, error) {
+	var c models.Category
+	query := `SELECT id, parent_id, name, (` + categoryPathQuery + `), created_at, updated_at FROM categories WHERE id = $1`
+	err := q.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.ParentID, &c.Name, &c.Path, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrCategoryNotFound
+		}
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+	return &c, nil
+}
+
+func scanCategory(row rowScanner) (*models.Category, error) {
+	var c models.Category
+	if err := row.Scan(&c.ID, &c.ParentID, &c.Name, &c.Path, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
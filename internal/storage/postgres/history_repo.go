@@ -5,11 +5,13 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 type HistoryStorageI interface {
 	GetHistoryByItemID(ctx context.Context, itemID int) ([]*models.ItemHistory, error)
-	GetAllHistory(ctx context.Context) ([]*models.ItemHistory, error)
+	GetAllHistory(ctx context.Context, filter models.HistoryFilter) ([]*models.ItemHistory, error)
+	StreamHistory(ctx context.Context, filter models.HistoryFilter, fn func(*models.ItemHistory) error) error
 }
 
 type HistoryStorage struct {
@@ -21,7 +23,7 @@ func NewHistoryStorage(db *sql.DB) *HistoryStorage {
 }
 
 func (s *HistoryStorage) GetHistoryByItemID(ctx context.Context, itemID int) ([]*models.ItemHistory, error) {
-	query := `SELECT id, item_id, action, changed_by, old_values, new_values, changed_at 
+	query := `SELECT id, item_id, action, changed_by, old_values, new_values, changed_at, location_id
 	          FROM item_history WHERE item_id = $1 ORDER BY changed_at DESC`
 	rows, err := s.db.QueryContext(ctx, query, itemID)
 	if err != nil {
@@ -32,7 +34,7 @@ func (s *HistoryStorage) GetHistoryByItemID(ctx context.Context, itemID int) ([]
 	var history []*models.ItemHistory
 	for rows.Next() {
 		var h models.ItemHistory
-		err = rows.Scan(&h.ID, &h.ItemID, &h.Action, &h.ChangedBy, &h.OldValues, &h.NewValues, &h.ChangedAt)
+		err = rows.Scan(&h.ID, &h.ItemID, &h.Action, &h.ChangedBy, &h.OldValues, &h.NewValues, &h.ChangedAt, &h.LocationID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan history record: %w", err)
 		}
@@ -42,24 +44,73 @@ func (s *HistoryStorage) GetHistoryByItemID(ctx context.Context, itemID int) ([]
 	return history, nil
 }
 
-func (s *HistoryStorage) GetAllHistory(ctx context.Context) ([]*models.ItemHistory, error) {
-	query := `SELECT id, item_id, action, changed_by, old_values, new_values, changed_at 
-	          FROM item_history ORDER BY changed_at DESC`
-	rows, err := s.db.QueryContext(ctx, query)
+// GetAllHistory возвращает записи журнала по фильтру, новые первыми
+func (s *HistoryStorage) GetAllHistory(ctx context.Context, filter models.HistoryFilter) ([]*models.ItemHistory, error) {
+	var history []*models.ItemHistory
+	err := s.queryHistory(ctx, filter, "changed_at DESC, id DESC", func(h *models.ItemHistory) error {
+		history = append(history, h)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all history: %w", err)
+		return nil, err
+	}
+	return history, nil
+}
+
+// StreamHistory передает fn записи журнала по фильтру в порядке их появления,
+// по мере чтения строк из базы. Ошибка fn прерывает чтение и возвращается как есть
+func (s *HistoryStorage) StreamHistory(ctx context.Context, filter models.HistoryFilter, fn func(*models.ItemHistory) error) error {
+	return s.queryHistory(ctx, filter, "id", fn)
+}
+
+func (s *HistoryStorage) queryHistory(ctx context.Context, filter models.HistoryFilter, orderBy string, fn func(*models.ItemHistory) error) error {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.ItemID != nil {
+		add("item_id = $%d", *filter.ItemID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.ChangedBy != "" {
+		add("changed_by = $%d", filter.ChangedBy)
+	}
+	if filter.From != nil {
+		add("changed_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("changed_at < $%d", *filter.To)
+	}
+
+	query := `SELECT id, item_id, action, changed_by, old_values, new_values, changed_at, location_id FROM item_history`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY " + orderBy
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get history: %w", err)
 	}
 	defer rows.Close()
 
-	var history []*models.ItemHistory
 	for rows.Next() {
 		var h models.ItemHistory
-		err = rows.Scan(&h.ID, &h.ItemID, &h.Action, &h.ChangedBy, &h.OldValues, &h.NewValues, &h.ChangedAt)
+		err = rows.Scan(&h.ID, &h.ItemID, &h.Action, &h.ChangedBy, &h.OldValues, &h.NewValues, &h.ChangedAt, &h.LocationID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan history record: %w", err)
+			return fmt.Errorf("failed to scan history record: %w", err)
+		}
+		if err = fn(&h); err != nil {
+			return err
 		}
-		history = append(history, &h)
 	}
-
-	return history, nil
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to get history: %w", err)
+	}
+	return nil
 }
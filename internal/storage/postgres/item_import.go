@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ImportItems создает и обновляет товары из строк импорта в одной транзакции от имени
+// changedBy. Каждая строка выполняется в точке сохранения: ошибка строки попадает
+// в отчет и не прерывает проверку остальных. Транзакция фиксируется, только если
+// это не пробный запуск и ни одна строка не завершилась ошибкой
+func (s *ItemStorage) ImportItems(ctx context.Context, rows []models.ImportRow, opts models.ImportOptions, changedBy string) (*models.ImportReport, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = setChangedBy(ctx, tx, changedBy); err != nil {
+		return nil, err
+	}
+
+	report := &models.ImportReport{DryRun: opts.DryRun, Rows: make([]models.ImportRowResult, 0, len(rows))}
+	for _, row := range rows {
+		result := models.ImportRowResult{Line: row.Line, SKU: row.SKU, Name: row.Name, Errors: row.Errors}
+
+		if len(result.Errors) == 0 {
+			if _, err = tx.ExecContext(ctx, `SAVEPOINT import_row`); err != nil {
+				return nil, fmt.Errorf("failed to create savepoint: %w", err)
+			}
+
+			err = importRow(ctx, tx, row, opts.MatchBy, &result, changedBy)
+			if err != nil && !isImportRowError(err) {
+				return nil, err
+			}
+
+			if err != nil {
+				result.Errors = []string{err.Error()}
+				if _, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_row`); err != nil {
+					return nil, fmt.Errorf("failed to roll back row: %w", err)
+				}
+			} else if _, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT import_row`); err != nil {
+				return nil, fmt.Errorf("failed to release savepoint: %w", err)
+			}
+		}
+
+		if len(result.Errors) > 0 {
+			result.Action = models.ImportError
+			result.Changes = nil
+		}
+		if opts.DryRun && result.Action == models.ImportCreate {
+			// id из последовательности не сохранится после отката
+			result.ItemID = 0
+		}
+		switch result.Action {
+		case models.ImportCreate:
+			report.Created++
+		case models.ImportUpdate:
+			report.Updated++
+		case models.ImportUnchanged:
+			report.Unchanged++
+		default:
+			report.Failed++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	if opts.DryRun || report.Failed > 0 {
+		return report, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+	report.Committed = true
+	return report, nil
+}
+
+// importRow находит товар по ключу и создает его или меняет только отличающиеся поля
+func importRow(ctx context.Context, tx *sql.Tx, row models.ImportRow, matchBy models.ImportMatch, result *models.ImportRowResult, changedBy string) error {
+	current, err := findImportItem(ctx, tx, row, matchBy)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		return importCreate(ctx, tx, row, result, changedBy)
+	}
+
+	result.ItemID = current.ID
+	result.SKU = current.SKU
+	result.Name = current.Name
+	result.Changes = make(map[string]models.FieldChange)
+
+	var patch models.ItemPatch
+	if row.SKU != "" && row.SKU != current.SKU {
+		patch.SKU = &row.SKU
+		result.Changes["sku"] = models.FieldChange{Old: current.SKU, New: row.SKU}
+	}
+	if row.Name != "" && row.Name != current.Name {
+		patch.Name = &row.Name
+		result.Changes["name"] = models.FieldChange{Old: current.Name, New: row.Name}
+	}
+	if row.Quantity != nil && *row.Quantity != current.Quantity {
+		patch.Quantity = row.Quantity
+		result.Changes["quantity"] = models.FieldChange{Old: current.Quantity, New: *row.Quantity}
+	}
+	if row.Barcodes != nil && !reflect.DeepEqual(*row.Barcodes, current.Barcodes) {
+		patch.Barcodes = row.Barcodes
+		result.Changes["barcodes"] = models.FieldChange{Old: current.Barcodes, New: *row.Barcodes}
+	}
+
+	if patch.IsEmpty() {
+		result.Action = models.ImportUnchanged
+		result.Changes = nil
+		return nil
+	}
+
+	result.Action = models.ImportUpdate
+	return patchItem(ctx, tx, current, patch, changedBy)
+}
+
+func importCreate(ctx context.Context, tx *sql.Tx, row models.ImportRow, result *models.ImportRowResult, changedBy string) error {
+	if row.SKU == "" || row.Name == "" {
+		return errImportIncomplete
+	}
+
+	item := &models.Item{SKU: row.SKU, Name: row.Name, Barcodes: []string{}}
+	if row.Quantity != nil {
+		item.Quantity = *row.Quantity
+	}
+	if row.Barcodes != nil {
+		item.Barcodes = *row.Barcodes
+	}
+
+	if err := createItem(ctx, tx, item, changedBy); err != nil {
+		return err
+	}
+
+	result.Action = models.ImportCreate
+	result.ItemID = item.ID
+	result.Changes = map[string]models.FieldChange{
+		"sku":      {New: item.SKU},
+		"name":     {New: item.Name},
+		"quantity": {New: item.Quantity},
+		"barcodes": {New: item.Barcodes},
+	}
+	return nil
+}
+
+// findImportItem блокирует товар с ключом строки. Артикул уникален и среди товаров
+// в корзине, поэтому совпадение с ним - ошибка строки, а не новый товар
+func findImportItem(ctx context.Context, tx *sql.Tx, row models.ImportRow, matchBy models.ImportMatch) (*models.Item, error) {
+	query := `SELECT ` + itemColumns + ` FROM items WHERE sku = $1 FOR UPDATE`
+	key := row.SKU
+	if matchBy == models.ImportMatchName {
+		query = `SELECT ` + itemColumns + ` FROM items WHERE name = $1 AND deleted_at IS NULL ORDER BY id LIMIT 2 FOR UPDATE`
+		key = row.Name
+	}
+
+	rows, err := tx.QueryContext(ctx, query, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find item: %w", err)
+	}
+	defer rows.Close()
+
+	var found []*models.Item
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		found = append(found, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find item: %w", err)
+	}
+
+	switch {
+	case len(found) == 0:
+		return nil, nil
+	case len(found) > 1:
+		return nil, storage.ErrAmbiguousName
+	case found[0].DeletedAt != nil:
+		return nil, storage.ErrItemInTrash
+	}
+	return found[0], nil
+}
+
+var errImportIncomplete = errors.New("sku and name are required to create an item")
+
+// isImportRowError отделяет ошибки данных строки от сбоев базы
+func isImportRowError(err error) bool {
+	for _, target := range []error{
+		errImportIncomplete,
+		storage.ErrSKUExists,
+		storage.ErrBarcodeExists,
+		storage.ErrItemInTrash,
+		storage.ErrAmbiguousName,
+		storage.ErrInsufficientStock,
+		storage.ErrNoDefaultLocation,
+		storage.ErrQuantityPrecision,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
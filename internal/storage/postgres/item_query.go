@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// sortCasts - тип значения поля сортировки, к которому приводится значение из курсора
+var sortCasts = map[string]string{
+	"id":         "integer",
+	"sku":        "text",
+	"name":       "text",
+	"quantity":   "numeric",
+	"created_at": "timestamp",
+	"updated_at": "timestamp",
+}
+
+const cursorTimeLayout = "2006-01-02T15:04:05.999999"
+
+// itemCursor - позиция последнего товара страницы. Сортировка и направление
+// сохраняются, чтобы курсор нельзя было применить к другому порядку
+type itemCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v,omitempty"`
+	ID    int    `json:"id"`
+}
+
+type itemQueryBuilder struct {
+	query      models.ItemQuery
+	conditions []string
+	args       []interface{}
+}
+
+func newItemQueryBuilder(q models.ItemQuery) *itemQueryBuilder {
+	b := &itemQueryBuilder{query: q}
+
+	if q.Deleted {
+		b.conditions = append(b.conditions, "deleted_at IS NOT NULL")
+	} else {
+		b.conditions = append(b.conditions, "deleted_at IS NULL")
+	}
+	if q.Name != "" {
+		b.add("name ILIKE $%d", "%"+escapeLike(q.Name)+"%")
+	}
+	if q.MinQuantity != nil {
+		b.add("quantity >= $%d", *q.MinQuantity)
+	}
+	if q.MaxQuantity != nil {
+		b.add("quantity <= $%d", *q.MaxQuantity)
+	}
+	if q.UpdatedFrom != nil {
+		b.add("updated_at >= $%d", *q.UpdatedFrom)
+	}
+	if q.UpdatedTo != nil {
+		b.add("updated_at <= $%d", *q.UpdatedTo)
+	}
+	if q.CategoryID != nil {
+		b.add(`category_id IN (WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE id = $%d
+			UNION ALL
+			SELECT c.id FROM categories c JOIN subtree ON c.parent_id = subtree.id
+		) SELECT id FROM subtree)`, *q.CategoryID)
+	}
+	if len(q.Tags) > 0 {
+		b.add("tags @> $%d", pq.Array(q.Tags))
+	}
+
+	return b
+}
+
+// add добавляет условие с одним параметром, %d заменяется номером параметра
+func (b *itemQueryBuilder) add(condition string, arg interface{}) {
+	b.args = append(b.args, arg)
+	b.conditions = append(b.conditions, fmt.Sprintf(condition, len(b.args)))
+}
+
+// after ограничивает выборку товарами после курсора
+func (b *itemQueryBuilder) after(encoded string) error {
+	c, err := decodeItemCursor(encoded)
+	if err != nil || c.Sort != b.query.Sort || c.Desc != b.query.Desc {
+		return storage.ErrInvalidCursor
+	}
+
+	op := ">"
+	if b.query.Desc {
+		op = "<"
+	}
+
+	if b.query.Sort == "id" {
+		b.add("id "+op+" $%d", c.ID)
+		return nil
+	}
+
+	b.args = append(b.args, c.Value, c.ID)
+	b.conditions = append(b.conditions, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)",
+		b.query.Sort, op, len(b.args)-1, sortCasts[b.query.Sort], len(b.args)))
+	return nil
+}
+
+func (b *itemQueryBuilder) where() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+func (b *itemQueryBuilder) orderBy() string {
+	direction := "ASC"
+	if b.query.Desc {
+		direction = "DESC"
+	}
+	if b.query.Sort == "id" {
+		return " ORDER BY id " + direction
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, id %s", b.query.Sort, direction, direction)
+}
+
+func encodeItemCursor(q models.ItemQuery, last *models.Item) string {
+	c := itemCursor{Sort: q.Sort, Desc: q.Desc, ID: last.ID}
+	switch q.Sort {
+	case "sku":
+		c.Value = last.SKU
+	case "name":
+		c.Value = last.Name
+	case "quantity":
+		c.Value = last.Quantity.String()
+	case "created_at":
+		c.Value = last.CreatedAt.Format(cursorTimeLayout)
+	case "updated_at":
+		c.Value = last.UpdatedAt.Format(cursorTimeLayout)
+	}
+
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeItemCursor(encoded string) (itemCursor, error) {
+	var c itemCursor
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return c, err
+	}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	if _, ok := sortCasts[c.Sort]; !ok {
+		return c, storage.ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// escapeLike экранирует спецсимволы LIKE, чтобы фильтр искал подстроку буквально
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -2,17 +2,33 @@ package postgres
 
 import (
 	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type ItemStorageI interface {
 	CreateItem(ctx context.Context, item *models.Item, changedBy string) error
-	GetAllItems(ctx context.Context) ([]*models.Item, error)
+	GetAllItems(ctx context.Context, query models.ItemQuery) (*models.ItemPage, error)
+	StreamItems(ctx context.Context, query models.ItemQuery, fn func(*models.Item) error) error
 	GetItemByID(ctx context.Context, id int) (*models.Item, error)
+	GetItemByCode(ctx context.Context, code string) (*models.Item, error)
+	SearchItems(ctx context.Context, text string, limit int) ([]*models.ItemSearchResult, error)
 	UpdateItem(ctx context.Context, item *models.Item, changedBy string) error
-	DeleteItem(ctx context.Context, id int, changedBy string) error
+	PatchItem(ctx context.Context, id int, version int, patch models.ItemPatch, changedBy string) (*models.Item, error)
+	DeleteItem(ctx context.Context, id int, version int, changedBy string) error
+	RestoreItem(ctx context.Context, id int, changedBy string) (*models.Item, error)
+	PurgeItem(ctx context.Context, id int, changedBy string) error
+	ImportItems(ctx context.Context, rows []models.ImportRow, opts models.ImportOptions, changedBy string) (*models.ImportReport, error)
+	SetStock(ctx context.Context, itemID, locationID int, quantity models.Quantity, unit, changedBy string) error
+	ListTags(ctx context.Context) ([]models.Tag, error)
 }
 
 type ItemStorage struct {
@@ -23,6 +39,11 @@ func NewItemStorage(db *sql.DB) *ItemStorage {
 	return &ItemStorage{db: db}
 }
 
+const itemColumns = `id, sku, name, quantity, unit, unit_precision, units, barcodes, category_id, tags,
+	created_at, updated_at, version, deleted_at`
+
+// CreateItem создает товар с начальным остатком. Остаток берется из item.Stock,
+// если он пуст - item.Quantity кладется в место хранения по умолчанию
 func (s *ItemStorage) CreateItem(ctx context.Context, item *models.Item, changedBy string) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -30,58 +51,296 @@ func (s *ItemStorage) CreateItem(ctx context.Context, item *models.Item, changed
 	}
 	defer tx.Rollback()
 
-	// Используем форматирование строки вместо параметризованного запроса
-	_, err = tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL app.username = '%s'", changedBy))
-	if err != nil {
-		return fmt.Errorf("failed to set user context: %w", err)
+	if err = setChangedBy(ctx, tx, changedBy); err != nil {
+		return err
+	}
+
+	if err = createItem(ctx, tx, item, changedBy); err != nil {
+		return err
 	}
 
-	query := `INSERT INTO items (name, quantity) VALUES ($1, $2) RETURNING id, created_at, updated_at`
-	err = tx.QueryRowContext(ctx, query, item.Name, item.Quantity).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+	return tx.Commit()
+}
+
+// createItem добавляет товар и приходует начальный остаток внутри транзакции
+func createItem(ctx context.Context, tx *sql.Tx, item *models.Item, changedBy string) error {
+	unitDefaults(item)
+
+	// quantity пересчитывает триггер item_stock по мере добавления остатков
+	query := `INSERT INTO items (sku, name, quantity, unit, unit_precision, units, barcodes, category_id, tags)
+	          VALUES ($1, $2, 0, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`
+	err := tx.QueryRowContext(ctx, query, item.SKU, item.Name, item.Unit, item.UnitPrecision, item.Units,
+		pq.Array(barcodesOrEmpty(item)), item.CategoryID, pq.Array(tagsOrEmpty(item))).
+		Scan(&item.ID, &item.CreatedAt)
 	if err != nil {
+		if conflict := itemConflict(err); conflict != nil {
+			return conflict
+		}
 		return fmt.Errorf("failed to create item: %w", err)
 	}
 
-	return tx.Commit()
+	stock := item.Stock
+	if len(stock) == 0 && item.Quantity != 0 {
+		locationID, err := defaultLocationID(ctx, tx)
+		if err != nil {
+			return err
+		}
+		stock = []models.ItemStock{{LocationID: locationID, Quantity: item.Quantity}}
+	}
+	for _, st := range stock {
+		if st.Quantity == 0 {
+			continue
+		}
+		err = applyMovement(ctx, tx, &models.StockMovement{
+			ItemID:     item.ID,
+			LocationID: st.LocationID,
+			Type:       models.MovementReceive,
+			Delta:      st.Quantity,
+			Reason:     "initial",
+			CreatedBy:  changedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return reloadItemStock(ctx, tx, item)
 }
 
-func (s *ItemStorage) GetAllItems(ctx context.Context) ([]*models.Item, error) {
-	query := `SELECT id, name, quantity, created_at, updated_at FROM items ORDER BY id`
-	rows, err := s.db.QueryContext(ctx, query)
+// GetAllItems возвращает страницу товаров по фильтрам. Страницы строятся по ключу
+// (значение поля сортировки, id), поэтому вставки и удаления между запросами
+// не сдвигают следующую страницу
+func (s *ItemStorage) GetAllItems(ctx context.Context, q models.ItemQuery) (*models.ItemPage, error) {
+	if q.Sort == "" {
+		q.Sort = "id"
+	}
+	if !models.IsItemSortField(q.Sort) {
+		return nil, storage.ErrInvalidSort
+	}
+
+	b := newItemQueryBuilder(q)
+
+	page := &models.ItemPage{Items: []*models.Item{}}
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM items`+b.where(), b.args...).Scan(&page.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count items: %w", err)
+	}
+
+	if q.Cursor != "" {
+		if err = b.after(q.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `SELECT ` + itemColumns + ` FROM items` + b.where() + b.orderBy()
+	if q.Limit > 0 {
+		// Лишняя строка показывает, есть ли следующая страница
+		query += fmt.Sprintf(" LIMIT %d", q.Limit+1)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, b.args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get items: %w", err)
 	}
 	defer rows.Close()
 
-	var items []*models.Item
 	for rows.Next() {
-		var item models.Item
-		err := rows.Scan(&item.ID, &item.Name, &item.Quantity, &item.CreatedAt, &item.UpdatedAt)
+		item, err := scanItem(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan item: %w", err)
 		}
-		items = append(items, &item)
+		page.Items = append(page.Items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get items: %w", err)
+	}
+
+	if q.Limit > 0 && len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		page.NextCursor = encodeItemCursor(q, page.Items[q.Limit-1])
+	}
+
+	if err = attachStock(ctx, s.db, page.Items); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// itemStockJSON - остатки товара по местам одной колонкой, чтобы выгрузка
+// не делала отдельный запрос остатков на каждую пачку товаров
+const itemStockJSON = `COALESCE((SELECT json_agg(json_build_object(
+		'location_id', s.location_id, 'location_code', l.code,
+		'warehouse_id', l.warehouse_id, 'warehouse_code', w.code,
+		'quantity', s.quantity, 'unit', items.unit) ORDER BY w.code, l.code)
+	FROM item_stock s
+	JOIN locations l ON l.id = s.location_id
+	JOIN warehouses w ON w.id = l.warehouse_id
+	WHERE s.item_id = items.id), '[]')`
+
+// StreamItems передает fn товары под фильтрами query по одному, по мере чтения
+// строк из базы, без накопления результата. Limit и Cursor не учитываются.
+// Ошибка fn прерывает чтение и возвращается как есть
+func (s *ItemStorage) StreamItems(ctx context.Context, q models.ItemQuery, fn func(*models.Item) error) error {
+	if q.Sort == "" {
+		q.Sort = "id"
+	}
+	if !models.IsItemSortField(q.Sort) {
+		return storage.ErrInvalidSort
 	}
 
-	return items, nil
+	b := newItemQueryBuilder(q)
+	query := `SELECT ` + itemColumns + `, ` + itemStockJSON + ` FROM items` + b.where() + b.orderBy()
+
+	rows, err := s.db.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return fmt.Errorf("failed to get items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stock []byte
+		item, err := scanItem(rows, &stock)
+		if err != nil {
+			return fmt.Errorf("failed to scan item: %w", err)
+		}
+		if err = json.Unmarshal(stock, &item.Stock); err != nil {
+			return fmt.Errorf("failed to decode item stock: %w", err)
+		}
+		if err = fn(item); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to get items: %w", err)
+	}
+	return nil
 }
 
 func (s *ItemStorage) GetItemByID(ctx context.Context, id int) (*models.Item, error) {
-	query := `SELECT id, name, quantity, created_at, updated_at FROM items WHERE id = $1`
-	row := s.db.QueryRowContext(ctx, query, id)
+	query := `SELECT ` + itemColumns + ` FROM items WHERE id = $1 AND deleted_at IS NULL`
 
-	var item models.Item
-	err := row.Scan(&item.ID, &item.Name, &item.Quantity, &item.CreatedAt, &item.UpdatedAt)
+	item, err := scanItem(s.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("item not found")
+			return nil, storage.ErrItemNotFound
 		}
 		return nil, fmt.Errorf("failed to get item: %w", err)
 	}
 
-	return &item, nil
+	if err = attachStock(ctx, s.db, []*models.Item{item}); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// GetItemByCode ищет товар по артикулу или по одному из штрихкодов.
+// Совпадение артикула имеет приоритет
+func (s *ItemStorage) GetItemByCode(ctx context.Context, code string) (*models.Item, error) {
+	query := `SELECT ` + itemColumns + ` FROM items
+	          WHERE deleted_at IS NULL
+	            AND (sku = $1 OR id = (SELECT item_id FROM item_barcodes WHERE barcode = $1))
+	          ORDER BY sku = $1 DESC
+	          LIMIT 1`
+
+	item, err := scanItem(s.db.QueryRowContext(ctx, query, code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrItemNotFound
+		}
+		return nil, fmt.Errorf("failed to get item by code: %w", err)
+	}
+
+	if err = attachStock(ctx, s.db, []*models.Item{item}); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// searchQuery ищет по названию полнотекстово (websearch_to_tsquery понимает кавычки,
+// or и минус) и по триграммам: name % $1 ловит опечатки во всем названии,
+// $1 <% name - в отдельном слове. Точное совпадение артикула или штрихкода
+// поднимает товар в начало
+const searchQuery = `WITH q AS (SELECT websearch_to_tsquery('russian', $1) AS tsq)
+	SELECT ` + itemColumns + `, rank,
+	       ts_headline('russian', name, q.tsq, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')
+	FROM (
+	    SELECT items.*,
+	           ts_rank(to_tsvector('russian', name), q.tsq)
+	           + GREATEST(similarity(name, $1), word_similarity($1, name))
+	           + CASE WHEN lower(sku) = lower($1)
+	                    OR id IN (SELECT item_id FROM item_barcodes WHERE barcode = $1) THEN 1 ELSE 0 END AS rank
+	    FROM items, q
+	    WHERE deleted_at IS NULL
+	      AND (to_tsvector('russian', name) @@ q.tsq
+	        OR name % $1
+	        OR $1 <% name
+	        OR sku ILIKE $2
+	        OR id IN (SELECT item_id FROM item_barcodes WHERE barcode = $1))
+	) ranked, q
+	ORDER BY rank DESC, id
+	LIMIT $3`
+
+// SearchItems ищет товары по названию с учетом опечаток, по началу артикула
+// и по штрихкоду. Результаты упорядочены по релевантности
+func (s *ItemStorage) SearchItems(ctx context.Context, text string, limit int) ([]*models.ItemSearchResult, error) {
+	rows, err := s.db.QueryContext(ctx, searchQuery, text, escapeLike(text)+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search items: %w", err)
+	}
+	defer rows.Close()
+
+	results := []*models.ItemSearchResult{}
+	items := []*models.Item{}
+	for rows.Next() {
+		var result models.ItemSearchResult
+		result.Item, err = scanItem(rows, &result.Rank, &result.Highlight)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		results = append(results, &result)
+		items = append(items, result.Item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search items: %w", err)
+	}
+
+	if err = attachStock(ctx, s.db, items); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ListTags возвращает теги действующих товаров с числом товаров, по алфавиту
+func (s *ItemStorage) ListTags(ctx context.Context) ([]models.Tag, error) {
+	query := `SELECT tag, COUNT(*) FROM items, unnest(tags) AS tag
+	          WHERE deleted_at IS NULL GROUP BY tag ORDER BY tag`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var t models.Tag
+		if err = rows.Scan(&t.Name, &t.ItemCount); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
 }
 
+// UpdateItem обновляет товар. Разница между item.Quantity и текущим общим остатком
+// записывается в журнал как корректировка места из item.Stock, если оно задано,
+// иначе места по умолчанию, уже в новой единице товара. Если item.Version задана,
+// она должна совпадать с текущей, иначе возвращается *storage.VersionConflictError.
+// Без версии общий остаток менять нельзя - storage.ErrVersionRequired
 func (s *ItemStorage) UpdateItem(ctx context.Context, item *models.Item, changedBy string) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -89,51 +348,557 @@ func (s *ItemStorage) UpdateItem(ctx context.Context, item *models.Item, changed
 	}
 	defer tx.Rollback()
 
-	// Используем форматирование строки вместо параметризованного запроса
-	_, err = tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL app.username = '%s'", changedBy))
+	if err = setChangedBy(ctx, tx, changedBy); err != nil {
+		return err
+	}
+
+	current, err := lockItem(ctx, tx, item.ID, item.Version)
 	if err != nil {
-		return fmt.Errorf("failed to set user context: %w", err)
+		return err
+	}
+	// Без версии новый общий остаток мог быть рассчитан по устаревшему чтению
+	// и затер бы параллельные движения
+	if item.Version == 0 && item.Quantity != current.Quantity {
+		return storage.ErrVersionRequired
 	}
 
-	query := `UPDATE items SET name = $1, quantity = $2, updated_at = NOW() WHERE id = $3 RETURNING updated_at`
-	err = tx.QueryRowContext(ctx, query, item.Name, item.Quantity, item.ID).Scan(&item.UpdatedAt)
+	unitDefaults(item)
+	if err = checkUnitChange(current, item.Unit, item.UnitPrecision); err != nil {
+		return err
+	}
+
+	query := `UPDATE items SET sku = $1, name = $2, unit = $3, unit_precision = $4, units = $5, barcodes = $6,
+	              category_id = $7, tags = $8, updated_at = NOW()
+	          WHERE id = $9 RETURNING created_at`
+	err = tx.QueryRowContext(ctx, query, item.SKU, item.Name, item.Unit, item.UnitPrecision, item.Units,
+		pq.Array(barcodesOrEmpty(item)), item.CategoryID, pq.Array(tagsOrEmpty(item)), item.ID).
+		Scan(&item.CreatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("item not found")
+		if conflict := itemConflict(err); conflict != nil {
+			return conflict
 		}
 		return fmt.Errorf("failed to update item: %w", err)
 	}
 
+	if delta := item.Quantity - current.Quantity; delta != 0 {
+		var locationID int
+		if len(item.Stock) > 0 {
+			locationID = item.Stock[0].LocationID
+		} else if locationID, err = defaultLocationID(ctx, tx); err != nil {
+			return err
+		}
+		err = applyMovement(ctx, tx, &models.StockMovement{
+			ItemID:     item.ID,
+			LocationID: locationID,
+			Type:       models.MovementAdjust,
+			Delta:      delta,
+			Reason:     "correction",
+			CreatedBy:  changedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = reloadItemStock(ctx, tx, item); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
-func (s *ItemStorage) DeleteItem(ctx context.Context, id int, changedBy string) error {
+// PatchItem обновляет только переданные в patch поля, поэтому снимки в item_history
+// отличаются только ими. Изменение quantity записывается в журнал как корректировка
+// места по умолчанию. version > 0 сверяется с текущей версией, как в UpdateItem
+func (s *ItemStorage) PatchItem(ctx context.Context, id int, version int, patch models.ItemPatch, changedBy string) (*models.Item, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = setChangedBy(ctx, tx, changedBy); err != nil {
+		return nil, err
+	}
+
+	current, err := lockItem(ctx, tx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = patchItem(ctx, tx, current, patch, changedBy); err != nil {
+		return nil, err
+	}
+
+	item, err := scanItem(tx.QueryRowContext(ctx, `SELECT `+itemColumns+` FROM items WHERE id = $1`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload item: %w", err)
+	}
+	if err = attachStock(ctx, tx, []*models.Item{item}); err != nil {
+		return nil, err
+	}
+
+	return item, tx.Commit()
+}
+
+// patchItem применяет patch к заблокированному товару current. Поля товара меняются
+// раньше остатка, чтобы изменение quantity проверялось по новой единице
+func patchItem(ctx context.Context, tx *sql.Tx, current *models.Item, patch models.ItemPatch, changedBy string) error {
+	unit, precision := current.Unit, current.UnitPrecision
+	if patch.Unit != nil {
+		unit = *patch.Unit
+	}
+	if patch.UnitPrecision != nil {
+		precision = *patch.UnitPrecision
+	}
+	if err := checkUnitChange(current, unit, precision); err != nil {
+		return err
+	}
+
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if patch.SKU != nil {
+		set("sku", *patch.SKU)
+	}
+	if patch.Name != nil {
+		set("name", *patch.Name)
+	}
+	if patch.Unit != nil {
+		set("unit", *patch.Unit)
+	}
+	if patch.UnitPrecision != nil {
+		set("unit_precision", *patch.UnitPrecision)
+	}
+	if patch.Units != nil {
+		set("units", *patch.Units)
+	}
+	if patch.Barcodes != nil {
+		set("barcodes", pq.Array(*patch.Barcodes))
+	}
+	if patch.CategoryID != nil {
+		if *patch.CategoryID == 0 {
+			set("category_id", nil)
+		} else {
+			set("category_id", *patch.CategoryID)
+		}
+	}
+	if patch.Tags != nil {
+		set("tags", pq.Array(*patch.Tags))
+	}
+
+	if len(sets) > 0 {
+		args = append(args, current.ID)
+		query := `UPDATE items SET ` + strings.Join(sets, ", ") + fmt.Sprintf(`, updated_at = NOW() WHERE id = $%d`, len(args))
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			if conflict := itemConflict(err); conflict != nil {
+				return conflict
+			}
+			return fmt.Errorf("failed to patch item: %w", err)
+		}
+	}
+
+	if patch.Quantity != nil && *patch.Quantity != current.Quantity {
+		locationID, err := defaultLocationID(ctx, tx)
+		if err != nil {
+			return err
+		}
+		err = applyMovement(ctx, tx, &models.StockMovement{
+			ItemID:     current.ID,
+			LocationID: locationID,
+			Type:       models.MovementAdjust,
+			Delta:      *patch.Quantity - current.Quantity,
+			Reason:     "correction",
+			CreatedBy:  changedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetStock устанавливает остаток товара в месте хранения по результату пересчета.
+// quantity задается в единице unit, пустая - в базовой. Разница с текущим остатком
+// записывается в журнал как корректировка с причиной count
+func (s *ItemStorage) SetStock(ctx context.Context, itemID, locationID int, quantity models.Quantity, unit, changedBy string) error {
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Используем форматирование строки вместо параметризованного запроса
-	_, err = tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL app.username = '%s'", changedBy))
+	if err = setChangedBy(ctx, tx, changedBy); err != nil {
+		return err
+	}
+
+	item, err := lockItem(ctx, tx, itemID, 0)
 	if err != nil {
-		return fmt.Errorf("failed to set user context: %w", err)
+		return err
+	}
+	if quantity, err = toBase(item, quantity, unit); err != nil {
+		return err
 	}
 
-	query := `DELETE FROM items WHERE id = $1`
-	result, err := tx.ExecContext(ctx, query, id)
+	var current models.Quantity
+	query := `SELECT quantity FROM item_stock WHERE item_id = $1 AND location_id = $2`
+	err = tx.QueryRowContext(ctx, query, itemID, locationID).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get stock: %w", err)
+	}
+
+	if delta := quantity - current; delta != 0 {
+		err = applyMovement(ctx, tx, &models.StockMovement{
+			ItemID:     itemID,
+			LocationID: locationID,
+			Type:       models.MovementAdjust,
+			Delta:      delta,
+			Reason:     "count",
+			CreatedBy:  changedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteItem переносит товар в корзину. version > 0 сверяется с текущей версией,
+// как в UpdateItem. Остатки, артикул и штрихкоды остаются за товаром до purge
+func (s *ItemStorage) DeleteItem(ctx context.Context, id int, version int, changedBy string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = setChangedBy(ctx, tx, changedBy); err != nil {
+		return err
+	}
+
+	if _, err = lockItem(ctx, tx, id, version); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE items SET deleted_at = NOW() WHERE id = $1`, id); err != nil {
 		return fmt.Errorf("failed to delete item: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return tx.Commit()
+}
+
+// RestoreItem возвращает товар из корзины. ErrItemNotFound - товара нет в корзине
+func (s *ItemStorage) RestoreItem(ctx context.Context, id int, changedBy string) (*models.Item, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = setChangedBy(ctx, tx, changedBy); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE items SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING ` + itemColumns
+	item, err := scanItem(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrItemNotFound
+		}
+		return nil, fmt.Errorf("failed to restore item: %w", err)
+	}
+
+	if err = attachStock(ctx, tx, []*models.Item{item}); err != nil {
+		return nil, err
+	}
+
+	return item, tx.Commit()
+}
+
+// PurgeItem окончательно удаляет товар из корзины вместе с остатками и штрихкодами.
+// Остатки перед удалением списываются корректировками, чтобы журнал движений товара
+// сходился к нулю. История и журнал сохраняются, удаление записывается в историю как purge
+func (s *ItemStorage) PurgeItem(ctx context.Context, id int, changedBy string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = setChangedBy(ctx, tx, changedBy); err != nil {
+		return err
+	}
+
+	var deletedAt *time.Time
+	err = tx.QueryRowContext(ctx, `SELECT deleted_at FROM items WHERE id = $1 FOR UPDATE`, id).Scan(&deletedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ErrItemNotFound
+		}
+		return fmt.Errorf("failed to lock item: %w", err)
+	}
+	if deletedAt == nil {
+		return storage.ErrItemNotDeleted
+	}
+
+	if err = clearStock(ctx, tx, id, changedBy); err != nil {
+		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("item not found")
+	if _, err = tx.ExecContext(ctx, `DELETE FROM items WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to purge item: %w", err)
 	}
 
 	return tx.Commit()
 }
+
+// clearStock обнуляет остатки товара во всех местах корректировками с причиной other
+func clearStock(ctx context.Context, tx *sql.Tx, itemID int, changedBy string) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT location_id, quantity FROM item_stock WHERE item_id = $1 AND quantity <> 0 ORDER BY location_id`, itemID)
+	if err != nil {
+		return fmt.Errorf("failed to get item stock: %w", err)
+	}
+
+	var stock []models.ItemStock
+	for rows.Next() {
+		var st models.ItemStock
+		if err = rows.Scan(&st.LocationID, &st.Quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan item stock: %w", err)
+		}
+		stock = append(stock, st)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to get item stock: %w", err)
+	}
+
+	for _, st := range stock {
+		err = applyMovement(ctx, tx, &models.StockMovement{
+			ItemID:     itemID,
+			LocationID: st.LocationID,
+			Type:       models.MovementAdjust,
+			Delta:      -st.Quantity,
+			Reason:     "other",
+			Note:       "purge",
+			CreatedBy:  changedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockItem блокирует строку товара до конца транзакции и возвращает общий остаток,
+// версию и единицы товара. expectedVersion > 0 сверяется с текущей версией товара.
+// Товар в корзине не найден
+func lockItem(ctx context.Context, tx *sql.Tx, itemID, expectedVersion int) (*models.Item, error) {
+	item := &models.Item{ID: itemID}
+	query := `SELECT quantity, version, unit, unit_precision, units FROM items WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	err := tx.QueryRowContext(ctx, query, itemID).Scan(&item.Quantity, &item.Version, &item.Unit, &item.UnitPrecision, &item.Units)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrItemNotFound
+		}
+		return nil, fmt.Errorf("failed to lock item: %w", err)
+	}
+
+	if expectedVersion > 0 && expectedVersion != item.Version {
+		return nil, &storage.VersionConflictError{Expected: expectedVersion, Actual: item.Version}
+	}
+	return item, nil
+}
+
+// checkUnitChange запрещает менять базовую единицу и уменьшать ее точность, пока
+// у товара есть остаток: остатки и журнал движений записаны в прежней единице
+func checkUnitChange(current *models.Item, unit string, precision int) error {
+	if current.Quantity != 0 && (unit != current.Unit || precision < current.UnitPrecision) {
+		return storage.ErrUnitInUse
+	}
+	return nil
+}
+
+// adjustStock изменяет остаток в месте на delta. Опустевшая строка удаляется.
+// Вызывается только через applyMovement, чтобы каждое изменение попало в журнал
+func adjustStock(ctx context.Context, tx *sql.Tx, itemID, locationID int, delta models.Quantity) error {
+	var quantity models.Quantity
+	query := `INSERT INTO item_stock (item_id, location_id, quantity) VALUES ($1, $2, $3)
+	          ON CONFLICT (item_id, location_id)
+	          DO UPDATE SET quantity = item_stock.quantity + EXCLUDED.quantity, updated_at = NOW()
+	          RETURNING quantity`
+	if err := tx.QueryRowContext(ctx, query, itemID, locationID, delta).Scan(&quantity); err != nil {
+		return stockError(err)
+	}
+
+	if quantity == 0 {
+		_, err := tx.ExecContext(ctx, `DELETE FROM item_stock WHERE item_id = $1 AND location_id = $2`, itemID, locationID)
+		if err != nil {
+			return fmt.Errorf("failed to delete empty stock: %w", err)
+		}
+	}
+	return nil
+}
+
+func defaultLocationID(ctx context.Context, tx *sql.Tx) (int, error) {
+	var id int
+	if err := tx.QueryRowContext(ctx, `SELECT id FROM locations WHERE is_default`).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, storage.ErrNoDefaultLocation
+		}
+		return 0, fmt.Errorf("failed to get default location: %w", err)
+	}
+	return id, nil
+}
+
+// stockError переводит нарушения ограничений item_stock в ошибки storage
+func stockError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23514" && pqErr.Constraint == "item_stock_quantity_check":
+			return storage.ErrInsufficientStock
+		case pqErr.Code == "23503" && pqErr.Constraint == "item_stock_location_id_fkey":
+			return storage.ErrLocationNotFound
+		}
+	}
+	return fmt.Errorf("failed to update stock: %w", err)
+}
+
+const stockQuery = `SELECT s.item_id, s.location_id, l.code, l.warehouse_id, w.code, s.quantity, i.unit
+	FROM item_stock s
+	JOIN items i ON i.id = s.item_id
+	JOIN locations l ON l.id = s.location_id
+	JOIN warehouses w ON w.id = l.warehouse_id`
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// attachStock заполняет разбивку остатков по местам для товаров одним запросом
+func attachStock(ctx context.Context, q queryer, items []*models.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.Item, len(items))
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		item.Stock = []models.ItemStock{}
+		byID[item.ID] = item
+		ids = append(ids, int64(item.ID))
+	}
+
+	rows, err := q.QueryContext(ctx, stockQuery+` WHERE s.item_id = ANY($1) ORDER BY w.code, l.code`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to get item stock: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		st, err := scanStock(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan stock: %w", err)
+		}
+		if item, ok := byID[st.ItemID]; ok {
+			item.Stock = append(item.Stock, st)
+		}
+	}
+
+	return rows.Err()
+}
+
+// reloadItemStock перечитывает пересчитанный триггером остаток после изменения товара
+func reloadItemStock(ctx context.Context, tx *sql.Tx, item *models.Item) error {
+	err := tx.QueryRowContext(ctx, `SELECT quantity, updated_at, version FROM items WHERE id = $1`, item.ID).
+		Scan(&item.Quantity, &item.UpdatedAt, &item.Version)
+	if err != nil {
+		return fmt.Errorf("failed to reload item: %w", err)
+	}
+	return attachStock(ctx, tx, []*models.Item{item})
+}
+
+func scanStock(row rowScanner) (models.ItemStock, error) {
+	var st models.ItemStock
+	err := row.Scan(&st.ItemID, &st.LocationID, &st.LocationCode, &st.WarehouseID, &st.WarehouseCode, &st.Quantity, &st.Unit)
+	return st, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanItem читает колонки itemColumns и следующие за ними колонки в extra
+func scanItem(row rowScanner, extra ...interface{}) (*models.Item, error) {
+	var item models.Item
+	dest := []interface{}{&item.ID, &item.SKU, &item.Name, &item.Quantity, &item.Unit, &item.UnitPrecision, &item.Units,
+		pq.Array(&item.Barcodes), &item.CategoryID, pq.Array(&item.Tags), &item.CreatedAt, &item.UpdatedAt, &item.Version, &item.DeletedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	if item.Barcodes == nil {
+		item.Barcodes = []string{}
+	}
+	if item.Tags == nil {
+		item.Tags = []string{}
+	}
+	return &item, nil
+}
+
+// unitDefaults задает штуки товару без базовой единицы
+func unitDefaults(item *models.Item) {
+	if item.Unit == "" {
+		item.Unit = models.DefaultUnit
+	}
+	if item.Units == nil {
+		item.Units = models.ItemUnits{}
+	}
+}
+
+func barcodesOrEmpty(item *models.Item) []string {
+	if item.Barcodes == nil {
+		return []string{}
+	}
+	return item.Barcodes
+}
+
+func tagsOrEmpty(item *models.Item) []string {
+	if item.Tags == nil {
+		return []string{}
+	}
+	return item.Tags
+}
+
+// itemConflict переводит нарушение уникальности артикула или штрихкода
+// и ссылку на несуществующую категорию в ошибку storage
+func itemConflict(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+
+	switch pqErr.Constraint {
+	case "items_sku_key":
+		return storage.ErrSKUExists
+	case "item_barcodes_pkey":
+		return storage.ErrBarcodeExists
+	case "items_category_id_fkey":
+		return storage.ErrCategoryNotFound
+	}
+	return nil
+}
+
+// setChangedBy передает имя пользователя триггеру log_item_change через app.username.
+// set_config(..., true) действует до конца транзакции, как SET LOCAL, но принимает параметр
+func setChangedBy(ctx context.Context, tx *sql.Tx, changedBy string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('app.username', $1, true)`, changedBy); err != nil {
+		return fmt.Errorf("failed to set user context: %w", err)
+	}
+	return nil
+}
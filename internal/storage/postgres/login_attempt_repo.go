@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type LoginAttemptStorageI interface {
+	GetBlockedUntil(ctx context.Context, scope models.AttemptScope, key string) (time.Time, error)
+	RegisterFailure(ctx context.Context, scope models.AttemptScope, key string, window time.Duration) (int, error)
+	Block(ctx context.Context, scope models.AttemptScope, key string, delay time.Duration) error
+	Reset(ctx context.Context, scope models.AttemptScope, key string) error
+}
+
+type LoginAttemptStorage struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptStorage(db *sql.DB) *LoginAttemptStorage {
+	return &LoginAttemptStorage{db: db}
+}
+
+// GetBlockedUntil возвращает момент, до которого попытки входа запрещены,
+// или нулевое время, если блокировки нет
+func (s *LoginAttemptStorage) GetBlockedUntil(ctx context.Context, scope models.AttemptScope, key string) (time.Time, error) {
+	var blockedUntil sql.NullTime
+	query := `SELECT blocked_until FROM login_attempts WHERE scope = $1 AND key = $2 AND blocked_until > NOW()`
+	err := s.db.QueryRowContext(ctx, query, scope, key).Scan(&blockedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get login attempts: %w", err)
+	}
+
+	return blockedUntil.Time, nil
+}
+
+// RegisterFailure атомарно увеличивает счетчик неудачных попыток и возвращает его.
+// Если с последней неудачи прошло больше window, счетчик начинается заново
+func (s *LoginAttemptStorage) RegisterFailure(ctx context.Context, scope models.AttemptScope, key string, window time.Duration) (int, error) {
+	query := `INSERT INTO login_attempts (scope, key, failures, last_failure_at)
+	          VALUES ($1, $2, 1, NOW())
+	          ON CONFLICT (scope, key) DO UPDATE SET
+	              failures = CASE
+	                  WHEN login_attempts.last_failure_at < NOW() - make_interval(secs => $3) THEN 1
+	                  ELSE login_attempts.failures + 1
+	              END,
+	              last_failure_at = NOW()
+	          RETURNING failures`
+
+	var failures int
+	err := s.db.QueryRowContext(ctx, query, scope, key, window.Seconds()).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("failed to register login failure: %w", err)
+	}
+
+	return failures, nil
+}
+
+// Block запрещает попытки входа на delay от текущего момента. Срок считается по часам
+// базы, как и проверка в GetBlockedUntil. Более длинная блокировка не сокращается
+func (s *LoginAttemptStorage) Block(ctx context.Context, scope models.AttemptScope, key string, delay time.Duration) error {
+	query := `UPDATE login_attempts SET blocked_until = GREATEST(blocked_until, NOW() + make_interval(secs => $3))
+	          WHERE scope = $1 AND key = $2`
+	if _, err := s.db.ExecContext(ctx, query, scope, key, delay.Seconds()); err != nil {
+		return fmt.Errorf("failed to block login attempts: %w", err)
+	}
+	return nil
+}
+
+func (s *LoginAttemptStorage) Reset(ctx context.Context, scope models.AttemptScope, key string) error {
+	query := `DELETE FROM login_attempts WHERE scope = $1 AND key = $2`
+	if _, err := s.db.ExecContext(ctx, query, scope, key); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// migrationLockID - ключ advisory lock, чтобы две копии не применяли миграции одновременно
+const migrationLockID = 20250101
+
+var upMigrationRe = regexp.MustCompile(`^(\d+)_.+\.up\.sql$`)
+
+type migration struct {
+	version uint
+	path    string
+}
+
+// MigrationVersion возвращает текущую версию схемы. Версия хранится в таблице
+// schema_migrations в формате golang-migrate, поэтому можно пользоваться
+// как этим кодом, так и контейнером migrate из docker-compose
+func MigrationVersion(ctx context.Context, db *sql.DB) (uint, bool, error) {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`); err != nil {
+		return 0, false, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var version uint
+	var dirty bool
+	err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, false, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	return version, dirty, nil
+}
+
+// Migrate применяет *.up.sql из dir, версия которых больше текущей. Каждая
+// миграция выполняется в своей транзакции вместе с обновлением версии.
+// Возвращает версии примененных миграций
+func Migrate(ctx context.Context, db *sql.DB, dir string) ([]uint, error) {
+	migrations, err := readMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	current, dirty, err := MigrationVersion(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, fmt.Errorf("schema version %d is dirty, fix it manually first", current)
+	}
+
+	var applied []uint
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err = applyMigration(ctx, conn, m); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m.version)
+	}
+
+	return applied, nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, m migration) error {
+	body, err := os.ReadFile(m.path)
+	if err != nil {
+		return fmt.Errorf("failed to read migration %d: %w", m.version, err)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, string(body)); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", filepath.Base(m.path), err)
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)`, m.version); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func readMigrations(dir string) ([]migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir: %w", err)
+	}
+
+	var migrations []migration
+	for _, e := range entries {
+		match := upMigrationRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", e.Name(), err)
+		}
+		migrations = append(migrations, migration{version: uint(version), path: filepath.Join(dir, e.Name())})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	return migrations, nil
+}
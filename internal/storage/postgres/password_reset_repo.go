@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type PasswordResetStorageI interface {
+	CreateResetToken(ctx context.Context, token *models.PasswordResetToken) error
+	GetResetTokenUserID(ctx context.Context, tokenHash string) (int, error)
+	ResetPassword(ctx context.Context, tokenHash string, passwordHash string, historySize int) (int, error)
+}
+
+type PasswordResetStorage struct {
+	db *sql.DB
+}
+
+func NewPasswordResetStorage(db *sql.DB) *PasswordResetStorage {
+	return &PasswordResetStorage{db: db}
+}
+
+// CreateResetToken сохраняет новый токен сброса. Ранее выпущенные
+// и еще не использованные токены пользователя удаляются
+func (s *PasswordResetStorage) CreateResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, token.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to delete previous reset tokens: %w", err)
+	}
+
+	query := `INSERT INTO password_reset_tokens (user_id, token_hash, created_by, expires_at)
+	          VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err = tx.QueryRowContext(ctx, query, token.UserID, token.TokenHash, token.CreatedBy, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create reset token: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetResetTokenUserID проверяет токен сброса, не погашая его, и возвращает id
+// пользователя. Нужен, чтобы сверить новый пароль с историей до сброса
+func (s *PasswordResetStorage) GetResetTokenUserID(ctx context.Context, tokenHash string) (int, error) {
+	token, err := getResetToken(ctx, s.db, tokenHash, "")
+	if err != nil {
+		return 0, err
+	}
+	return token.UserID, nil
+}
+
+// ResetPassword погашает токен сброса, устанавливает новый хеш пароля
+// и отзывает все сессии пользователя в одной транзакции. Возвращает id пользователя
+func (s *PasswordResetStorage) ResetPassword(ctx context.Context, tokenHash string, passwordHash string, historySize int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	token, err := getResetToken(ctx, tx, tokenHash, " FOR UPDATE")
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE password_reset_tokens SET used_at = NOW() WHERE id = $1`, token.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark reset token used: %w", err)
+	}
+
+	if err = setPasswordHash(ctx, tx, token.UserID, passwordHash, historySize); err != nil {
+		return 0, err
+	}
+
+	if _, err = revokeUserSessions(ctx, tx, token.UserID); err != nil {
+		return 0, err
+	}
+
+	return token.UserID, tx.Commit()
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// getResetToken возвращает действующий токен сброса по хешу
+func getResetToken(ctx context.Context, q queryRower, tokenHash string, lock string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	query := `SELECT id, user_id, expires_at, used_at FROM password_reset_tokens WHERE token_hash = $1` + lock
+	err := q.QueryRowContext(ctx, query, tokenHash).Scan(&token.ID, &token.UserID, &token.ExpiresAt, &token.UsedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get reset token: %w", err)
+	}
+
+	if token.UsedAt != nil {
+		return nil, storage.ErrTokenUsed
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, storage.ErrTokenExpired
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type StockMovementStorageI interface {
+	RecordMovement(ctx context.Context, movement *models.StockMovement, changedBy string) error
+	Transfer(ctx context.Context, itemID, fromLocationID, toLocationID int, quantity models.Quantity, unit, note, changedBy string) ([]*models.StockMovement, error)
+	ListMovements(ctx context.Context, itemID, limit, offset int) ([]*models.StockMovement, error)
+}
+
+type StockMovementStorage struct {
+	db *sql.DB
+}
+
+func NewStockMovementStorage(db *sql.DB) *StockMovementStorage {
+	return &StockMovementStorage{db: db}
+}
+
+// RecordMovement проводит приход, расход или корректировку. Без LocationID
+// используется место по умолчанию. Если задана EnteredUnit, Delta вычисляется
+// из EnteredQuantity по коэффициенту единицы товара. Строка товара блокируется,
+// поэтому параллельные движения по одному товару выполняются по очереди
+func (s *StockMovementStorage) RecordMovement(ctx context.Context, movement *models.StockMovement, changedBy string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = setChangedBy(ctx, tx, changedBy); err != nil {
+		return err
+	}
+
+	item, err := lockItem(ctx, tx, movement.ItemID, 0)
+	if err != nil {
+		return err
+	}
+
+	if movement.EnteredUnit != nil {
+		if movement.Delta, err = toBase(item, *movement.EnteredQuantity, *movement.EnteredUnit); err != nil {
+			return err
+		}
+	}
+
+	if movement.LocationID == 0 {
+		if movement.LocationID, err = defaultLocationID(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	movement.CreatedBy = changedBy
+	if err = applyMovement(ctx, tx, movement); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Transfer перемещает остаток между местами: две строки журнала
+// с общим transfer_id, расход из одного места и приход в другое.
+// quantity задается в единице unit, пустая - в базовой
+func (s *StockMovementStorage) Transfer(ctx context.Context, itemID, fromLocationID, toLocationID int, quantity models.Quantity, unit, note, changedBy string) ([]*models.StockMovement, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = setChangedBy(ctx, tx, changedBy); err != nil {
+		return nil, err
+	}
+
+	item, err := lockItem(ctx, tx, itemID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := toBase(item, quantity, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	var transferID string
+	if err = tx.QueryRowContext(ctx, `SELECT gen_random_uuid()::text`).Scan(&transferID); err != nil {
+		return nil, fmt.Errorf("failed to generate transfer id: %w", err)
+	}
+
+	movements := []*models.StockMovement{
+		{LocationID: fromLocationID, Delta: -base},
+		{LocationID: toLocationID, Delta: base},
+	}
+	for i, m := range movements {
+		if unit != "" {
+			entered := quantity
+			if i == 0 {
+				entered = -quantity
+			}
+			m.EnteredQuantity, m.EnteredUnit = &entered, &unit
+		}
+		m.ItemID = itemID
+		m.Type = models.MovementTransfer
+		m.Reason = "relocation"
+		m.Note = note
+		m.TransferID = &transferID
+		m.CreatedBy = changedBy
+		if err = applyMovement(ctx, tx, m); err != nil {
+			return nil, err
+		}
+	}
+
+	return movements, tx.Commit()
+}
+
+// ListMovements возвращает журнал движений товара, новые записи первыми
+func (s *StockMovementStorage) ListMovements(ctx context.Context, itemID, limit, offset int) ([]*models.StockMovement, error) {
+	query := `SELECT id, item_id, location_id, movement_type, delta, unit, entered_quantity, entered_unit,
+	                 reason, note, transfer_id, created_by, created_at
+	          FROM stock_movements WHERE item_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3`
+	rows, err := s.db.QueryContext(ctx, query, itemID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock movements: %w", err)
+	}
+	defer rows.Close()
+
+	movements := []*models.StockMovement{}
+	for rows.Next() {
+		var m models.StockMovement
+		err = rows.Scan(&m.ID, &m.ItemID, &m.LocationID, &m.Type, &m.Delta, &m.Unit, &m.EnteredQuantity, &m.EnteredUnit,
+			&m.Reason, &m.Note, &m.TransferID, &m.CreatedBy, &m.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock movement: %w", err)
+		}
+		movements = append(movements, &m)
+	}
+
+	return movements, rows.Err()
+}
+
+// applyMovement изменяет остаток в месте и записывает движение в журнал.
+// Delta должна укладываться в точность базовой единицы товара, сама единица
+// записывается в движение. Единственный путь изменения item_stock, вызывается
+// внутри транзакции с заблокированной строкой товара
+func applyMovement(ctx context.Context, tx *sql.Tx, m *models.StockMovement) error {
+	var precision int
+	err := tx.QueryRowContext(ctx, `SELECT unit, unit_precision FROM items WHERE id = $1`, m.ItemID).Scan(&m.Unit, &precision)
+	if err != nil {
+		return fmt.Errorf("failed to get item unit: %w", err)
+	}
+	if !m.Delta.FitsPrecision(precision) {
+		return fmt.Errorf("%w: %s %s, allowed decimal places: %d", storage.ErrQuantityPrecision, m.Delta, m.Unit, precision)
+	}
+
+	if err = adjustStock(ctx, tx, m.ItemID, m.LocationID, m.Delta); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO stock_movements (item_id, location_id, movement_type, delta, unit, entered_quantity, entered_unit,
+	              reason, note, transfer_id, created_by)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, created_at`
+	err = tx.QueryRowContext(ctx, query, m.ItemID, m.LocationID, m.Type, m.Delta, m.Unit, m.EnteredQuantity, m.EnteredUnit,
+		m.Reason, m.Note, m.TransferID, m.CreatedBy).
+		Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record stock movement: %w", err)
+	}
+	return nil
+}
+
+// toBase переводит количество в единице unit в базовую единицу товара, пустая
+// unit - уже базовая. Точность базовой единицы проверяет applyMovement
+func toBase(item *models.Item, quantity models.Quantity, unit string) (models.Quantity, error) {
+	if unit == "" {
+		return quantity, nil
+	}
+	factor, ok := item.Factor(unit)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", storage.ErrUnknownUnit, unit)
+	}
+	base, ok := quantity.Mul(factor)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s %s cannot be converted to %s exactly", storage.ErrQuantityPrecision, quantity, unit, item.Unit)
+	}
+	return base, nil
+}
@@ -0,0 +1,252 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type TokenStorageI interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, oldHash string, next *models.RefreshToken) error
+	RevokeRefreshToken(ctx context.Context, tokenHash string, userID int) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeUserSessions(ctx context.Context, userID int) (int, error)
+	RevokeOtherUserSessions(ctx context.Context, userID int, keepJTI string) (int, error)
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpiredTokens(ctx context.Context) error
+}
+
+type TokenStorage struct {
+	db *sql.DB
+}
+
+func NewTokenStorage(db *sql.DB) *TokenStorage {
+	return &TokenStorage{db: db}
+}
+
+func (s *TokenStorage) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (user_id, token_hash, access_jti, access_expires_at, expires_at)
+	          VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	err := s.db.QueryRowContext(ctx, query,
+		token.UserID, token.TokenHash, token.AccessJTI, token.AccessExpiresAt, token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken отзывает refresh токен oldHash и сохраняет next для того же пользователя.
+// Повторное предъявление уже отозванного токена считается кражей: все сессии
+// пользователя отзываются и возвращается storage.ErrTokenReused
+func (s *TokenStorage) RotateRefreshToken(ctx context.Context, oldHash string, next *models.RefreshToken) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var old models.RefreshToken
+	query := `SELECT id, user_id, access_jti, access_expires_at, expires_at, revoked_at
+	          FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, oldHash).
+		Scan(&old.ID, &old.UserID, &old.AccessJTI, &old.AccessExpiresAt, &old.ExpiresAt, &old.RevokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ErrTokenNotFound
+		}
+		return fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	// Заполняется и при ошибке, чтобы вызывающий знал, чей токен предъявлен
+	next.UserID = old.UserID
+
+	if old.RevokedAt != nil {
+		if _, err = revokeUserSessions(ctx, tx, old.UserID); err != nil {
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return storage.ErrTokenReused
+	}
+
+	if time.Now().After(old.ExpiresAt) {
+		return storage.ErrTokenExpired
+	}
+
+	if err = revokeRefreshToken(ctx, tx, old.ID); err != nil {
+		return err
+	}
+	if err = revokeAccessToken(ctx, tx, old.AccessJTI, old.AccessExpiresAt); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO refresh_tokens (user_id, token_hash, access_jti, access_expires_at, expires_at)
+	         VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	err = tx.QueryRowContext(ctx, query,
+		next.UserID, next.TokenHash, next.AccessJTI, next.AccessExpiresAt, next.ExpiresAt,
+	).Scan(&next.ID, &next.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *TokenStorage) RevokeRefreshToken(ctx context.Context, tokenHash string, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var token models.RefreshToken
+	query := `SELECT id, access_jti, access_expires_at FROM refresh_tokens
+	          WHERE token_hash = $1 AND user_id = $2 AND revoked_at IS NULL FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, tokenHash, userID).Scan(&token.ID, &token.AccessJTI, &token.AccessExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ErrTokenNotFound
+		}
+		return fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if err = revokeRefreshToken(ctx, tx, token.ID); err != nil {
+		return err
+	}
+	if err = revokeAccessToken(ctx, tx, token.AccessJTI, token.AccessExpiresAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *TokenStorage) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = revokeAccessToken(ctx, tx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeUserSessions отзывает все активные refresh токены пользователя
+// и выданные вместе с ними access токены
+func (s *TokenStorage) RevokeUserSessions(ctx context.Context, userID int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	revoked, err := revokeUserSessions(ctx, tx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return revoked, tx.Commit()
+}
+
+// RevokeOtherUserSessions отзывает все сессии пользователя, кроме той,
+// к которой относится access токен keepJTI
+func (s *TokenStorage) RevokeOtherUserSessions(ctx context.Context, userID int, keepJTI string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO revoked_tokens (jti, expires_at)
+	          SELECT access_jti, access_expires_at FROM refresh_tokens
+	          WHERE user_id = $1 AND access_jti <> $2 AND revoked_at IS NULL AND access_expires_at > NOW()
+	          ON CONFLICT (jti) DO NOTHING`
+	if _, err = tx.ExecContext(ctx, query, userID, keepJTI); err != nil {
+		return 0, fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND access_jti <> $2 AND revoked_at IS NULL`,
+		userID, keepJTI)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(revoked), tx.Commit()
+}
+
+func (s *TokenStorage) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+	if err := s.db.QueryRowContext(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+// DeleteExpiredTokens удаляет записи, которые уже не могут быть предъявлены
+func (s *TokenStorage) DeleteExpiredTokens(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("failed to delete expired revoked tokens: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE expires_at < NOW()`); err != nil {
+		return fmt.Errorf("failed to delete expired mfa challenges: %w", err)
+	}
+	return nil
+}
+
+func revokeRefreshToken(ctx context.Context, tx *sql.Tx, id int) error {
+	_, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	return nil
+}
+
+func revokeAccessToken(ctx context.Context, tx *sql.Tx, jti string, expiresAt time.Time) error {
+	query := `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, jti, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return nil
+}
+
+func revokeUserSessions(ctx context.Context, tx *sql.Tx, userID int) (int, error) {
+	query := `INSERT INTO revoked_tokens (jti, expires_at)
+	          SELECT access_jti, access_expires_at FROM refresh_tokens
+	          WHERE user_id = $1 AND revoked_at IS NULL AND access_expires_at > NOW()
+	          ON CONFLICT (jti) DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return 0, fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(revoked), nil
+}
@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type TwoFactorStorageI interface {
+	GetTOTP(ctx context.Context, userID int) (*models.UserTOTP, error)
+	SaveTOTPSecret(ctx context.Context, userID int, secret string) error
+	EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error
+	DisableTOTP(ctx context.Context, userID int) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	CountRecoveryCodes(ctx context.Context, userID int) (int, error)
+	CreateMFAChallenge(ctx context.Context, challenge *models.MFAChallenge) error
+	UseMFAChallenge(ctx context.Context, tokenHash string, maxAttempts int) (*models.MFAChallenge, error)
+	DeleteMFAChallenge(ctx context.Context, tokenHash string) error
+}
+
+type TwoFactorStorage struct {
+	db *sql.DB
+}
+
+func NewTwoFactorStorage(db *sql.DB) *TwoFactorStorage {
+	return &TwoFactorStorage{db: db}
+}
+
+func (s *TwoFactorStorage) GetTOTP(ctx context.Context, userID int) (*models.UserTOTP, error) {
+	query := `SELECT user_id, secret, enabled, last_used_step, confirmed_at, created_at FROM user_totp WHERE user_id = $1`
+
+	var t models.UserTOTP
+	err := s.db.QueryRowContext(ctx, query, userID).
+		Scan(&t.UserID, &t.Secret, &t.Enabled, &t.LastUsedStep, &t.ConfirmedAt, &t.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrTOTPNotFound
+		}
+		return nil, fmt.Errorf("failed to get totp: %w", err)
+	}
+
+	return &t, nil
+}
+
+// SaveTOTPSecret сохраняет секрет неподтвержденной регистрации. Повторный вызов
+// заменяет секрет, пока регистрация не подтверждена кодом
+func (s *TwoFactorStorage) SaveTOTPSecret(ctx context.Context, userID int, secret string) error {
+	query := `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+	          ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = NOW()
+	          WHERE NOT user_totp.enabled`
+	result, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to save totp secret: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrTOTPAlreadyEnabled
+	}
+
+	return nil
+}
+
+// EnableTOTP подтверждает регистрацию и выпускает коды восстановления
+func (s *TwoFactorStorage) EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE user_totp SET enabled = TRUE, confirmed_at = NOW(), last_used_step = $2
+	          WHERE user_id = $1 AND NOT enabled`
+	result, err := tx.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to enable totp: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrTOTPAlreadyEnabled
+	}
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *TwoFactorStorage) DisableTOTP(ctx context.Context, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete totp: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrTOTPNotFound
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep запоминает шаг принятого кода. Код того же или более раннего
+// шага повторно не принимается
+func (s *TwoFactorStorage) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	query := `UPDATE user_totp SET last_used_step = $2
+	          WHERE user_id = $1 AND enabled AND (last_used_step IS NULL OR last_used_step < $2)`
+	result, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to update totp step: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrTOTPCodeUsed
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStorage) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	query := `UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	result, err := s.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrRecoveryCodeInvalid
+	}
+
+	return nil
+}
+
+func (s *TwoFactorStorage) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CountRecoveryCodes возвращает число неиспользованных кодов восстановления
+func (s *TwoFactorStorage) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+func (s *TwoFactorStorage) CreateMFAChallenge(ctx context.Context, challenge *models.MFAChallenge) error {
+	query := `INSERT INTO mfa_challenges (user_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id, created_at`
+	err := s.db.QueryRowContext(ctx, query, challenge.UserID, challenge.TokenHash, challenge.ExpiresAt).
+		Scan(&challenge.ID, &challenge.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create mfa challenge: %w", err)
+	}
+	return nil
+}
+
+// UseMFAChallenge засчитывает попытку проверки второго фактора. Истекший
+// challenge или challenge с исчерпанными попытками не возвращается
+func (s *TwoFactorStorage) UseMFAChallenge(ctx context.Context, tokenHash string, maxAttempts int) (*models.MFAChallenge, error) {
+	query := `UPDATE mfa_challenges SET attempts = attempts + 1
+	          WHERE token_hash = $1 AND attempts < $2 AND expires_at > NOW()
+	          RETURNING id, user_id, attempts, expires_at, created_at`
+
+	c := models.MFAChallenge{TokenHash: tokenHash}
+	err := s.db.QueryRowContext(ctx, query, tokenHash, maxAttempts).
+		Scan(&c.ID, &c.UserID, &c.Attempts, &c.ExpiresAt, &c.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to use mfa challenge: %w", err)
+	}
+
+	return &c, nil
+}
+
+func (s *TwoFactorStorage) DeleteMFAChallenge(ctx context.Context, tokenHash string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE token_hash = $1`, tokenHash); err != nil {
+		return fmt.Errorf("failed to delete mfa challenge: %w", err)
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash)
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -2,14 +2,27 @@ package postgres
 
 import (
 	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+
+	"github.com/lib/pq"
 )
 
 type UserStorageI interface {
 	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	GetUserByExternalID(ctx context.Context, externalID string) (*models.User, error)
+	ListUsers(ctx context.Context) ([]*models.User, error)
 	CreateUser(ctx context.Context, user *models.User) error
+	UpdateUserRole(ctx context.Context, id int, role models.UserRole) error
+	SetUserDisabled(ctx context.Context, id int, disabled bool) error
+	UpdatePasswordHash(ctx context.Context, id int, passwordHash string) error
+	ChangePassword(ctx context.Context, id int, passwordHash string, historySize int) error
+	GetPasswordHistory(ctx context.Context, id int) ([]string, error)
+	DeleteUser(ctx context.Context, id int) error
 }
 
 type UserStorage struct {
@@ -21,14 +34,46 @@ func NewUserStorage(db *sql.DB) *UserStorage {
 }
 
 func (s *UserStorage) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
-	query := `SELECT id, username, password_hash, role FROM users WHERE username = $1`
+	query := `SELECT id, username, password_hash, role, disabled, auth_provider, external_id, created_at FROM users WHERE username = $1`
 	row := s.db.QueryRowContext(ctx, query, username)
 
 	var user models.User
-	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role)
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.Disabled, &user.AuthProvider, &user.ExternalID, &user.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (s *UserStorage) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	query := `SELECT id, username, password_hash, role, disabled, auth_provider, external_id, created_at FROM users WHERE id = $1`
+	row := s.db.QueryRowContext(ctx, query, id)
+
+	var user models.User
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.Disabled, &user.AuthProvider, &user.ExternalID, &user.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (s *UserStorage) GetUserByExternalID(ctx context.Context, externalID string) (*models.User, error) {
+	query := `SELECT id, username, password_hash, role, disabled, auth_provider, external_id, created_at FROM users WHERE external_id = $1`
+	row := s.db.QueryRowContext(ctx, query, externalID)
+
+	var user models.User
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.Disabled, &user.AuthProvider, &user.ExternalID, &user.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, storage.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -36,11 +81,223 @@ func (s *UserStorage) GetUserByUsername(ctx context.Context, username string) (*
 	return &user, nil
 }
 
+func (s *UserStorage) ListUsers(ctx context.Context) ([]*models.User, error) {
+	query := `SELECT id, username, password_hash, role, disabled, auth_provider, external_id, created_at FROM users ORDER BY id`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		err = rows.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.Disabled, &user.AuthProvider, &user.ExternalID, &user.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, &user)
+	}
+
+	return users, nil
+}
+
+// CreateUser создает пользователя. Имена с префиксом API ключей отклоняются
+// с storage.ErrUsernameReserved, чтобы changed_by не путал человека с интеграцией
 func (s *UserStorage) CreateUser(ctx context.Context, user *models.User) error {
-	query := `INSERT INTO users (username, password_hash, role) VALUES ($1, $2, $3) RETURNING id`
-	err := s.db.QueryRowContext(ctx, query, user.Username, user.PasswordHash, user.Role).Scan(&user.ID)
+	if models.IsAPIKeyUsername(user.Username) {
+		return storage.ErrUsernameReserved
+	}
+	if user.AuthProvider == "" {
+		user.AuthProvider = models.AuthProviderLocal
+	}
+
+	query := `INSERT INTO users (username, password_hash, role, disabled, auth_provider, external_id)
+	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	err := s.db.QueryRowContext(ctx, query,
+		user.Username, user.PasswordHash, user.Role, user.Disabled, user.AuthProvider, user.ExternalID,
+	).Scan(&user.ID, &user.CreatedAt)
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return storage.ErrUserExists
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 	return nil
 }
+
+func (s *UserStorage) UpdateUserRole(ctx context.Context, id int, role models.UserRole) error {
+	return s.withAdminGuard(ctx, id, func(tx *sql.Tx, user *models.User, activeAdmins int) error {
+		if user.Role == models.RoleAdmin && role != models.RoleAdmin && !user.Disabled && activeAdmins <= 1 {
+			return storage.ErrLastAdmin
+		}
+
+		_, err := tx.ExecContext(ctx, `UPDATE users SET role = $1 WHERE id = $2`, role, id)
+		if err != nil {
+			return fmt.Errorf("failed to update user role: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *UserStorage) SetUserDisabled(ctx context.Context, id int, disabled bool) error {
+	return s.withAdminGuard(ctx, id, func(tx *sql.Tx, user *models.User, activeAdmins int) error {
+		if disabled && user.Role == models.RoleAdmin && !user.Disabled && activeAdmins <= 1 {
+			return storage.ErrLastAdmin
+		}
+
+		_, err := tx.ExecContext(ctx, `UPDATE users SET disabled = $1 WHERE id = $2`, disabled, id)
+		if err != nil {
+			return fmt.Errorf("failed to update user status: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *UserStorage) UpdatePasswordHash(ctx context.Context, id int, passwordHash string) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET password_hash = $1 WHERE id = $2`, passwordHash, id)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return storage.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// ChangePassword устанавливает новый пароль, сохраняя текущий хеш в истории
+func (s *UserStorage) ChangePassword(ctx context.Context, id int, passwordHash string, historySize int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = setPasswordHash(ctx, tx, id, passwordHash, historySize); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPasswordHistory возвращает прежние хеши паролей пользователя, новые первыми
+func (s *UserStorage) GetPasswordHistory(ctx context.Context, id int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT password_hash FROM password_history WHERE user_id = $1 ORDER BY id DESC`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password history: %w", err)
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err = rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to scan password history: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, nil
+}
+
+// setPasswordHash переносит текущий хеш в password_history, устанавливает новый
+// и оставляет в истории не больше historySize записей
+func setPasswordHash(ctx context.Context, tx *sql.Tx, userID int, passwordHash string, historySize int) error {
+	if historySize > 0 {
+		_, err := tx.ExecContext(ctx, `INSERT INTO password_history (user_id, password_hash)
+		                               SELECT id, password_hash FROM users WHERE id = $1 AND password_hash <> ''`, userID)
+		if err != nil {
+			return fmt.Errorf("failed to save password history: %w", err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET password_hash = $1 WHERE id = $2`, passwordHash, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return storage.ErrUserNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM password_history WHERE user_id = $1 AND id NOT IN (
+	                                  SELECT id FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2)`,
+		userID, historySize)
+	if err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+
+	return nil
+}
+
+func (s *UserStorage) DeleteUser(ctx context.Context, id int) error {
+	return s.withAdminGuard(ctx, id, func(tx *sql.Tx, user *models.User, activeAdmins int) error {
+		if user.Role == models.RoleAdmin && !user.Disabled && activeAdmins <= 1 {
+			return storage.ErrLastAdmin
+		}
+
+		// Refresh токены удалятся каскадно, поэтому выданные с ними
+		// access токены отзываем в той же транзакции
+		if _, err := revokeUserSessions(ctx, tx, id); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		return nil
+	})
+}
+
+// withAdminGuard выполняет fn в транзакции, предварительно заблокировав строки
+// всех активных администраторов, чтобы параллельные запросы не могли
+// одновременно лишить систему последнего admin
+func (s *UserStorage) withAdminGuard(ctx context.Context, id int, fn func(tx *sql.Tx, user *models.User, activeAdmins int) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM users WHERE role = 'admin' AND NOT disabled FOR UPDATE`)
+	if err != nil {
+		return fmt.Errorf("failed to lock admins: %w", err)
+	}
+	activeAdmins := 0
+	for rows.Next() {
+		activeAdmins++
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to lock admins: %w", err)
+	}
+
+	var user models.User
+	err = tx.QueryRowContext(ctx, `SELECT id, username, role, disabled FROM users WHERE id = $1 FOR UPDATE`, id).
+		Scan(&user.ID, &user.Username, &user.Role, &user.Disabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ErrUserNotFound
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if err = fn(tx, &user, activeAdmins); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
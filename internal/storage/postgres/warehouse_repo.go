@@ -0,0 +1,298 @@
+package postgres
+
+import (
+	"WarehouseControl/internal/models"
+	"WarehouseControl/internal/storage"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+type WarehouseStorageI interface {
+	CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error
+	ListWarehouses(ctx context.Context) ([]*models.Warehouse, error)
+	GetWarehouseByID(ctx context.Context, id int) (*models.Warehouse, error)
+	UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error
+	DeleteWarehouse(ctx context.Context, id int) error
+
+	CreateLocation(ctx context.Context, location *models.Location) error
+	ListLocations(ctx context.Context, warehouseID int) ([]*models.Location, error)
+	GetLocationByID(ctx context.Context, id int) (*models.Location, error)
+	UpdateLocation(ctx context.Context, location *models.Location) error
+	DeleteLocation(ctx context.Context, id int) error
+	GetLocationStock(ctx context.Context, locationID int) ([]models.ItemStock, error)
+}
+
+type WarehouseStorage struct {
+	db *sql.DB
+}
+
+func NewWarehouseStorage(db *sql.DB) *WarehouseStorage {
+	return &WarehouseStorage{db: db}
+}
+
+func (s *WarehouseStorage) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	query := `INSERT INTO warehouses (code, name) VALUES ($1, $2) RETURNING id, created_at`
+	err := s.db.QueryRowContext(ctx, query, warehouse.Code, warehouse.Name).Scan(&warehouse.ID, &warehouse.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.ErrWarehouseExists
+		}
+		return fmt.Errorf("failed to create warehouse: %w", err)
+	}
+	return nil
+}
+
+func (s *WarehouseStorage) ListWarehouses(ctx context.Context) ([]*models.Warehouse, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, code, name, created_at FROM warehouses ORDER BY code`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get warehouses: %w", err)
+	}
+	defer rows.Close()
+
+	var warehouses []*models.Warehouse
+	for rows.Next() {
+		var w models.Warehouse
+		if err = rows.Scan(&w.ID, &w.Code, &w.Name, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan warehouse: %w", err)
+		}
+		warehouses = append(warehouses, &w)
+	}
+
+	return warehouses, rows.Err()
+}
+
+func (s *WarehouseStorage) GetWarehouseByID(ctx context.Context, id int) (*models.Warehouse, error) {
+	var w models.Warehouse
+	query := `SELECT id, code, name, created_at FROM warehouses WHERE id = $1`
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&w.ID, &w.Code, &w.Name, &w.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrWarehouseNotFound
+		}
+		return nil, fmt.Errorf("failed to get warehouse: %w", err)
+	}
+	return &w, nil
+}
+
+func (s *WarehouseStorage) UpdateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	query := `UPDATE warehouses SET code = $1, name = $2 WHERE id = $3 RETURNING created_at`
+	err := s.db.QueryRowContext(ctx, query, warehouse.Code, warehouse.Name, warehouse.ID).Scan(&warehouse.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ErrWarehouseNotFound
+		}
+		if isUniqueViolation(err) {
+			return storage.ErrWarehouseExists
+		}
+		return fmt.Errorf("failed to update warehouse: %w", err)
+	}
+	return nil
+}
+
+// DeleteWarehouse удаляет только пустой склад: места хранения нужно удалить раньше
+func (s *WarehouseStorage) DeleteWarehouse(ctx context.Context, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM warehouses WHERE id = $1`, id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return storage.ErrWarehouseInUse
+		}
+		return fmt.Errorf("failed to delete warehouse: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return storage.ErrWarehouseNotFound
+	}
+	return nil
+}
+
+const locationColumns = `id, warehouse_id, parent_id, kind, code, name, is_default, created_at`
+
+// CreateLocation создает место хранения. Родитель должен быть на уровень выше
+// и принадлежать тому же складу, зона создается без родителя
+func (s *WarehouseStorage) CreateLocation(ctx context.Context, location *models.Location) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = checkLocationParent(ctx, tx, location); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO locations (warehouse_id, parent_id, kind, code, name)
+	          VALUES ($1, $2, $3, $4, $5) RETURNING id, is_default, created_at`
+	err = tx.QueryRowContext(ctx, query, location.WarehouseID, location.ParentID, location.Kind, location.Code, location.Name).
+		Scan(&location.ID, &location.IsDefault, &location.CreatedAt)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err):
+			return storage.ErrLocationExists
+		case isForeignKeyViolation(err):
+			return storage.ErrWarehouseNotFound
+		}
+		return fmt.Errorf("failed to create location: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *WarehouseStorage) ListLocations(ctx context.Context, warehouseID int) ([]*models.Location, error) {
+	query := `SELECT ` + locationColumns + ` FROM locations WHERE warehouse_id = $1 ORDER BY code`
+	rows, err := s.db.QueryContext(ctx, query, warehouseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locations: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []*models.Location
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan location: %w", err)
+		}
+		locations = append(locations, location)
+	}
+
+	return locations, rows.Err()
+}
+
+func (s *WarehouseStorage) GetLocationByID(ctx context.Context, id int) (*models.Location, error) {
+	query := `SELECT ` + locationColumns + ` FROM locations WHERE id = $1`
+	location, err := scanLocation(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, storage.ErrLocationNotFound
+		}
+		return nil, fmt.Errorf("failed to get location: %w", err)
+	}
+	return location, nil
+}
+
+// UpdateLocation меняет код и название места. Склад, уровень и родитель не меняются
+func (s *WarehouseStorage) UpdateLocation(ctx context.Context, location *models.Location) error {
+	query := `UPDATE locations SET code = $1, name = $2 WHERE id = $3 RETURNING ` + locationColumns
+	updated, err := scanLocation(s.db.QueryRowContext(ctx, query, location.Code, location.Name, location.ID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ErrLocationNotFound
+		}
+		if isUniqueViolation(err) {
+			return storage.ErrLocationExists
+		}
+		return fmt.Errorf("failed to update location: %w", err)
+	}
+	*location = *updated
+	return nil
+}
+
+// DeleteLocation удаляет место без вложенных мест и остатков.
+// Место по умолчанию удалить нельзя
+func (s *WarehouseStorage) DeleteLocation(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var isDefault bool
+	err = tx.QueryRowContext(ctx, `SELECT is_default FROM locations WHERE id = $1 FOR UPDATE`, id).Scan(&isDefault)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ErrLocationNotFound
+		}
+		return fmt.Errorf("failed to get location: %w", err)
+	}
+	if isDefault {
+		return storage.ErrLocationInUse
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM locations WHERE id = $1`, id); err != nil {
+		if isForeignKeyViolation(err) {
+			return storage.ErrLocationInUse
+		}
+		return fmt.Errorf("failed to delete location: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetLocationStock возвращает остатки всех товаров в месте хранения
+func (s *WarehouseStorage) GetLocationStock(ctx context.Context, locationID int) ([]models.ItemStock, error) {
+	if _, err := s.GetLocationByID(ctx, locationID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, stockQuery+` WHERE s.location_id = $1 ORDER BY s.item_id`, locationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location stock: %w", err)
+	}
+	defer rows.Close()
+
+	stock := []models.ItemStock{}
+	for rows.Next() {
+		st, err := scanStock(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock: %w", err)
+		}
+		stock = append(stock, st)
+	}
+
+	return stock, rows.Err()
+}
+
+func checkLocationParent(ctx context.Context, tx *sql.Tx, location *models.Location) error {
+	parentKind, needsParent := location.Kind.Parent()
+	if !needsParent {
+		if location.ParentID != nil {
+			return storage.ErrInvalidParent
+		}
+		return nil
+	}
+	if location.ParentID == nil {
+		return storage.ErrInvalidParent
+	}
+
+	var warehouseID int
+	var kind models.LocationKind
+	query := `SELECT warehouse_id, kind FROM locations WHERE id = $1`
+	err := tx.QueryRowContext(ctx, query, *location.ParentID).Scan(&warehouseID, &kind)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return storage.ErrInvalidParent
+		}
+		return fmt.Errorf("failed to get parent location: %w", err)
+	}
+
+	if warehouseID != location.WarehouseID || kind != parentKind {
+		return storage.ErrInvalidParent
+	}
+	return nil
+}
+
+func scanLocation(row rowScanner) (*models.Location, error) {
+	var l models.Location
+	err := row.Scan(&l.ID, &l.WarehouseID, &l.ParentID, &l.Kind, &l.Code, &l.Name, &l.IsDefault, &l.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
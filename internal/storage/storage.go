@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrUserExists       = errors.New("user already exists")
+	ErrUsernameReserved = errors.New("usernames starting with apikey: are reserved for api keys")
+	ErrLastAdmin        = errors.New("cannot demote, disable or delete the last active admin")
+
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenExpired  = errors.New("token expired")
+	ErrTokenReused   = errors.New("refresh token reuse detected")
+	ErrTokenUsed     = errors.New("token already used")
+
+	ErrItemNotFound    = errors.New("item not found")
+	ErrSKUExists       = errors.New("item with this sku already exists")
+	ErrBarcodeExists   = errors.New("barcode is already assigned to another item")
+	ErrVersionConflict = errors.New("item was modified by another request")
+	ErrVersionRequired = errors.New("quantity can be changed only with If-Match set to the item version")
+	ErrInvalidCursor   = errors.New("invalid or outdated cursor")
+	ErrInvalidSort     = errors.New("invalid sort field")
+	ErrItemNotDeleted  = errors.New("item must be moved to trash before purge")
+	ErrItemInTrash     = errors.New("item with this sku is in trash")
+	ErrAmbiguousName   = errors.New("several items have this name")
+
+	ErrWarehouseNotFound = errors.New("warehouse not found")
+	ErrWarehouseExists   = errors.New("warehouse with this code already exists")
+	ErrWarehouseInUse    = errors.New("warehouse has locations")
+	ErrLocationNotFound  = errors.New("location not found")
+	ErrLocationExists    = errors.New("location with this code already exists in the warehouse")
+	ErrLocationInUse     = errors.New("location is default, has child locations or holds stock")
+	ErrInvalidParent     = errors.New("parent location must be one level up in the same warehouse")
+	ErrInsufficientStock = errors.New("insufficient stock in location")
+	ErrNoDefaultLocation = errors.New("default location is not configured")
+
+	ErrUnknownUnit       = errors.New("unit is not defined for the item")
+	ErrQuantityPrecision = errors.New("quantity does not fit the precision of the item unit")
+	ErrUnitInUse         = errors.New("base unit cannot be changed and its precision cannot be reduced while the item has stock")
+
+	ErrCategoryNotFound      = errors.New("category not found")
+	ErrCategoryExists        = errors.New("category with this name already exists in the parent category")
+	ErrCategoryInUse         = errors.New("category has subcategories or items")
+	ErrInvalidCategoryParent = errors.New("parent category not found")
+	ErrCategoryCycle         = errors.New("category cannot be moved into itself or its subcategory")
+
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyExists   = errors.New("api key already exists")
+
+	ErrTOTPNotFound        = errors.New("totp is not configured")
+	ErrTOTPAlreadyEnabled  = errors.New("totp is already enabled")
+	ErrTOTPCodeUsed        = errors.New("totp code already used")
+	ErrRecoveryCodeInvalid = errors.New("recovery code is invalid or used")
+)
+
+// VersionConflictError - товар изменен после того, как клиент прочитал версию Expected.
+// errors.Is(err, ErrVersionConflict) для него истинно
+type VersionConflictError struct {
+	Expected int
+	Actual   int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: expected version %d, current version %d", ErrVersionConflict, e.Expected, e.Actual)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN disabled   BOOLEAN   NOT NULL DEFAULT FALSE,
+    ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW();
\ No newline at end of file
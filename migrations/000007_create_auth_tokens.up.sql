@@ -0,0 +1,22 @@
+-- Сроки действия записываются приложением (совпадают с exp в JWT) и сравниваются с NOW(),
+-- поэтому хранятся как TIMESTAMPTZ: в TIMESTAMP смещение пояса приложения теряется
+CREATE TABLE refresh_tokens
+(
+    id                SERIAL PRIMARY KEY,
+    user_id           INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash        TEXT UNIQUE NOT NULL, -- sha256 от refresh токена, сам токен не храним
+    access_jti        VARCHAR(64) NOT NULL, -- jti access токена, выданного вместе с refresh
+    access_expires_at TIMESTAMPTZ NOT NULL,
+    expires_at        TIMESTAMPTZ NOT NULL,
+    created_at        TIMESTAMP   NOT NULL DEFAULT NOW(),
+    revoked_at        TIMESTAMP
+);
+
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+
+CREATE TABLE revoked_tokens
+(
+    jti        VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMP   NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
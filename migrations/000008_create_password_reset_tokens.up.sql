@@ -0,0 +1,13 @@
+-- expires_at задает приложение, поэтому TIMESTAMPTZ: в TIMESTAMP смещение пояса теряется
+CREATE TABLE password_reset_tokens
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT UNIQUE NOT NULL, -- sha256 от одноразового токена
+    created_by VARCHAR(50) NOT NULL, -- admin, выпустивший токен
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP   NOT NULL DEFAULT NOW(),
+    used_at    TIMESTAMP
+);
+
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- Моменты считаются от NOW() базы и читаются приложением, поэтому TIMESTAMPTZ
+CREATE TABLE login_attempts
+(
+    scope           VARCHAR(20)  NOT NULL, -- 'username' или 'ip'
+    key             VARCHAR(255) NOT NULL,
+    failures        INTEGER      NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    blocked_until   TIMESTAMPTZ,
+    PRIMARY KEY (scope, key)
+);
\ No newline at end of file
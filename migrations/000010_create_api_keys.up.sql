@@ -0,0 +1,13 @@
+CREATE TABLE api_keys
+(
+    id           SERIAL PRIMARY KEY,
+    name         VARCHAR(43) UNIQUE NOT NULL, -- как apikey:<name> попадает в app.username и item_history.changed_by
+    key_prefix   VARCHAR(16)        NOT NULL, -- открытая часть ключа для распознавания в списке
+    key_hash     TEXT UNIQUE        NOT NULL, -- sha256 от ключа, сам ключ не храним
+    role         VARCHAR(20)        NOT NULL CHECK (role IN ('admin', 'manager', 'viewer')),
+    created_by   VARCHAR(50)        NOT NULL,
+    expires_at   TIMESTAMPTZ,                 -- задается клиентом и сравнивается со временем приложения
+    created_at   TIMESTAMP          NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP,
+    revoked_at   TIMESTAMP
+);
\ No newline at end of file
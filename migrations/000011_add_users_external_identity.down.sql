@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS external_id,
+    DROP COLUMN IF EXISTS auth_provider;
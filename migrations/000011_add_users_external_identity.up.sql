@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN auth_provider VARCHAR(20)  NOT NULL DEFAULT 'local' CHECK (auth_provider IN ('local', 'oidc')),
+    ADD COLUMN external_id   VARCHAR(255) UNIQUE;
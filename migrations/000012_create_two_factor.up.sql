@@ -0,0 +1,33 @@
+CREATE TABLE user_totp
+(
+    user_id        INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret         TEXT      NOT NULL,          -- base32 секрет RFC 6238
+    enabled        BOOLEAN   NOT NULL DEFAULT FALSE,
+    last_used_step BIGINT,                      -- шаг последнего принятого кода, защита от повтора
+    confirmed_at   TIMESTAMP,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE recovery_codes
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  TEXT      NOT NULL, -- sha256 от одноразового кода восстановления
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    used_at    TIMESTAMP,
+    UNIQUE (user_id, code_hash)
+);
+
+-- Выдаются после проверки пароля или входа через SSO и обмениваются на сессию после проверки
+-- второго фактора. expires_at задает приложение, поэтому TIMESTAMPTZ, как у токенов
+CREATE TABLE mfa_challenges
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT UNIQUE NOT NULL, -- sha256 от pre-auth токена
+    attempts   INTEGER     NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_mfa_challenges_expires_at ON mfa_challenges (expires_at);
@@ -0,0 +1,19 @@
+-- Журнал событий аутентификации. Пользователь может быть удален,
+-- а записи о его входах должны остаться, поэтому имя хранится отдельно
+CREATE TABLE auth_events
+(
+    id         BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(30)  NOT NULL,
+    outcome    VARCHAR(20)  NOT NULL,
+    username   VARCHAR(255) NOT NULL DEFAULT '', -- введенное имя, может не существовать
+    user_id    INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    ip         VARCHAR(64)  NOT NULL DEFAULT '',
+    user_agent TEXT         NOT NULL DEFAULT '',
+    request_id VARCHAR(100) NOT NULL DEFAULT '',
+    reason     TEXT         NOT NULL DEFAULT '',
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_auth_events_created_at ON auth_events (created_at);
+CREATE INDEX idx_auth_events_username ON auth_events (username, created_at);
+CREATE INDEX idx_auth_events_ip ON auth_events (ip, created_at);
@@ -0,0 +1,11 @@
+-- Прежние хеши паролей для запрета повторного использования.
+-- Хранятся только последние auth.password.history_size записей
+CREATE TABLE password_history
+(
+    id            SERIAL PRIMARY KEY,
+    user_id       INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    password_hash TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_password_history_user_id ON password_history (user_id, id);
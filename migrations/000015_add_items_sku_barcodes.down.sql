@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS items_barcodes_trigger ON items;
+DROP FUNCTION IF EXISTS sync_item_barcodes();
+DROP TABLE IF EXISTS item_barcodes;
+ALTER TABLE items DROP COLUMN IF EXISTS barcodes;
+ALTER TABLE items DROP COLUMN IF EXISTS sku;
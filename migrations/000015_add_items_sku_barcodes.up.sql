@@ -0,0 +1,39 @@
+-- Штрихкоды хранятся в самом товаре, поэтому попадают в снимки to_jsonb
+-- в item_history. Уникальность и поиск обеспечивает item_barcodes
+ALTER TABLE items ADD COLUMN barcodes TEXT[] NOT NULL DEFAULT '{}';
+
+-- Артикул обязателен и уникален. Существующим товарам назначается ITEM-<id>,
+-- назначение попадает в item_history от имени migration
+ALTER TABLE items ADD COLUMN sku VARCHAR(64);
+SELECT set_config('app.username', 'migration', true);
+UPDATE items SET sku = 'ITEM-' || LPAD(id::TEXT, 6, '0');
+ALTER TABLE items ALTER COLUMN sku SET NOT NULL;
+ALTER TABLE items ADD CONSTRAINT items_sku_key UNIQUE (sku);
+
+CREATE TABLE item_barcodes
+(
+    barcode VARCHAR(14) PRIMARY KEY,
+    item_id INTEGER NOT NULL REFERENCES items (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_item_barcodes_item_id ON item_barcodes (item_id);
+
+-- sync_item_barcodes приводит item_barcodes в соответствие с items.barcodes.
+-- Штрихкод другого товара нарушит первичный ключ item_barcodes_pkey
+CREATE OR REPLACE FUNCTION sync_item_barcodes() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM item_barcodes WHERE item_id = NEW.id AND barcode <> ALL (NEW.barcodes);
+
+    INSERT INTO item_barcodes (barcode, item_id)
+    SELECT DISTINCT b, NEW.id
+    FROM unnest(NEW.barcodes) AS b
+    WHERE NOT EXISTS (SELECT 1 FROM item_barcodes WHERE barcode = b AND item_id = NEW.id);
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER items_barcodes_trigger
+    AFTER INSERT OR UPDATE OF barcodes ON items
+    FOR EACH ROW
+EXECUTE FUNCTION sync_item_barcodes();
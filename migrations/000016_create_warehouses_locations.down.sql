@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS item_stock_change_trigger ON item_stock;
+DROP FUNCTION IF EXISTS log_item_stock_change();
+DELETE FROM item_history WHERE action = 'stock';
+ALTER TABLE item_history DROP COLUMN IF EXISTS location_id;
+DROP TABLE IF EXISTS item_stock;
+DROP TABLE IF EXISTS locations;
+DROP TABLE IF EXISTS warehouses;
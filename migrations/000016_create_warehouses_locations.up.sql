@@ -0,0 +1,105 @@
+CREATE TABLE warehouses
+(
+    id         SERIAL PRIMARY KEY,
+    code       VARCHAR(32)  NOT NULL CONSTRAINT warehouses_code_key UNIQUE,
+    name       VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+-- Иерархия мест хранения: zone -> aisle -> rack -> bin. Код уникален в пределах склада.
+-- Место по умолчанию одно на всю систему, туда попадает остаток без явного места
+CREATE TABLE locations
+(
+    id           SERIAL PRIMARY KEY,
+    warehouse_id INTEGER      NOT NULL REFERENCES warehouses (id),
+    parent_id    INTEGER REFERENCES locations (id),
+    kind         VARCHAR(10)  NOT NULL CHECK (kind IN ('zone', 'aisle', 'rack', 'bin')),
+    code         VARCHAR(64)  NOT NULL,
+    name         VARCHAR(100) NOT NULL DEFAULT '',
+    is_default   BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at   TIMESTAMP    NOT NULL DEFAULT NOW(),
+    CONSTRAINT locations_warehouse_code_key UNIQUE (warehouse_id, code)
+);
+
+CREATE INDEX idx_locations_parent_id ON locations (parent_id);
+CREATE UNIQUE INDEX locations_default_key ON locations (is_default) WHERE is_default;
+
+-- Остаток товара по местам. Нулевые строки не хранятся: ItemStorage удаляет их сразу
+CREATE TABLE item_stock
+(
+    item_id     INTEGER   NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+    location_id INTEGER   NOT NULL REFERENCES locations (id),
+    quantity    INTEGER   NOT NULL CONSTRAINT item_stock_quantity_check CHECK (quantity >= 0),
+    updated_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (item_id, location_id)
+);
+
+CREATE INDEX idx_item_stock_location_id ON item_stock (location_id);
+
+-- Записи об остатках по местам пишутся в общую историю товара с action = 'stock'
+ALTER TABLE item_history ADD COLUMN location_id INTEGER REFERENCES locations (id) ON DELETE SET NULL;
+
+-- log_item_stock_change пишет изменение остатка в item_history и пересчитывает
+-- items.quantity как сумму по местам. Пересчет вызывает log_item_change, поэтому
+-- общий остаток товара тоже остается в истории
+CREATE OR REPLACE FUNCTION log_item_stock_change() RETURNS TRIGGER AS $$
+DECLARE
+    target_item_id INTEGER;
+BEGIN
+    IF (TG_OP = 'DELETE') THEN
+        target_item_id := OLD.item_id;
+    ELSE
+        target_item_id := NEW.item_id;
+    END IF;
+
+    -- Каскадное удаление вместе с товаром: товара уже нет, писать некуда
+    IF NOT EXISTS (SELECT 1 FROM items WHERE id = target_item_id) THEN
+        RETURN NULL;
+    END IF;
+
+    IF (TG_OP = 'INSERT') THEN
+        INSERT INTO item_history (item_id, action, changed_by, new_values, location_id)
+        VALUES (NEW.item_id, 'stock', current_setting('app.username', true), to_jsonb(NEW), NEW.location_id);
+    ELSIF (TG_OP = 'UPDATE') THEN
+        IF OLD.quantity = NEW.quantity THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO item_history (item_id, action, changed_by, old_values, new_values, location_id)
+        VALUES (NEW.item_id, 'stock', current_setting('app.username', true), to_jsonb(OLD), to_jsonb(NEW), NEW.location_id);
+    ELSE
+        -- Пустая строка удаляется сразу после списания до нуля, списание уже записано
+        IF OLD.quantity = 0 THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO item_history (item_id, action, changed_by, old_values, location_id)
+        VALUES (OLD.item_id, 'stock', current_setting('app.username', true), to_jsonb(OLD), OLD.location_id);
+    END IF;
+
+    UPDATE items
+    SET quantity   = totals.quantity,
+        updated_at = NOW()
+    FROM (SELECT COALESCE(SUM(quantity), 0) AS quantity FROM item_stock WHERE item_id = target_item_id) AS totals
+    WHERE items.id = target_item_id
+      AND items.quantity <> totals.quantity;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER item_stock_change_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON item_stock
+    FOR EACH ROW
+EXECUTE FUNCTION log_item_stock_change();
+
+-- Текущие остатки переносятся на место по умолчанию основного склада
+INSERT INTO warehouses (code, name) VALUES ('MAIN', 'Основной склад');
+
+INSERT INTO locations (warehouse_id, kind, code, name, is_default)
+SELECT id, 'zone', 'RECEIVING', 'Зона приемки', TRUE FROM warehouses WHERE code = 'MAIN';
+
+SELECT set_config('app.username', 'migration', true);
+
+INSERT INTO item_stock (item_id, location_id, quantity)
+SELECT items.id, locations.id, items.quantity
+FROM items, locations
+WHERE locations.is_default AND items.quantity > 0;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS stock_movements_protect_trigger ON stock_movements;
+DROP FUNCTION IF EXISTS protect_stock_movements();
+DROP TABLE IF EXISTS stock_movements;
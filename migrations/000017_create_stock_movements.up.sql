@@ -0,0 +1,40 @@
+-- Журнал движений остатков. Каждое изменение item_stock записывается сюда
+-- в той же транзакции, строки журнала не изменяются и не удаляются
+CREATE TABLE stock_movements
+(
+    id            BIGSERIAL PRIMARY KEY,
+    item_id       INTEGER     NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+    location_id   INTEGER     NOT NULL REFERENCES locations (id),
+    movement_type VARCHAR(10) NOT NULL CHECK (movement_type IN ('receive', 'issue', 'adjust', 'transfer')),
+    delta         INTEGER     NOT NULL CHECK (delta <> 0), -- со знаком: приход > 0, расход < 0
+    reason        VARCHAR(32) NOT NULL,
+    note          TEXT        NOT NULL DEFAULT '',
+    transfer_id   UUID,                                    -- общий у двух строк перемещения
+    created_by    VARCHAR(50) NOT NULL,
+    created_at    TIMESTAMP   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_stock_movements_item_id ON stock_movements (item_id, id);
+CREATE INDEX idx_stock_movements_location_id ON stock_movements (location_id);
+CREATE INDEX idx_stock_movements_transfer_id ON stock_movements (transfer_id) WHERE transfer_id IS NOT NULL;
+
+-- Журнал только дополняется. Удаление разрешено лишь каскадом вместе с товаром
+CREATE OR REPLACE FUNCTION protect_stock_movements() RETURNS TRIGGER AS $$
+BEGIN
+    IF (TG_OP = 'DELETE' AND pg_trigger_depth() > 1) THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_protect_trigger
+    BEFORE UPDATE OR DELETE ON stock_movements
+    FOR EACH ROW
+EXECUTE FUNCTION protect_stock_movements();
+
+-- Текущие остатки становятся начальными записями журнала
+INSERT INTO stock_movements (item_id, location_id, movement_type, delta, reason, created_by)
+SELECT item_id, location_id, 'adjust', quantity, 'opening_balance', 'migration'
+FROM item_stock
+ORDER BY item_id, location_id;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS items_version_trigger ON items;
+DROP FUNCTION IF EXISTS bump_item_version();
+ALTER TABLE items DROP COLUMN IF EXISTS version;
@@ -0,0 +1,15 @@
+-- Версия товара для оптимистичной блокировки (ETag / If-Match).
+-- Увеличивается при любом изменении строки, в том числе при пересчете остатка
+ALTER TABLE items ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_item_version() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER items_version_trigger
+    BEFORE UPDATE ON items
+    FOR EACH ROW
+EXECUTE FUNCTION bump_item_version();
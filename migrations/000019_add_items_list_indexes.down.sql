@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_items_updated_at_id;
+DROP INDEX IF EXISTS idx_items_created_at_id;
+DROP INDEX IF EXISTS idx_items_quantity_id;
+DROP INDEX IF EXISTS idx_items_sku_id;
+DROP INDEX IF EXISTS idx_items_name_id;
@@ -0,0 +1,6 @@
+-- Индексы под постраничный список товаров: ключ страницы (поле сортировки, id)
+CREATE INDEX idx_items_name_id ON items (name, id);
+CREATE INDEX idx_items_sku_id ON items (sku, id);
+CREATE INDEX idx_items_quantity_id ON items (quantity, id);
+CREATE INDEX idx_items_created_at_id ON items (created_at, id);
+CREATE INDEX idx_items_updated_at_id ON items (updated_at, id);
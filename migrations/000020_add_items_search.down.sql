@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_items_sku_trgm;
+DROP INDEX IF EXISTS idx_items_name_trgm;
+DROP INDEX IF EXISTS idx_items_name_fts;
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,8 @@
+-- Поиск товаров: полнотекстовый по названию (конфигурация russian, латиница
+-- обрабатывается английским стеммером) и нечеткий по триграммам для опечаток
+-- и частей слов. Выражения индексов должны совпадать с запросом SearchItems
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_items_name_fts ON items USING GIN (to_tsvector('russian', name));
+CREATE INDEX idx_items_name_trgm ON items USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_items_sku_trgm ON items USING GIN (sku gin_trgm_ops);
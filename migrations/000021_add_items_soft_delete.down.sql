@@ -0,0 +1,35 @@
+CREATE OR REPLACE FUNCTION log_item_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF (TG_OP = 'INSERT') THEN
+        INSERT INTO item_history (item_id, action, changed_by, new_values)
+        VALUES (NEW.id, 'create', current_setting('app.username', true), to_jsonb(NEW));
+        RETURN NEW;
+    ELSIF (TG_OP = 'UPDATE') THEN
+        INSERT INTO item_history (item_id, action, changed_by, old_values, new_values)
+        VALUES (NEW.id, 'update', current_setting('app.username', true), to_jsonb(OLD), to_jsonb(NEW));
+        RETURN NEW;
+    ELSIF (TG_OP = 'DELETE') THEN
+        INSERT INTO item_history (item_id, action, changed_by, old_values)
+        VALUES (OLD.id, 'delete', current_setting('app.username', true), to_jsonb(OLD));
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Без корзины товары из нее удаляются, журналы удаленных товаров - вместе с ними
+SELECT set_config('app.username', 'migration', true);
+DELETE FROM items WHERE deleted_at IS NOT NULL;
+
+DELETE FROM item_history WHERE item_id NOT IN (SELECT id FROM items);
+ALTER TABLE stock_movements DISABLE TRIGGER stock_movements_protect_trigger;
+DELETE FROM stock_movements WHERE item_id NOT IN (SELECT id FROM items);
+ALTER TABLE stock_movements ENABLE TRIGGER stock_movements_protect_trigger;
+
+ALTER TABLE stock_movements
+    ADD CONSTRAINT stock_movements_item_id_fkey FOREIGN KEY (item_id) REFERENCES items (id) ON DELETE CASCADE;
+ALTER TABLE item_history
+    ADD CONSTRAINT item_history_item_id_fkey FOREIGN KEY (item_id) REFERENCES items (id) ON DELETE CASCADE;
+
+DROP INDEX IF EXISTS idx_items_deleted_at;
+ALTER TABLE items DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,39 @@
+-- Мягкое удаление: товар получает deleted_at и попадает в корзину, откуда его можно
+-- восстановить. Окончательное удаление (purge) убирает строку товара, но не журналы:
+-- item_history и stock_movements больше не ссылаются на items внешним ключом
+ALTER TABLE items ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_items_deleted_at ON items (deleted_at) WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE item_history DROP CONSTRAINT item_history_item_id_fkey;
+ALTER TABLE stock_movements DROP CONSTRAINT stock_movements_item_id_fkey;
+
+-- Перенос в корзину и восстановление пишутся как delete и restore со снимками до и после,
+-- окончательное удаление - как purge
+CREATE OR REPLACE FUNCTION log_item_change() RETURNS TRIGGER AS $$
+DECLARE
+    change_action VARCHAR(20);
+BEGIN
+    IF (TG_OP = 'INSERT') THEN
+        INSERT INTO item_history (item_id, action, changed_by, new_values)
+        VALUES (NEW.id, 'create', current_setting('app.username', true), to_jsonb(NEW));
+        RETURN NEW;
+    ELSIF (TG_OP = 'UPDATE') THEN
+        IF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+            change_action := 'delete';
+        ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+            change_action := 'restore';
+        ELSE
+            change_action := 'update';
+        END IF;
+        INSERT INTO item_history (item_id, action, changed_by, old_values, new_values)
+        VALUES (NEW.id, change_action, current_setting('app.username', true), to_jsonb(OLD), to_jsonb(NEW));
+        RETURN NEW;
+    ELSIF (TG_OP = 'DELETE') THEN
+        INSERT INTO item_history (item_id, action, changed_by, old_values)
+        VALUES (OLD.id, 'purge', current_setting('app.username', true), to_jsonb(OLD));
+        RETURN OLD;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,3 @@
+ALTER TABLE items DROP COLUMN IF EXISTS tags;
+ALTER TABLE items DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,23 @@
+-- Дерево категорий произвольной глубины. Перенос меняет только parent_id, поэтому
+-- путь категории и подкатегории вычисляются рекурсивными запросами. Циклы не допускает
+-- CategoryStorage.MoveCategory. Название уникально среди соседей без учета регистра
+CREATE TABLE categories
+(
+    id         SERIAL PRIMARY KEY,
+    parent_id  INTEGER REFERENCES categories (id),
+    name       VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    CONSTRAINT categories_parent_check CHECK (parent_id <> id)
+);
+
+CREATE UNIQUE INDEX categories_parent_name_key ON categories (COALESCE(parent_id, 0), lower(name));
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
+
+-- Категория и теги хранятся в самом товаре, как штрихкоды, поэтому их изменения
+-- попадают в снимки to_jsonb в item_history. Категорию с товарами удалить нельзя
+ALTER TABLE items ADD COLUMN category_id INTEGER CONSTRAINT items_category_id_fkey REFERENCES categories (id);
+ALTER TABLE items ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_items_category_id ON items (category_id);
+CREATE INDEX idx_items_tags ON items USING GIN (tags);
@@ -0,0 +1,22 @@
+-- Целые количества не могут хранить дробные остатки и движения, такие данные
+-- нужно исправить до отката
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM item_stock WHERE quantity <> trunc(quantity))
+        OR EXISTS (SELECT 1 FROM stock_movements WHERE delta <> trunc(delta)) THEN
+        RAISE EXCEPTION 'fractional quantities exist, cannot revert to integer quantities';
+    END IF;
+END;
+$$;
+
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS entered_unit;
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS entered_quantity;
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS unit;
+
+ALTER TABLE items DROP COLUMN IF EXISTS units;
+ALTER TABLE items DROP COLUMN IF EXISTS unit_precision;
+ALTER TABLE items DROP COLUMN IF EXISTS unit;
+
+ALTER TABLE stock_movements ALTER COLUMN delta TYPE INTEGER;
+ALTER TABLE item_stock ALTER COLUMN quantity TYPE INTEGER;
+ALTER TABLE items ALTER COLUMN quantity TYPE INTEGER;
@@ -0,0 +1,19 @@
+-- Количества становятся десятичными с точностью до тысячных. Сколько знаков
+-- допускает конкретный товар, задает unit_precision его базовой единицы
+ALTER TABLE items ALTER COLUMN quantity TYPE NUMERIC(18, 3);
+ALTER TABLE item_stock ALTER COLUMN quantity TYPE NUMERIC(18, 3);
+ALTER TABLE stock_movements ALTER COLUMN delta TYPE NUMERIC(18, 3);
+
+-- Базовая единица и дополнительные единицы хранятся в самом товаре, поэтому их
+-- изменения попадают в снимки item_history. units - массив {"unit": "case", "factor": 24}:
+-- factor базовых единиц в одной дополнительной
+ALTER TABLE items ADD COLUMN unit VARCHAR(16) NOT NULL DEFAULT 'pcs';
+ALTER TABLE items ADD COLUMN unit_precision SMALLINT NOT NULL DEFAULT 0
+    CONSTRAINT items_unit_precision_check CHECK (unit_precision BETWEEN 0 AND 3);
+ALTER TABLE items ADD COLUMN units JSONB NOT NULL DEFAULT '[]';
+
+-- Движение хранит delta в базовой единице на момент движения и количество в единице,
+-- в которой его ввел пользователь. Существующие движения были в штуках
+ALTER TABLE stock_movements ADD COLUMN unit VARCHAR(16) NOT NULL DEFAULT 'pcs';
+ALTER TABLE stock_movements ADD COLUMN entered_quantity NUMERIC(18, 3);
+ALTER TABLE stock_movements ADD COLUMN entered_unit VARCHAR(16);
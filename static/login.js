@@ -1,6 +1,50 @@
 document.addEventListener('DOMContentLoaded', function() {
     const loginForm = document.getElementById('loginForm');
     const errorMessage = document.getElementById('error-message');
+    const ssoButton = document.getElementById('ssoButton');
+
+    const ssoErrors = {
+        sso_denied: 'Вход через SSO отклонен',
+        sso_no_role: 'Для вашей учетной записи не назначена роль',
+        sso_account_conflict: 'Имя пользователя занято локальной учетной записью',
+        sso_session_expired: 'Сеанс входа истек, попробуйте снова',
+        sso_failed: 'Ошибка входа через SSO'
+    };
+
+    // Возврат после входа через OIDC: токены (в режиме cookie только роль),
+    // pre-auth токен второго шага или ошибка во фрагменте URL
+    if (window.location.hash.length > 1) {
+        const params = new URLSearchParams(window.location.hash.substring(1));
+        history.replaceState(null, '', window.location.pathname);
+
+        if (params.get('mfa_token')) {
+            startSecondFactor({
+                mfa_token: params.get('mfa_token'),
+                enrollment_required: params.get('enrollment_required') === 'true'
+            });
+            return;
+        }
+        if (params.get('role')) {
+            saveSession(params.get('token'), params.get('refresh_token'), params.get('role'));
+            return;
+        }
+        if (params.get('error')) {
+            errorMessage.textContent = ssoErrors[params.get('error')] || ssoErrors.sso_failed;
+        }
+    }
+
+    fetch('/auth/providers')
+        .then(response => response.json())
+        .then(data => {
+            if (data.status === 'OK' && data.data.oidc) {
+                ssoButton.style.display = '';
+            }
+        })
+        .catch(() => {});
+
+    ssoButton.addEventListener('click', function() {
+        window.location.href = '/auth/oidc/login';
+    });
 
     loginForm.addEventListener('submit', async function(e) {
         e.preventDefault();
@@ -19,10 +63,10 @@ document.addEventListener('DOMContentLoaded', function() {
 
             const data = await response.json();
 
-            if (data.status === 'OK') {
-                localStorage.setItem('token', data.data.token);
-                localStorage.setItem('role', document.getElementById('role').value);
-                window.location.href = '/';
+            if (data.status === 'OK' && data.data.mfa_required) {
+                startSecondFactor(data.data);
+            } else if (data.status === 'OK') {
+                saveSession(data.data.token, data.data.refresh_token, data.data.role);
             } else {
                 errorMessage.textContent = data.error || 'Ошибка авторизации';
             }
@@ -30,4 +74,84 @@ document.addEventListener('DOMContentLoaded', function() {
             errorMessage.textContent = 'Ошибка подключения к серверу';
         }
     });
-});
\ No newline at end of file
+});
+
+// Второй шаг входа: TOTP код или код восстановления по pre-auth токену
+async function startSecondFactor(challenge) {
+    const errorMessage = document.getElementById('error-message');
+    const mfaForm = document.getElementById('mfaForm');
+
+    document.getElementById('loginForm').style.display = 'none';
+    document.getElementById('ssoButton').style.display = 'none';
+    mfaForm.style.display = '';
+    errorMessage.textContent = '';
+
+    if (challenge.enrollment_required) {
+        const response = await fetch('/auth/2fa/enroll', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ mfa_token: challenge.mfa_token })
+        });
+        const data = await response.json();
+        if (data.status !== 'OK') {
+            errorMessage.textContent = data.error || 'Ошибка настройки двухфакторной аутентификации';
+            return;
+        }
+        document.getElementById('mfaQRCode').src = data.data.qr_code;
+        document.getElementById('mfaSecret').textContent = data.data.secret;
+        document.getElementById('mfaEnrollment').style.display = '';
+    }
+
+    mfaForm.addEventListener('submit', async function(e) {
+        e.preventDefault();
+
+        const value = document.getElementById('mfaCode').value.trim();
+        const body = { mfa_token: challenge.mfa_token };
+        if (/^\d{6}$/.test(value)) {
+            body.code = value;
+        } else {
+            body.recovery_code = value;
+        }
+
+        try {
+            const response = await fetch('/auth/2fa/verify', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(body)
+            });
+            const data = await response.json();
+
+            if (data.status !== 'OK') {
+                errorMessage.textContent = data.error || 'Неверный код';
+                return;
+            }
+
+            if (data.data.recovery_codes && data.data.recovery_codes.length) {
+                mfaForm.style.display = 'none';
+                document.getElementById('recoveryCodesList').textContent = data.data.recovery_codes.join('\n');
+                document.getElementById('recoveryCodes').style.display = '';
+                document.getElementById('recoveryCodesDone').addEventListener('click', function() {
+                    saveSession(data.data.token, data.data.refresh_token, data.data.role);
+                });
+                return;
+            }
+
+            saveSession(data.data.token, data.data.refresh_token, data.data.role);
+        } catch (error) {
+            errorMessage.textContent = 'Ошибка подключения к серверу';
+        }
+    });
+}
+
+// В режиме cookie токенов в ответе нет, они уже установлены сервером
+function saveSession(token, refreshToken, role) {
+    if (token) {
+        localStorage.setItem('token', token);
+        localStorage.setItem('refresh_token', refreshToken);
+    } else {
+        localStorage.removeItem('token');
+        localStorage.removeItem('refresh_token');
+    }
+    localStorage.setItem('role', role);
+    window.location.href = '/';
+}
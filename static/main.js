@@ -1,7 +1,6 @@
 document.addEventListener('DOMContentLoaded', function() {
     // Проверка авторизации
-    const token = localStorage.getItem('token');
-    if (!token) {
+    if (!api.hasSession()) {
         window.location.href = '/login.html';
         return;
     }
@@ -11,18 +10,46 @@ document.addEventListener('DOMContentLoaded', function() {
     document.getElementById('user-role').textContent = `Роль: ${getRoleName(role)}`;
 
     // Обработчик выхода
-    document.getElementById('logout-btn').addEventListener('click', function() {
-        localStorage.removeItem('token');
-        localStorage.removeItem('role');
-        window.location.href = '/login.html';
+    document.getElementById('logout-btn').addEventListener('click', async function() {
+        try {
+            await api.logout();
+        } finally {
+            window.location.href = '/login.html';
+        }
     });
 
     // Инициализация табов
     initTabs();
 
+    // Фильтры и подгрузка списка товаров
+    document.getElementById('items-filter').addEventListener('submit', function(e) {
+        e.preventDefault();
+        loadItems();
+    });
+    document.getElementById('items-more-btn').addEventListener('click', () => loadItems(true));
+    document.getElementById('items-search').addEventListener('submit', function(e) {
+        e.preventDefault();
+        searchItems();
+    });
+
+    // Выгрузка с текущими фильтрами списка
+    document.getElementById('items-export-btn').addEventListener('click', function() {
+        const params = itemsQuery();
+        params.set('format', document.getElementById('items-export-format').value);
+        exportFile(`/items/export?${params}`);
+    });
+    document.getElementById('history-export-btn').addEventListener('click', function() {
+        const params = new URLSearchParams({ format: document.getElementById('history-export-format').value });
+        exportFile(`/history/export?${params}`);
+    });
+
+    document.getElementById('add-category-btn').addEventListener('click', () => addCategory());
+
     // Загрузка данных
-    loadItems();
+    // Пути категорий нужны для списка товаров, поэтому товары грузятся после них
+    loadCategories().then(loadItems);
     loadHistory();
+    loadTrash();
 
     // Обработчики модальных окон
     initModal();
@@ -57,12 +84,39 @@ function initTabs() {
     });
 }
 
-// Загрузка товаров
-async function loadItems() {
+// Загрузка товаров постранично. С append=true подгружается следующая страница
+let itemsNextCursor = '';
+
+function itemsQuery() {
+    const params = new URLSearchParams();
+    const name = document.getElementById('filter-name').value.trim();
+    const quantityMin = document.getElementById('filter-quantity-min').value;
+    const quantityMax = document.getElementById('filter-quantity-max').value;
+    if (name) params.set('name', name);
+    if (quantityMin !== '') params.set('quantity_min', quantityMin);
+    if (quantityMax !== '') params.set('quantity_max', quantityMax);
+    const categoryId = document.getElementById('filter-category').value;
+    const tags = parseTags(document.getElementById('filter-tags').value);
+    if (categoryId) params.set('category_id', categoryId);
+    if (tags.length > 0) params.set('tag', tags.join(','));
+    params.set('sort', document.getElementById('filter-sort').value);
+    params.set('order', document.getElementById('filter-order').value);
+    return params;
+}
+
+async function loadItems(append = false) {
+    const params = itemsQuery();
+    if (append && itemsNextCursor) {
+        params.set('cursor', itemsNextCursor);
+    }
+
     try {
-        const response = await api.get('/items');
+        const response = await api.get(`/items?${params}`);
         if (response.status === 'OK') {
-            renderItems(response.data);
+            renderItems(response.data, append);
+            itemsNextCursor = response.meta.next_cursor || '';
+            document.getElementById('items-total').textContent = `Найдено: ${response.meta.total}`;
+            document.getElementById('items-more-btn').style.display = itemsNextCursor ? 'block' : 'none';
         } else {
             console.error('Ошибка загрузки товаров:', response.error);
         }
@@ -71,27 +125,99 @@ async function loadItems() {
     }
 }
 
-function renderItems(items) {
+async function exportFile(url) {
+    try {
+        const response = await api.download(url);
+        if (response.status !== 'OK') {
+            alert('Ошибка выгрузки: ' + response.error);
+        }
+    } catch (error) {
+        console.error('Ошибка подключения:', error);
+    }
+}
+
+// Поиск показывает лучшие совпадения одной страницей, пустая строка возвращает к списку
+async function searchItems() {
+    const text = document.getElementById('search-text').value.trim();
+    if (!text) {
+        loadItems();
+        return;
+    }
+
+    try {
+        const response = await api.get(`/items/search?${new URLSearchParams({ q: text })}`);
+        if (response.status === 'OK') {
+            renderItems(response.data);
+            itemsNextCursor = '';
+            document.getElementById('items-total').textContent = `Найдено: ${response.data.length}`;
+            document.getElementById('items-more-btn').style.display = 'none';
+        } else {
+            alert('Ошибка поиска: ' + response.error);
+        }
+    } catch (error) {
+        console.error('Ошибка подключения:', error);
+    }
+}
+
+// Подсветка от сервера не экранирует название: экранируем все, кроме <mark>
+function highlightName(highlight) {
+    const div = document.createElement('div');
+    div.textContent = highlight;
+    return div.innerHTML
+        .replace(/&lt;mark&gt;/g, '<mark>')
+        .replace(/&lt;\/mark&gt;/g, '</mark>');
+}
+
+function renderItems(items, append = false) {
     const tbody = document.querySelector('#items-table tbody');
-    tbody.innerHTML = '';
+    if (!append) {
+        tbody.innerHTML = '';
+    }
 
     items.forEach(item => {
         const row = document.createElement('tr');
         row.innerHTML = `
             <td>${item.id}</td>
-            <td>${item.name}</td>
-            <td>${item.quantity}</td>
+            <td>${item.sku}</td>
+            <td>${item.highlight ? highlightName(item.highlight) : item.name}${formatItemMeta(item)}</td>
+            <td>${item.quantity} ${item.unit}${formatStock(item.stock)}</td>
+            <td>${(item.barcodes || []).join(', ')}</td>
             <td>${formatDate(item.created_at)}</td>
             <td>${formatDate(item.updated_at)}</td>
             <td class="action-buttons">
-                <button class="btn-warning" onclick="editItem(${item.id})">Редактировать</button>
-                <button class="btn-danger" onclick="deleteItem(${item.id})">Удалить</button>
+                ${can('items:update') ? `<button class="btn-warning" onclick="editItem(${item.id})">Редактировать</button>` : ''}
+                ${can('items:delete') ? `<button class="btn-danger" onclick="deleteItem(${item.id}, ${item.version})">Удалить</button>` : ''}
             </td>
         `;
         tbody.appendChild(row);
     });
 }
 
+// Категория и теги под названием товара
+function formatItemMeta(item) {
+    const parts = [];
+    if (item.category_id && categoryPaths[item.category_id]) {
+        parts.push(categoryPaths[item.category_id]);
+    }
+    (item.tags || []).forEach(tag => parts.push('#' + tag));
+    if (parts.length === 0) {
+        return '';
+    }
+    const div = document.createElement('div');
+    div.className = 'item-meta';
+    div.textContent = parts.join(' · ');
+    return div.outerHTML;
+}
+
+// Разбивка остатка по местам хранения: СКЛАД/МЕСТО: количество
+function formatStock(stock) {
+    if (!stock || stock.length === 0) {
+        return '';
+    }
+    const lines = stock.map(s => `${s.warehouse_code}/${s.location_code}: ${s.quantity} ${s.unit}`);
+    return `<div class="stock-breakdown">${lines.join('<br>')}</div>`;
+}
+
 // Загрузка истории
 async function loadHistory() {
     try {
@@ -126,6 +252,177 @@ function renderHistory(history) {
     });
 }
 
+// Корзина: товары, удаленные без потери истории
+async function loadTrash() {
+    try {
+        const response = await api.get('/items/trash?limit=1000');
+        if (response.status === 'OK') {
+            renderTrash(response.data);
+        } else {
+            console.error('Ошибка загрузки корзины:', response.error);
+        }
+    } catch (error) {
+        console.error('Ошибка подключения:', error);
+    }
+}
+
+function renderTrash(items) {
+    const tbody = document.querySelector('#trash-table tbody');
+    tbody.innerHTML = '';
+
+    items.forEach(item => {
+        const row = document.createElement('tr');
+        row.innerHTML = `
+            <td>${item.id}</td>
+            <td>${item.sku}</td>
+            <td>${item.name}</td>
+            <td>${item.quantity} ${item.unit}</td>
+            <td>${formatDate(item.deleted_at)}</td>
+            <td class="action-buttons">
+                ${can('items:delete') ? `<button class="btn-warning" onclick="restoreItem(${item.id})">Восстановить</button>` : ''}
+                ${can('items:purge') ? `<button class="btn-danger" onclick="purgeItem(${item.id})">Удалить навсегда</button>` : ''}
+            </td>
+        `;
+        tbody.appendChild(row);
+    });
+}
+
+async function restoreItem(id) {
+    try {
+        const response = await api.post(`/items/${id}/restore`);
+        if (response.status === 'OK') {
+            loadItems();
+            loadTrash();
+            loadHistory();
+        } else {
+            alert('Ошибка восстановления: ' + response.error);
+        }
+    } catch (error) {
+        alert('Ошибка подключения к серверу');
+    }
+}
+
+async function purgeItem(id) {
+    if (!confirm('Удалить товар навсегда? История изменений сохранится.')) {
+        return;
+    }
+
+    try {
+        const response = await api.delete(`/items/trash/${id}`);
+        if (response.status === 'OK') {
+            loadTrash();
+            loadHistory();
+        } else {
+            alert('Ошибка удаления: ' + response.error);
+        }
+    } catch (error) {
+        alert('Ошибка подключения к серверу');
+    }
+}
+
+// Категории: путь по id для списка товаров, выпадающие списки и вкладка управления
+let categoryPaths = {};
+
+async function loadCategories() {
+    try {
+        const response = await api.get('/categories');
+        if (response.status === 'OK') {
+            const flat = [];
+            const walk = (nodes, depth) => nodes.forEach(c => {
+                flat.push({ ...c, depth });
+                walk(c.children || [], depth + 1);
+            });
+            walk(response.data, 0);
+
+            categoryPaths = {};
+            flat.forEach(c => categoryPaths[c.id] = c.path);
+            fillCategorySelect('filter-category', flat);
+            fillCategorySelect('item-category', flat);
+            renderCategories(flat);
+        } else {
+            console.error('Ошибка загрузки категорий:', response.error);
+        }
+    } catch (error) {
+        console.error('Ошибка подключения:', error);
+    }
+}
+
+// Первый пункт списка ("все" или "без категории") сохраняется
+function fillCategorySelect(id, categories) {
+    const select = document.getElementById(id);
+    const selected = select.value;
+    select.length = 1;
+    categories.forEach(c => select.add(new Option(c.path, c.id)));
+    select.value = selected;
+}
+
+function renderCategories(categories) {
+    const tbody = document.querySelector('#categories-table tbody');
+    tbody.innerHTML = '';
+    const manage = can('categories:manage');
+
+    categories.forEach(c => {
+        const row = document.createElement('tr');
+        row.innerHTML = `
+            <td>${c.id}</td>
+            <td><span class="category-depth" style="padding-left: ${c.depth * 1.5}rem"></span></td>
+            <td class="action-buttons">
+                ${manage ? `<button class="btn-primary" onclick="addCategory(${c.id})">Подкатегория</button>` : ''}
+                ${manage ? `<button class="btn-warning" onclick="renameCategory(${c.id})">Переименовать</button>` : ''}
+                ${manage ? `<button class="btn-warning" onclick="moveCategory(${c.id})">Перенести</button>` : ''}
+                ${manage ? `<button class="btn-danger" onclick="deleteCategory(${c.id})">Удалить</button>` : ''}
+            </td>
+        `;
+        row.querySelector('.category-depth').textContent = c.name;
+        tbody.appendChild(row);
+    });
+
+    document.getElementById('add-category-btn').style.display = manage ? '' : 'none';
+}
+
+async function categoryAction(request) {
+    try {
+        const response = await request();
+        if (response.status === 'OK') {
+            loadCategories();
+            loadItems();
+        } else {
+            alert('Ошибка: ' + response.error);
+        }
+    } catch (error) {
+        alert('Ошибка подключения к серверу');
+    }
+}
+
+function addCategory(parentId = null) {
+    const name = prompt('Название категории:');
+    if (name) {
+        categoryAction(() => api.post('/categories', { name, parent_id: parentId }));
+    }
+}
+
+function renameCategory(id) {
+    const name = prompt('Новое название:', (categoryPaths[id] || '').split(' / ').pop());
+    if (name) {
+        categoryAction(() => api.put(`/categories/${id}`, { name }));
+    }
+}
+
+function moveCategory(id) {
+    const value = prompt('ID новой родительской категории (пусто - в корень):');
+    if (value === null) {
+        return;
+    }
+    const parentId = value.trim() ? parseInt(value) : null;
+    categoryAction(() => api.post(`/categories/${id}/move`, { parent_id: parentId }));
+}
+
+function deleteCategory(id) {
+    if (confirm(`Удалить категорию "${categoryPaths[id]}"? Удалить можно только пустую категорию.`)) {
+        categoryAction(() => api.delete(`/categories/${id}`));
+    }
+}
+
 // Модальное окно для товаров
 function initModal() {
     const modal = document.getElementById('item-modal');
@@ -149,22 +446,34 @@ function initModal() {
         e.preventDefault();
 
         const id = document.getElementById('item-id').value;
+        const version = document.getElementById('item-version').value;
+        const sku = document.getElementById('item-sku').value.trim();
         const name = document.getElementById('item-name').value;
-        const quantity = parseInt(document.getElementById('item-quantity').value);
+        const quantity = parseFloat(document.getElementById('item-quantity').value);
+        const unit = document.getElementById('item-unit').value.trim() || 'pcs';
+        const unit_precision = parseInt(document.getElementById('item-unit-precision').value);
+        const units = parseUnits(document.getElementById('item-units').value);
+        const barcodes = parseBarcodes(document.getElementById('item-barcodes').value);
+        const categoryValue = document.getElementById('item-category').value;
+        const category_id = categoryValue ? parseInt(categoryValue) : null;
+        const tags = parseTags(document.getElementById('item-tags').value);
 
         try {
             let response;
             if (id) {
                 // Обновление
-                response = await api.put(`/items/${id}`, { name, quantity });
+                response = await api.put(`/items/${id}`, { sku, name, quantity, unit, unit_precision, units, barcodes, category_id, tags }, ifMatch(version));
             } else {
                 // Создание
-                response = await api.post('/items', { name, quantity });
+                response = await api.post('/items', { sku, name, quantity, unit, unit_precision, units, barcodes, category_id, tags });
             }
 
             if (response.status === 'OK') {
                 modal.style.display = 'none';
                 loadItems();
+            } else if (response.httpStatus === 412) {
+                await showVersionConflict(version, response.data);
+                openItemModal(response.data);
             } else {
                 alert('Ошибка: ' + response.error);
             }
@@ -174,30 +483,127 @@ function initModal() {
     });
 
     // Кнопка добавления товара
-    document.getElementById('add-item-btn').addEventListener('click', function() {
+    const addItemBtn = document.getElementById('add-item-btn');
+    if (!can('items:create')) {
+        addItemBtn.style.display = 'none';
+    }
+    addItemBtn.addEventListener('click', function() {
         openItemModal();
     });
+
+    // Импорт из CSV / XLSX: сначала пробный запуск, затем сохранение
+    if (!can('items:create') || !can('items:update')) {
+        document.getElementById('import-controls').style.display = 'none';
+    }
+    document.getElementById('import-check-btn').addEventListener('click', () => importItems(true));
+    document.getElementById('import-run-btn').addEventListener('click', () => importItems(false));
+}
+
+async function importItems(dryRun) {
+    const file = document.getElementById('import-file').files[0];
+    if (!file) {
+        alert('Выберите файл CSV или XLSX');
+        return;
+    }
+
+    const params = new URLSearchParams({
+        format: file.name.toLowerCase().endsWith('.xlsx') ? 'xlsx' : 'csv',
+        match_by: document.getElementById('import-match').value,
+        dry_run: dryRun
+    });
+
+    try {
+        const response = await api.upload(`/items/import?${params}`, file);
+        const report = response.data;
+        if (!report) {
+            alert('Ошибка импорта: ' + response.error);
+            return;
+        }
+
+        let message = `Новых: ${report.created}, изменено: ${report.updated}, без изменений: ${report.unchanged}, с ошибками: ${report.failed}`;
+        const failed = report.rows.filter(row => row.action === 'error').slice(0, 20);
+        if (failed.length > 0) {
+            message += '\n\n' + failed.map(row => `Строка ${row.line}: ${row.errors.join('; ')}`).join('\n');
+        }
+        if (report.committed) {
+            message = 'Импорт выполнен. ' + message;
+            loadItems();
+            loadHistory();
+        } else if (report.failed > 0) {
+            message = 'Импорт не выполнен, исправьте ошибки. ' + message;
+        } else {
+            message = 'Проверка пройдена. ' + message;
+        }
+        alert(message);
+    } catch (error) {
+        alert('Ошибка подключения к серверу');
+    }
+}
+
+function parseTags(value) {
+    return value.split(',').map(tag => tag.trim()).filter(tag => tag !== '');
+}
+
+// Штрихкоды вводятся через запятую, пробел или с новой строки (сканер добавляет Enter)
+function parseBarcodes(value) {
+    return value.split(/[\s,;]+/).filter(code => code !== '');
+}
+
+// Упаковки вводятся как "case=24, pack=6": единица и число базовых единиц в ней
+function parseUnits(value) {
+    return value.split(',').map(part => part.trim()).filter(part => part !== '').map(part => {
+        const [unit, factor] = part.split('=');
+        return { unit: (unit || '').trim(), factor: parseFloat(factor) };
+    });
+}
+
+function formatUnits(units) {
+    return (units || []).map(u => `${u.unit}=${u.factor}`).join(', ');
 }
 
 function openItemModal(item = null) {
     const modal = document.getElementById('item-modal');
     const title = document.getElementById('modal-title');
     const idInput = document.getElementById('item-id');
+    const versionInput = document.getElementById('item-version');
+    const skuInput = document.getElementById('item-sku');
     const nameInput = document.getElementById('item-name');
     const quantityInput = document.getElementById('item-quantity');
+    const unitInput = document.getElementById('item-unit');
+    const precisionInput = document.getElementById('item-unit-precision');
+    const unitsInput = document.getElementById('item-units');
+    const barcodesInput = document.getElementById('item-barcodes');
+    const categoryInput = document.getElementById('item-category');
+    const tagsInput = document.getElementById('item-tags');
 
     if (item) {
         // Редактирование
         title.textContent = 'Редактировать товар';
         idInput.value = item.id;
+        versionInput.value = item.version;
+        skuInput.value = item.sku;
         nameInput.value = item.name;
         quantityInput.value = item.quantity;
+        unitInput.value = item.unit;
+        precisionInput.value = item.unit_precision;
+        unitsInput.value = formatUnits(item.units);
+        barcodesInput.value = (item.barcodes || []).join(', ');
+        categoryInput.value = item.category_id || '';
+        tagsInput.value = (item.tags || []).join(', ');
     } else {
         // Создание
         title.textContent = 'Добавить товар';
         idInput.value = '';
+        versionInput.value = '';
+        skuInput.value = '';
         nameInput.value = '';
         quantityInput.value = '';
+        unitInput.value = 'pcs';
+        precisionInput.value = '0';
+        unitsInput.value = '';
+        barcodesInput.value = '';
+        categoryInput.value = '';
+        tagsInput.value = '';
     }
 
     modal.style.display = 'block';
@@ -216,15 +622,19 @@ async function editItem(id) {
     }
 }
 
-async function deleteItem(id) {
-    if (!confirm('Вы уверены, что хотите удалить этот товар?')) {
+async function deleteItem(id, version) {
+    if (!confirm('Переместить товар в корзину?')) {
         return;
     }
 
     try {
-        const response = await api.delete(`/items/${id}`);
+        const response = await api.delete(`/items/${id}`, ifMatch(version));
         if (response.status === 'OK') {
             loadItems();
+            loadTrash();
+        } else if (response.httpStatus === 412) {
+            await showVersionConflict(version, response.data);
+            loadItems();
         } else {
             alert('Ошибка удаления: ' + response.error);
         }
@@ -233,6 +643,35 @@ async function deleteItem(id) {
     }
 }
 
+function ifMatch(version) {
+    return { 'If-Match': `"${version}"` };
+}
+
+// Товар изменили после того, как он был открыт: показываем текущую версию
+// и последнее изменение из истории
+async function showVersionConflict(version, current) {
+    if (!current) {
+        alert('Товар был изменен или удален другим пользователем');
+        return;
+    }
+
+    let lastChange = '';
+    try {
+        const history = await api.get(`/history/${current.id}`);
+        if (history.status === 'OK' && history.data && history.data.length > 0) {
+            const last = history.data[0];
+            lastChange = `\nПоследнее изменение: ${formatAction(last.action)}, ${last.changed_by}, ${formatDate(last.changed_at)}`;
+        }
+    } catch (error) {
+        console.error('Ошибка загрузки истории:', error);
+    }
+
+    alert(`Товар изменен другим пользователем: версия ${current.version}, у вас была версия ${version}.` +
+        lastChange +
+        `\nСейчас: ${current.sku} «${current.name}», количество ${current.quantity} ${current.unit}.` +
+        '\nПроверьте данные и повторите действие.');
+}
+
 // Показ деталей истории
 function showDetails(id) {
     // Для простоты показываем alert, но можно сделать полноценное модальное окно
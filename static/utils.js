@@ -1,6 +1,17 @@
 // Утилиты для работы с API
 const API_BASE = ''; // Пустой базовый путь
 
+// Режим cookie (auth.session.mode): токены в HttpOnly cookie, недоступных скриптам.
+// Изменяющие запросы повторяют CSRF токен из cookie в заголовке
+const CSRF_COOKIE = 'wc_csrf';
+const CSRF_HEADER = 'X-CSRF-Token';
+
+function getCookie(name) {
+    const prefix = name + '=';
+    const cookie = document.cookie.split('; ').find(c => c.startsWith(prefix));
+    return cookie ? decodeURIComponent(cookie.substring(prefix.length)) : null;
+}
+
 class ApiClient {
     constructor() {
         this.token = localStorage.getItem('token');
@@ -11,12 +22,60 @@ class ApiClient {
         localStorage.setItem('token', token);
     }
 
+    // Есть ли сессия: токен в localStorage или cookie сессии
+    hasSession() {
+        return !!this.token || !!getCookie(CSRF_COOKIE);
+    }
+
     clearToken() {
         this.token = null;
         localStorage.removeItem('token');
+        localStorage.removeItem('refresh_token');
+    }
+
+    // Обмен refresh токена на новую пару токенов
+    async refresh() {
+        const refreshToken = localStorage.getItem('refresh_token');
+        const csrfToken = getCookie(CSRF_COOKIE);
+        if (!refreshToken && !csrfToken) {
+            return false;
+        }
+
+        // В режиме cookie refresh токен отправляет браузер
+        const options = { method: 'POST', headers: { 'Content-Type': 'application/json' } };
+        if (refreshToken) {
+            options.body = JSON.stringify({ refresh_token: refreshToken });
+        } else {
+            options.headers[CSRF_HEADER] = csrfToken;
+        }
+
+        const response = await fetch(API_BASE + '/auth/refresh', options);
+        if (!response.ok) {
+            return false;
+        }
+
+        const data = await response.json();
+        if (data.data.token) {
+            this.setToken(data.data.token);
+            localStorage.setItem('refresh_token', data.data.refresh_token);
+        }
+        localStorage.setItem('role', data.data.role);
+        return true;
+    }
+
+    async logout() {
+        try {
+            await this.request('/auth/logout', {
+                method: 'POST',
+                body: JSON.stringify({ refresh_token: localStorage.getItem('refresh_token') })
+            });
+        } finally {
+            this.clearToken();
+            localStorage.removeItem('role');
+        }
     }
 
-    async request(url, options = {}) {
+    async request(url, options = {}, retry = true) {
         const headers = {
             'Content-Type': 'application/json',
             ...options.headers
@@ -24,6 +83,8 @@ class ApiClient {
 
         if (this.token) {
             headers['Authorization'] = `Bearer ${this.token}`;
+        } else if (getCookie(CSRF_COOKIE)) {
+            headers[CSRF_HEADER] = getCookie(CSRF_COOKIE);
         }
 
         const config = {
@@ -34,6 +95,9 @@ class ApiClient {
         const response = await fetch(API_BASE + url, config);
 
         if (response.status === 401) {
+            if (retry && await this.refresh()) {
+                return this.request(url, options, false);
+            }
             this.clearToken();
             window.location.href = '/login.html';
             throw new Error('Unauthorized');
@@ -42,9 +106,16 @@ class ApiClient {
         return response;
     }
 
+    // HTTP статус нужен, чтобы отличить конфликт версий (412) от прочих ошибок
+    async parse(response) {
+        const body = await response.json();
+        body.httpStatus = response.status;
+        return body;
+    }
+
     async get(url) {
         const response = await this.request(url, { method: 'GET' });
-        return response.json();
+        return this.parse(response);
     }
 
     async post(url, data) {
@@ -52,20 +123,48 @@ class ApiClient {
             method: 'POST',
             body: JSON.stringify(data)
         });
-        return response.json();
+        return this.parse(response);
     }
 
-    async put(url, data) {
+    // Загрузка файла как есть, без JSON
+    async upload(url, file) {
+        const response = await this.request(url, {
+            method: 'POST',
+            body: file,
+            headers: { 'Content-Type': file.type || 'application/octet-stream' }
+        });
+        return this.parse(response);
+    }
+
+    // Скачивание файла выгрузки под именем из Content-Disposition
+    async download(url) {
+        const response = await this.request(url, { method: 'GET' });
+        if (!response.ok) {
+            return this.parse(response);
+        }
+
+        const disposition = response.headers.get('Content-Disposition') || '';
+        const match = disposition.match(/filename="([^"]+)"/);
+        const link = document.createElement('a');
+        link.href = URL.createObjectURL(await response.blob());
+        link.download = match ? match[1] : 'export';
+        link.click();
+        URL.revokeObjectURL(link.href);
+        return { status: 'OK' };
+    }
+
+    async put(url, data, headers = {}) {
         const response = await this.request(url, {
             method: 'PUT',
-            body: JSON.stringify(data)
+            body: JSON.stringify(data),
+            headers
         });
-        return response.json();
+        return this.parse(response);
     }
 
-    async delete(url) {
-        const response = await this.request(url, { method: 'DELETE' });
-        return response.json();
+    async delete(url, headers = {}) {
+        const response = await this.request(url, { method: 'DELETE', headers });
+        return this.parse(response);
     }
 }
 
@@ -84,7 +183,22 @@ function formatAction(action) {
     const actions = {
         'create': 'Создание',
         'update': 'Обновление',
-        'delete': 'Удаление'
+        'delete': 'Удаление в корзину',
+        'restore': 'Восстановление',
+        'purge': 'Окончательное удаление',
+        'stock': 'Остаток в месте'
     };
     return actions[action] || action;
+}
+
+// Права ролей - должны совпадать с матрицей на сервере (middleware/role.go)
+const ROLE_PERMISSIONS = {
+    'viewer': ['items:read', 'history:read'],
+    'manager': ['items:read', 'history:read', 'items:create', 'items:update', 'categories:manage'],
+    'admin': ['items:read', 'history:read', 'items:create', 'items:update', 'items:delete', 'users:manage', 'api_keys:manage', 'audit:read', 'warehouses:manage', 'items:purge', 'categories:manage']
+};
+
+function can(permission) {
+    const role = localStorage.getItem('role') || 'viewer';
+    return (ROLE_PERMISSIONS[role] || []).includes(permission);
 }
\ No newline at end of file